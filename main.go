@@ -1,13 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/Masterminds/semver/v3"
@@ -15,6 +20,7 @@ import (
 	"github.com/rancher/partner-charts-ci/pkg/conform"
 	"github.com/rancher/partner-charts-ci/pkg/fetcher"
 	"github.com/rancher/partner-charts-ci/pkg/icons"
+	"github.com/rancher/partner-charts-ci/pkg/kubeapi"
 	p "github.com/rancher/partner-charts-ci/pkg/paths"
 	"github.com/rancher/partner-charts-ci/pkg/pkg"
 	"github.com/rancher/partner-charts-ci/pkg/upstreamyaml"
@@ -25,8 +31,9 @@ import (
 
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
-	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/repo"
+
+	"sigs.k8s.io/yaml"
 )
 
 const (
@@ -46,11 +53,33 @@ const (
 )
 
 var (
-	version         = "v0.0.0"
-	commit          = "HEAD"
-	force           = false
-	makeCommit      = false
-	modifyGenerated = false
+	addOCIRepo            = ""
+	cullDryRun            = false
+	cullKeepLatestPatches = false
+	cullKeepNewest        = 0
+	cullPins              = cli.NewStringSlice()
+	addRunUpdate          = false
+	addUpstreamYaml       = upstreamyaml.UpstreamYaml{}
+	annotateLatestOnly    = false
+	annotateRemove        = false
+	version               = "v0.0.0"
+	commit                = "HEAD"
+	force                 = false
+	jsonOutput            = false
+	makeCommit            = false
+	modifyGenerated       = false
+	moveDisplayName       = ""
+	moveVendor            = ""
+	staleDays             = 0
+	staleFailures         = 0
+	statusFormat          = ""
+	statusOffline         = false
+	validateBase          = ""
+	validateFix           = false
+	validateFormat        = ""
+	validateList          = false
+	validateOnly          = cli.NewStringSlice()
+	validateSkip          = cli.NewStringSlice()
 )
 
 // ChartWrapper is like a chart.Chart, but it tracks whether the chart
@@ -76,6 +105,9 @@ func annotate(paths p.Paths, vendor, chartName, annotation, value string, remove
 
 	chartsToUpdate := make([]*ChartWrapper, 0, len(existingCharts))
 	if onlyLatest {
+		if len(existingCharts) == 0 {
+			return fmt.Errorf("%s/%s has no chart versions", vendor, chartName)
+		}
 		chartsToUpdate = append(chartsToUpdate, existingCharts[0])
 	} else {
 		chartsToUpdate = existingCharts
@@ -151,8 +183,10 @@ func commitChanges(paths p.Paths, updatedPackageWrappers []pkg.PackageWrapper) e
 
 	}
 
-	if _, err := wt.Add(paths.IndexYaml); err != nil {
-		return fmt.Errorf("failed to add %q to working tree: %w", paths.IndexYaml, err)
+	for _, path := range []string{paths.IndexYaml, paths.StaleYaml} {
+		if _, err := wt.Add(path); err != nil {
+			return fmt.Errorf("failed to add %q to working tree: %w", path, err)
+		}
 	}
 	commitMessage := "Added chart versions:\n"
 	sortPackageWrappers(updatedPackageWrappers)
@@ -180,12 +214,16 @@ func commitChanges(paths p.Paths, updatedPackageWrappers []pkg.PackageWrapper) e
 	return nil
 }
 
-func ApplyUpdates(paths p.Paths, packageWrapper pkg.PackageWrapper) error {
+// ApplyUpdates fetches packageWrapper.FetchVersions, integrates them
+// with the existing chart versions of the package and writes them. It
+// returns the chart versions from FetchVersions that were integrated;
+// the others were rejected by integrateCharts.
+func ApplyUpdates(paths p.Paths, packageWrapper pkg.PackageWrapper) (repo.ChartVersions, error) {
 	logrus.Debugf("Applying updates for package %s/%s\n", packageWrapper.Vendor, packageWrapper.Name)
 
 	existingCharts, err := loadExistingCharts(paths, packageWrapper.Vendor, packageWrapper.Name)
 	if err != nil {
-		return fmt.Errorf("failed to load existing charts: %w", err)
+		return nil, fmt.Errorf("failed to load existing charts: %w", err)
 	}
 
 	// for new charts, convert repo.ChartVersions to *chart.Chart
@@ -199,24 +237,34 @@ func ApplyUpdates(paths p.Paths, packageWrapper pkg.PackageWrapper) error {
 			newChart, err = fetcher.LoadChartFromURL(chartVersion.URLs[0])
 		}
 		if err != nil {
-			return fmt.Errorf("failed to fetch chart: %w", err)
+			return nil, fmt.Errorf("failed to fetch chart: %w", err)
 		}
 		newChart.Metadata.Version = chartVersion.Version
 		newCharts = append(newCharts, NewChartWrapper(newChart))
 	}
 
-	if err := integrateCharts(paths, packageWrapper, existingCharts, newCharts); err != nil {
-		return fmt.Errorf("failed to reconcile charts for package %q: %w", packageWrapper.Name, err)
+	newCharts, err = integrateCharts(paths, packageWrapper, existingCharts, newCharts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconcile charts for package %q: %w", packageWrapper.Name, err)
 	}
 
 	allCharts := make([]*ChartWrapper, 0, len(existingCharts)+len(newCharts))
 	allCharts = append(allCharts, existingCharts...)
 	allCharts = append(allCharts, newCharts...)
 	if err := writeCharts(paths, packageWrapper.Vendor, packageWrapper.Name, allCharts); err != nil {
-		return fmt.Errorf("failed to write charts: %w", err)
+		return nil, fmt.Errorf("failed to write charts: %w", err)
 	}
 
-	return nil
+	integratedVersions := make(repo.ChartVersions, 0, len(newCharts))
+	for _, chartVersion := range packageWrapper.FetchVersions {
+		integrated := slices.ContainsFunc(newCharts, func(newChart *ChartWrapper) bool {
+			return newChart.Metadata.Version == chartVersion.Version
+		})
+		if integrated {
+			integratedVersions = append(integratedVersions, chartVersion)
+		}
+	}
+	return integratedVersions, nil
 }
 
 // Copied from helm's chartutil.Save, which unfortunately does
@@ -266,7 +314,7 @@ func writeCharts(paths p.Paths, vendor, chartName string, chartWrappers []*Chart
 			return fmt.Errorf("failed to check %s for existence: %w", assetsPath, err)
 		}
 		if chartWrapper.Modified || !tgzFileExists {
-			_, err := chartutil.Save(chartWrapper.Chart, assetsDir)
+			_, err := conform.SaveChart(chartWrapper.Chart, assetsDir)
 			if err != nil {
 				return fmt.Errorf("failed to write tgz for %q version %q: %w", chartWrapper.Name(), chartWrapper.Metadata.Version, err)
 			}
@@ -287,6 +335,90 @@ func writeCharts(paths p.Paths, vendor, chartName string, chartWrappers []*Chart
 	return nil
 }
 
+// A chartsBackup holds the chart archives of a package so that its
+// assets and charts directories can be restored if changing it fails.
+type chartsBackup struct {
+	vendor    string
+	chartName string
+	// archives maps the path of each chart archive to its version and
+	// contents.
+	archives map[string]backedUpArchive
+}
+
+type backedUpArchive struct {
+	version  string
+	contents []byte
+}
+
+// backupCharts reads the chart archives of package <vendor>/<chartName>
+// into memory. Archives of other packages whose names begin with
+// chartName are left out.
+func backupCharts(paths p.Paths, vendor, chartName string) (chartsBackup, error) {
+	backup := chartsBackup{
+		vendor:    vendor,
+		chartName: chartName,
+		archives:  map[string]backedUpArchive{},
+	}
+	tgzPaths, err := getExistingChartTgzFiles(paths, vendor, chartName)
+	if err != nil {
+		return chartsBackup{}, fmt.Errorf("failed to get paths to existing chart tgz files: %w", err)
+	}
+	for _, tgzPath := range tgzPaths {
+		contents, err := os.ReadFile(tgzPath)
+		if err != nil {
+			return chartsBackup{}, fmt.Errorf("failed to read %s: %w", tgzPath, err)
+		}
+		helmChart, err := loader.LoadArchive(bytes.NewReader(contents))
+		if err != nil {
+			return chartsBackup{}, fmt.Errorf("failed to load %s: %w", tgzPath, err)
+		}
+		if helmChart.Name() != chartName {
+			continue
+		}
+		backup.archives[tgzPath] = backedUpArchive{version: helmChart.Metadata.Version, contents: contents}
+	}
+	return backup, nil
+}
+
+// restore puts the chart archives of the package back the way they were
+// when backup was made, and regenerates the package's charts directory
+// from them.
+func (backup chartsBackup) restore(paths p.Paths) error {
+	tgzPaths, err := getExistingChartTgzFiles(paths, backup.vendor, backup.chartName)
+	if err != nil {
+		return fmt.Errorf("failed to get paths to existing chart tgz files: %w", err)
+	}
+	for _, tgzPath := range tgzPaths {
+		if _, ok := backup.archives[tgzPath]; ok {
+			continue
+		}
+		helmChart, err := loader.LoadFile(tgzPath)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", tgzPath, err)
+		}
+		if helmChart.Name() != backup.chartName {
+			continue
+		}
+		if err := os.Remove(tgzPath); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", tgzPath, err)
+		}
+	}
+
+	chartsDir := filepath.Join(paths.Charts, backup.vendor, backup.chartName)
+	if err := os.RemoveAll(chartsDir); err != nil {
+		return fmt.Errorf("failed to wipe charts directory: %w", err)
+	}
+	for tgzPath, archive := range backup.archives {
+		if err := os.WriteFile(tgzPath, archive.contents, 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", tgzPath, err)
+		}
+		if err := conform.Gunzip(tgzPath, filepath.Join(chartsDir, archive.version)); err != nil {
+			return fmt.Errorf("failed to unpack %s: %w", tgzPath, err)
+		}
+	}
+	return nil
+}
+
 // loadExistingCharts loads the existing charts for package
 // <vendor>/<packageName> from the assets directory. It returns
 // them in a slice that is sorted by chart version, newest first.
@@ -343,34 +475,157 @@ func getExistingChartTgzFiles(paths p.Paths, vendor string, packageName string)
 // existing charts. It applies modifications to the new charts, and
 // ensures that the state of all charts, both current and new, is
 // correct. Should never modify an existing chart, except for in
-// the special case of the "featured" annotation.
-func integrateCharts(paths p.Paths, packageWrapper pkg.PackageWrapper, existingCharts, newCharts []*ChartWrapper) error {
+// the special case of the "featured" annotation. New charts whose
+// dependency tree cannot be completed or that use removed Kubernetes
+// APIs are rejected; the new charts that were integrated are returned.
+func integrateCharts(paths p.Paths, packageWrapper pkg.PackageWrapper, existingCharts, newCharts []*ChartWrapper) ([]*ChartWrapper, error) {
 	overlayFiles, err := packageWrapper.GetOverlayFiles()
 	if err != nil {
-		return fmt.Errorf("failed to get overlay files: %w", err)
+		return nil, fmt.Errorf("failed to get overlay files: %w", err)
 	}
 
+	integratedCharts := make([]*ChartWrapper, 0, len(newCharts))
 	for _, newChart := range newCharts {
 		if err := applyOverlayFiles(overlayFiles, newChart.Chart); err != nil {
-			return fmt.Errorf("failed to apply overlay files to chart %q version %q: %w", newChart.Name(), newChart.Metadata.Version, err)
+			return nil, fmt.Errorf("failed to apply overlay files to chart %q version %q: %w", newChart.Name(), newChart.Metadata.Version, err)
+		}
+		upstreamDependencies := slices.Clone(newChart.Metadata.Dependencies)
+		conform.OverlayChartMetadata(newChart.Chart, packageWrapper.UpstreamYaml.ChartMetadata, packageWrapper.UpstreamYaml.OverlayOptions())
+		if !packageWrapper.UpstreamYaml.PreserveKubeVersion {
+			newChart.Metadata.KubeVersion = conform.NormalizeKubeVersion(newChart.Metadata.KubeVersion)
 		}
-		conform.OverlayChartMetadata(newChart.Chart, packageWrapper.UpstreamYaml.ChartMetadata)
 		if err := addAnnotations(packageWrapper, newChart.Chart); err != nil {
-			return fmt.Errorf("failed to add annotations to chart %q version %q: %w", newChart.Name(), newChart.Metadata.Version, err)
+			return nil, fmt.Errorf("failed to add annotations to chart %q version %q: %w", newChart.Name(), newChart.Metadata.Version, err)
 		}
 		if err := ensureIcon(paths, packageWrapper, newChart); err != nil {
-			return fmt.Errorf("failed to ensure icon for chart %q version %q: %w", newChart.Name(), newChart.Metadata.Version, err)
+			return nil, fmt.Errorf("failed to ensure icon for chart %q version %q: %w", newChart.Name(), newChart.Metadata.Version, err)
+		}
+		if err := ensureDependencies(newChart.Chart, upstreamDependencies); err != nil {
+			logrus.Errorf("Rejecting chart %q version %q: failed to ensure dependencies: %s", newChart.Name(), newChart.Metadata.Version, err)
+			continue
+		}
+		if err := checkRemovedAPIs(newChart.Chart); err != nil {
+			logrus.Errorf("Rejecting chart %q version %q: uses removed Kubernetes APIs: %s", newChart.Name(), newChart.Metadata.Version, err)
+			continue
 		}
 		newChart.Modified = true
+		integratedCharts = append(integratedCharts, newChart)
+	}
+
+	if err := ensureFeaturedAnnotation(existingCharts, integratedCharts); err != nil {
+		return nil, fmt.Errorf("failed to ensure featured annotation: %w", err)
 	}
 
-	if err := ensureFeaturedAnnotation(existingCharts, newCharts); err != nil {
-		return fmt.Errorf("failed to ensure featured annotation: %w", err)
+	return integratedCharts, nil
+}
+
+// ensureDependencies ensures that every dependency declared by helmChart
+// is present as a subchart, and that each subchart's version satisfies
+// the dependency's version constraint and matches Chart.lock, if there
+// is one. Chart.lock comes from upstream, so only the dependencies in
+// upstreamDependencies, which helmChart declared before upstream.yaml's
+// ChartMetadata was applied, are checked against it. Missing
+// dependencies are fetched from their repositories. Subcharts are
+// checked in the same way, so that the whole dependency tree of
+// helmChart is complete.
+func ensureDependencies(helmChart *chart.Chart, upstreamDependencies []*chart.Dependency) error {
+	declared := map[string]bool{}
+	for _, dependency := range helmChart.Metadata.Dependencies {
+		dependencyName := dependency.Name
+		if dependency.Alias != "" {
+			dependencyName = dependency.Alias
+		}
+		if declared[dependencyName] {
+			return fmt.Errorf("dependency %q is declared more than once", dependencyName)
+		}
+		declared[dependencyName] = true
+
+		isSameDependency := func(other *chart.Dependency) bool {
+			return other.Name == dependency.Name && other.Repository == dependency.Repository
+		}
+		lockedVersion := ""
+		if helmChart.Lock != nil && slices.ContainsFunc(upstreamDependencies, isSameDependency) {
+			index := slices.IndexFunc(helmChart.Lock.Dependencies, isSameDependency)
+			if index == -1 {
+				return fmt.Errorf("dependency %q is not present in Chart.lock", dependency.Name)
+			}
+			lockedVersion = helmChart.Lock.Dependencies[index].Version
+		}
+
+		var subchart *chart.Chart
+		for _, candidate := range helmChart.Dependencies() {
+			if candidate.Name() == dependency.Name {
+				subchart = candidate
+				break
+			}
+		}
+		if subchart == nil {
+			if dependency.Repository == "" {
+				return fmt.Errorf("dependency %q is not present in charts/ and has no repository", dependency.Name)
+			}
+			logrus.Infof("Vendoring dependency %q of chart %q version %q", dependency.Name, helmChart.Name(), helmChart.Metadata.Version)
+			fetchedChart, err := fetcher.FetchDependency(dependency, lockedVersion)
+			if err != nil {
+				return fmt.Errorf("failed to fetch dependency %q: %w", dependency.Name, err)
+			}
+			helmChart.AddDependency(fetchedChart)
+			subchart = fetchedChart
+		}
+
+		if err := checkDependencyVersion(dependency, lockedVersion, subchart.Metadata.Version); err != nil {
+			return err
+		}
+		if err := ensureDependencies(subchart, subchart.Metadata.Dependencies); err != nil {
+			return fmt.Errorf("failed to ensure dependencies of subchart %q: %w", subchart.Name(), err)
+		}
 	}
+	return nil
+}
 
+// checkDependencyVersion checks that version, the version of the subchart
+// that fulfills dependency, satisfies dependency's version constraint and
+// is equal to lockedVersion, if lockedVersion is set.
+func checkDependencyVersion(dependency *chart.Dependency, lockedVersion, version string) error {
+	if lockedVersion != "" && version != lockedVersion {
+		return fmt.Errorf("subchart %q has version %s, but Chart.lock requires %s", dependency.Name, version, lockedVersion)
+	}
+	if dependency.Version == "" {
+		return nil
+	}
+	constraint, err := semver.NewConstraint(dependency.Version)
+	if err != nil {
+		return fmt.Errorf("failed to parse version constraint %q of dependency %q: %w", dependency.Version, dependency.Name, err)
+	}
+	parsedVersion, err := semver.NewVersion(version)
+	if err != nil {
+		return fmt.Errorf("failed to parse version %q of subchart %q: %w", version, dependency.Name, err)
+	}
+	if !constraint.Check(parsedVersion) {
+		return fmt.Errorf("subchart %q has version %s, which does not satisfy %q", dependency.Name, version, dependency.Version)
+	}
 	return nil
 }
 
+// checkRemovedAPIs returns an error if helmChart uses any Kubernetes APIs
+// that are removed in a Kubernetes version allowed by its kubeVersion
+// constraint. Charts that cannot be rendered are not rejected, since
+// rendering without user-provided values is not always possible.
+func checkRemovedAPIs(helmChart *chart.Chart) error {
+	usages, err := kubeapi.FindRemovedAPIUsages(helmChart)
+	if err != nil {
+		logrus.Warnf("failed to check chart %q version %q for removed APIs: %s", helmChart.Name(), helmChart.Metadata.Version, err)
+		return nil
+	}
+	if len(usages) == 0 {
+		return nil
+	}
+	errs := make([]error, 0, len(usages))
+	for _, usage := range usages {
+		errs = append(errs, errors.New(usage.String()))
+	}
+	return errors.Join(errs...)
+}
+
 // applyOverlayFiles applies the files referenced in overlayFiles to the files
 // in helmChart.Files. If a file already exists, it is overwritten.
 func applyOverlayFiles(overlayFiles map[string][]byte, helmChart *chart.Chart) error {
@@ -447,10 +702,15 @@ func addAnnotations(packageWrapper pkg.PackageWrapper, helmChart *chart.Chart) e
 		annotations[annotationNamespace] = packageWrapper.UpstreamYaml.Namespace
 	}
 
+	kubeVersion := helmChart.Metadata.KubeVersion
 	if packageWrapper.UpstreamYaml.ChartMetadata.KubeVersion != "" {
-		annotations[annotationKubeVersion] = packageWrapper.UpstreamYaml.ChartMetadata.KubeVersion
-	} else if helmChart.Metadata.KubeVersion != "" {
-		annotations[annotationKubeVersion] = helmChart.Metadata.KubeVersion
+		kubeVersion = packageWrapper.UpstreamYaml.ChartMetadata.KubeVersion
+	}
+	if kubeVersion != "" {
+		if !packageWrapper.UpstreamYaml.PreserveKubeVersion {
+			kubeVersion = conform.NormalizeKubeVersion(kubeVersion)
+		}
+		annotations[annotationKubeVersion] = kubeVersion
 	}
 
 	if packageVersion := packageWrapper.UpstreamYaml.PackageVersion; packageVersion != 0 { //nolint:all
@@ -463,6 +723,14 @@ func addAnnotations(packageWrapper pkg.PackageWrapper, helmChart *chart.Chart) e
 
 	conform.ApplyChartAnnotations(helmChart, annotations, false)
 
+	// fields such as RancherVersion take precedence over the upstream chart
+	conform.ApplyChartAnnotations(helmChart, packageWrapper.UpstreamYaml.CatalogAnnotations(), true)
+
+	// the upstream chart may set the kube-version annotation itself
+	if value, ok := helmChart.Metadata.Annotations[annotationKubeVersion]; ok && !packageWrapper.UpstreamYaml.PreserveKubeVersion {
+		helmChart.Metadata.Annotations[annotationKubeVersion] = conform.NormalizeKubeVersion(value)
+	}
+
 	return nil
 }
 
@@ -472,10 +740,14 @@ func addAnnotations(packageWrapper pkg.PackageWrapper, helmChart *chart.Chart) e
 // this function must consider and possibly modify all of the package's chart
 // versions.
 func ensureFeaturedAnnotation(existingCharts, newCharts []*ChartWrapper) error {
+	allCharts := make([]*ChartWrapper, 0, len(existingCharts)+len(newCharts))
+	allCharts = append(allCharts, existingCharts...)
+	allCharts = append(allCharts, newCharts...)
+
 	// get current value of featured annotation
 	featuredAnnotationValue := ""
-	for _, existingChart := range existingCharts {
-		val, ok := existingChart.Metadata.Annotations[annotationFeatured]
+	for _, chartWrapper := range allCharts {
+		val, ok := chartWrapper.Metadata.Annotations[annotationFeatured]
 		if !ok {
 			continue
 		}
@@ -489,26 +761,33 @@ func ensureFeaturedAnnotation(existingCharts, newCharts []*ChartWrapper) error {
 		return nil
 	}
 
-	// set featured annotation on last of new charts
-	// TODO: This replicates a bug in the existing code. Whichever ChartVersion
-	// comes last in the ChartVersions that conformPackage is working on has
-	// the featured annotation applies. This could easily give the wrong result, which
-	// presumably is for only the latest chart version to have the "featured"
-	// annotation.
-	// But in practice this is not a problem: as of the time of writing, only
-	// one chart (kasten/k10) uses a value for UpstreamYaml.Fetch other than the
-	// default value of "latest", and that chart is not featured.
-	lastNewChart := newCharts[len(newCharts)-1]
-	if conform.AnnotateChart(lastNewChart.Chart, annotationFeatured, featuredAnnotationValue, true) {
-		lastNewChart.Modified = true
+	// Find the highest chart version. Depending on UpstreamYaml.Fetch,
+	// this is not necessarily the last of the new charts, and may even be
+	// an existing chart.
+	var latestChart *ChartWrapper
+	var latestVersion *semver.Version
+	for _, chartWrapper := range allCharts {
+		version, err := semver.NewVersion(chartWrapper.Metadata.Version)
+		if err != nil {
+			return fmt.Errorf("failed to parse version %q of chart %q: %w", chartWrapper.Metadata.Version, chartWrapper.Name(), err)
+		}
+		if latestVersion == nil || version.GreaterThan(latestVersion) {
+			latestChart = chartWrapper
+			latestVersion = version
+		}
 	}
 
-	// Ensure featured annotation is not present on existing charts. We don't
-	// need to worry about other new charts because they will not have the
-	// featured annotation.
-	for _, existingChart := range existingCharts {
-		if conform.DeannotateChart(existingChart.Chart, annotationFeatured, "") {
-			existingChart.Modified = true
+	// set featured annotation on the latest chart, and ensure it is not
+	// present on any other chart
+	for _, chartWrapper := range allCharts {
+		var modified bool
+		if chartWrapper == latestChart {
+			modified = conform.AnnotateChart(chartWrapper.Chart, annotationFeatured, featuredAnnotationValue, true)
+		} else {
+			modified = conform.DeannotateChart(chartWrapper.Chart, annotationFeatured, "")
+		}
+		if modified {
+			chartWrapper.Modified = true
 		}
 	}
 
@@ -637,345 +916,2266 @@ func listPackages(c *cli.Context) error {
 	return nil
 }
 
-// addFeaturedChart adds the "featured" annotation to a chart.
-func addFeaturedChart(c *cli.Context) error {
-	if c.Args().Len() != 2 {
-		logrus.Fatalf("Please provide the chart name and featured number (1 - %d) as arguments\n", featuredMax)
-	}
-	featuredChart := c.Args().Get(0)
-	inputIndex := c.Args().Get(1)
-	featuredNumber, err := strconv.Atoi(inputIndex)
-	if err != nil {
-		return fmt.Errorf("failed to parse given index %q: %w", inputIndex, err)
+// statusFormats are the output formats of the status command.
+var statusFormats = []string{"table", "json", "markdown"}
+
+// packageStatus describes the state of a package for the status command.
+type packageStatus struct {
+	Package      string `json:"package"`
+	Source       string `json:"source"`
+	LatestStored string `json:"latestStored,omitempty"`
+	// LatestUpstream is empty if the upstream was not checked.
+	LatestUpstream string `json:"latestUpstream,omitempty"`
+	// VersionsBehind is the number of upstream versions that are newer
+	// than LatestStored.
+	VersionsBehind int    `json:"versionsBehind"`
+	Hidden         bool   `json:"hidden"`
+	Deprecated     bool   `json:"deprecated"`
+	Featured       string `json:"featured,omitempty"`
+	// LastIntegrated is the latest Created time of the package's chart
+	// versions in index.yaml.
+	LastIntegrated *time.Time `json:"lastIntegrated,omitempty"`
+	AgeDays        *int       `json:"ageDays,omitempty"`
+	Error          string     `json:"error,omitempty"`
+}
+
+// getPackageSource returns the type of upstream that upstreamYaml is
+// fetched from, using the same names as fetcher.FetchUpstream.
+func getPackageSource(upstreamYaml *upstreamyaml.UpstreamYaml) string {
+	switch {
+	case upstreamYaml.ArtifactHubRepo != "" && upstreamYaml.ArtifactHubPackage != "":
+		return "ArtifactHub"
+	case upstreamYaml.HelmRepo != "" && upstreamYaml.HelmChart != "" && strings.HasPrefix(upstreamYaml.HelmRepo, "oci://"):
+		return "OCI"
+	case upstreamYaml.HelmRepo != "" && upstreamYaml.HelmChart != "":
+		return "HelmRepo"
+	case upstreamYaml.GitRepo != "":
+		return "Git"
+	}
+	return ""
+}
+
+// getPackageStatus returns the status of packageWrapper that can be
+// determined without contacting its upstream. chartVersions are the
+// package's chart versions in index.yaml, sorted by descending version.
+func getPackageStatus(packageWrapper pkg.PackageWrapper, chartVersions repo.ChartVersions, now time.Time) packageStatus {
+	status := packageStatus{
+		Package:    packageWrapper.FullName(),
+		Source:     getPackageSource(packageWrapper.UpstreamYaml),
+		Deprecated: packageWrapper.UpstreamYaml.Deprecated,
 	}
-	if featuredNumber < 1 || featuredNumber > featuredMax {
-		return fmt.Errorf("featured number must be between %d and %d", 1, featuredMax)
+	if len(chartVersions) == 0 {
+		return status
 	}
 
-	paths, err := p.GetPaths()
-	if err != nil {
-		return fmt.Errorf("failed to get paths: %w", err)
+	latest := chartVersions[0]
+	status.LatestStored = latest.Version
+	status.Hidden = latest.Annotations[annotationHidden] == "true"
+	status.Featured = latest.Annotations[annotationFeatured]
+
+	var lastIntegrated time.Time
+	for _, chartVersion := range chartVersions {
+		if chartVersion.Created.After(lastIntegrated) {
+			lastIntegrated = chartVersion.Created
+		}
 	}
-	packageList, err := pkg.ListPackageWrappers(paths, featuredChart)
-	if err != nil {
-		return fmt.Errorf("failed to list packages: %w", err)
+	if !lastIntegrated.IsZero() {
+		ageDays := int(now.Sub(lastIntegrated).Hours() / 24)
+		status.LastIntegrated = &lastIntegrated
+		status.AgeDays = &ageDays
 	}
-	packageWrapper := packageList[0]
 
-	featuredVersions := getByAnnotation(paths, annotationFeatured, inputIndex)
-	if len(featuredVersions) > 0 {
-		for chartName := range featuredVersions {
-			logrus.Errorf("%s already featured at index %d\n", chartName, featuredNumber)
-		}
-	} else {
-		vendor := packageWrapper.Vendor
-		chartName := packageWrapper.Name
-		paths, err := p.GetPaths()
+	return status
+}
+
+// setUpstreamStatus sets the fields of status that come from the
+// package's upstream versions. Pre-release versions are ignored, as they
+// are when updating.
+func setUpstreamStatus(status *packageStatus, upstreamVersions repo.ChartVersions) error {
+	var latestStored *semver.Version
+	if status.LatestStored != "" {
+		var err error
+		latestStored, err = semver.NewVersion(conform.StripPackageVersion(status.LatestStored))
 		if err != nil {
-			return fmt.Errorf("failed to get paths: %w", err)
+			return fmt.Errorf("failed to parse stored version %q: %w", status.LatestStored, err)
 		}
-		if err := annotate(paths, vendor, chartName, annotationFeatured, inputIndex, false, true); err != nil {
-			return fmt.Errorf("failed to annotate %q: %w", packageWrapper.FullName(), err)
+	}
+
+	var latestUpstream *semver.Version
+	status.VersionsBehind = 0
+	for _, upstreamVersion := range upstreamVersions {
+		version, err := semver.NewVersion(upstreamVersion.Version)
+		if err != nil || version.Prerelease() != "" {
+			continue
 		}
-		if err := writeIndex(paths); err != nil {
-			return fmt.Errorf("failed to write index: %w", err)
+		if latestUpstream == nil || version.GreaterThan(latestUpstream) {
+			latestUpstream = version
+			status.LatestUpstream = upstreamVersion.Version
+		}
+		if latestStored == nil || version.GreaterThan(latestStored) {
+			status.VersionsBehind++
 		}
 	}
-
 	return nil
 }
 
-// removeFeaturedChart removes the "featured" annotation from a chart.
-func removeFeaturedChart(c *cli.Context) error {
-	if c.Args().Len() != 1 {
-		logrus.Fatal("Please provide the chart name as argument")
+// showStatus prints the status of each package. Unless --offline is
+// passed, the upstream of each package that is not deprecated is checked
+// for new versions, which requires network access. Like many other
+// subcommands, the PACKAGE environment variable can be used to work on a
+// single package.
+func showStatus(c *cli.Context) error {
+	if !slices.Contains(statusFormats, statusFormat) {
+		return fmt.Errorf("unknown format %q; must be one of %s", statusFormat, strings.Join(statusFormats, ", "))
 	}
-	featuredChart := c.Args().Get(0)
+	currentPackage := os.Getenv(packageEnvVariable)
 	paths, err := p.GetPaths()
 	if err != nil {
 		return fmt.Errorf("failed to get paths: %w", err)
 	}
-
-	packageList, err := pkg.ListPackageWrappers(paths, featuredChart)
+	packageWrappers, err := pkg.ListPackageWrappers(paths, currentPackage)
 	if err != nil {
 		return fmt.Errorf("failed to list packages: %w", err)
 	}
-	packageWrapper := packageList[0]
-
-	vendor := packageWrapper.Vendor
-	chartName := packageWrapper.Name
-	if err := annotate(paths, vendor, chartName, annotationFeatured, "", true, false); err != nil {
-		return fmt.Errorf("failed to deannotate %q: %w", packageWrapper.FullName(), err)
+	sortPackageWrappers(packageWrappers)
+	indexYaml, err := repo.LoadIndexFile(paths.IndexYaml)
+	if err != nil {
+		return fmt.Errorf("failed to load index.yaml: %w", err)
 	}
 
-	if err := writeIndex(paths); err != nil {
-		return fmt.Errorf("failed to write index: %w", err)
+	now := time.Now()
+	statuses := make([]packageStatus, 0, len(packageWrappers))
+	for _, packageWrapper := range packageWrappers {
+		status := getPackageStatus(packageWrapper, indexYaml.Entries[packageWrapper.Name], now)
+		if !statusOffline && !status.Deprecated {
+			if _, err := packageWrapper.Populate(paths); err != nil {
+				logrus.Errorf("failed to populate %s: %s", packageWrapper.FullName(), err)
+				status.Error = err.Error()
+			} else {
+				status.Source = packageWrapper.SourceMetadata.Source
+				if err := setUpstreamStatus(&status, packageWrapper.SourceMetadata.Versions); err != nil {
+					logrus.Errorf("failed to get upstream status of %s: %s", packageWrapper.FullName(), err)
+					status.Error = err.Error()
+				}
+			}
+		}
+		statuses = append(statuses, status)
 	}
 
-	return nil
+	return writeStatus(os.Stdout, statusFormat, statuses)
 }
 
-func listFeaturedCharts(c *cli.Context) error {
-	indexConflict := false
-	featuredSorted := make([]string, featuredMax)
-	paths, err := p.GetPaths()
-	if err != nil {
-		logrus.Fatalf("failed to get paths: %s", err)
+// writeStatus writes statuses to w in format, which is one of
+// statusFormats.
+func writeStatus(w io.Writer, format string, statuses []packageStatus) error {
+	if format == "json" {
+		output, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal status to JSON: %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(output))
+		return err
 	}
-	featuredVersions := getByAnnotation(paths, annotationFeatured, "")
 
-	for chartName, chartVersion := range featuredVersions {
-		featuredIndex, err := strconv.Atoi(chartVersion[0].Annotations[annotationFeatured])
-		if err != nil {
-			logrus.Fatal(err)
+	headers := []string{"PACKAGE", "SOURCE", "STORED", "UPSTREAM", "BEHIND", "HIDDEN", "DEPRECATED", "FEATURED", "LAST INTEGRATED"}
+	rows := make([][]string, 0, len(statuses))
+	for _, status := range statuses {
+		upstream, behind := "-", "-"
+		if status.Error != "" {
+			upstream, behind = "error", "?"
+		} else if status.LatestUpstream != "" {
+			upstream, behind = status.LatestUpstream, strconv.Itoa(status.VersionsBehind)
 		}
-		featuredIndex--
-		if featuredSorted[featuredIndex] != "" {
-			indexConflict = true
-			featuredSorted[featuredIndex] += fmt.Sprintf(", %s", chartName)
-		} else {
-			featuredSorted[featuredIndex] = chartName
+		lastIntegrated := "-"
+		if status.AgeDays != nil {
+			lastIntegrated = fmt.Sprintf("%s (%dd ago)", status.LastIntegrated.Format(time.DateOnly), *status.AgeDays)
+		}
+		rows = append(rows, []string{
+			status.Package,
+			valueOrDash(status.Source),
+			valueOrDash(status.LatestStored),
+			upstream,
+			behind,
+			strconv.FormatBool(status.Hidden),
+			strconv.FormatBool(status.Deprecated),
+			valueOrDash(status.Featured),
+			lastIntegrated,
+		})
+	}
+
+	if format == "markdown" {
+		titles := make([]string, 0, len(headers))
+		separators := make([]string, 0, len(headers))
+		for _, header := range headers {
+			titles = append(titles, strings.ToUpper(header[:1])+strings.ToLower(header[1:]))
+			separators = append(separators, "---")
+		}
+		fmt.Fprintf(w, "| %s |\n", strings.Join(titles, " | "))
+		fmt.Fprintf(w, "| %s |\n", strings.Join(separators, " | "))
+		for _, row := range rows {
+			if _, err := fmt.Fprintf(w, "| %s |\n", strings.Join(row, " | ")); err != nil {
+				return err
+			}
 		}
+		return nil
 	}
-	if indexConflict {
-		logrus.Errorf("Multiple charts given same featured index")
+
+	tabWriter := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tabWriter, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tabWriter, strings.Join(row, "\t"))
 	}
+	return tabWriter.Flush()
+}
 
-	for i, chartName := range featuredSorted {
-		if featuredSorted[i] != "" {
-			fmt.Printf("%d: %s\n", i+1, chartName)
-		}
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
 	}
+	return value
+}
 
-	return nil
+// staleState is the contents of stale.yaml, which records what previous
+// runs of the stale command found out about the upstream of each
+// package, keyed by full package name.
+type staleState struct {
+	Packages map[string]*upstreamHistory `json:"packages"`
 }
 
-// hideChart ensures each released version of a package has the "hidden"
-// annotation set to "true". This hides the package in the Rancher UI.
-func hideChart(c *cli.Context) error {
-	if c.Args().Len() != 1 {
-		logrus.Fatal("Must provide exactly one package name as argument")
+// upstreamHistory is what the stale command has found out about the
+// upstream of a package.
+type upstreamHistory struct {
+	// ConsecutiveFailures is the number of runs in a row in which the
+	// upstream could not be fetched.
+	ConsecutiveFailures int        `json:"consecutiveFailures,omitempty"`
+	LastError           string     `json:"lastError,omitempty"`
+	LastSuccess         *time.Time `json:"lastSuccess,omitempty"`
+	LatestVersion       string     `json:"latestVersion,omitempty"`
+	// LatestRelease is when LatestVersion was released. Only Helm
+	// repositories publish this, so for other upstreams it is when
+	// LatestVersion was first seen.
+	LatestRelease *time.Time `json:"latestRelease,omitempty"`
+}
+
+// stalePackage is a package that the stale command reports.
+type stalePackage struct {
+	Package string   `json:"package"`
+	Reasons []string `json:"reasons"`
+}
+
+func readStaleState(staleYamlPath string) (staleState, error) {
+	state := staleState{}
+	contents, err := os.ReadFile(staleYamlPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return staleState{Packages: map[string]*upstreamHistory{}}, nil
+	} else if err != nil {
+		return staleState{}, fmt.Errorf("failed to read %s: %w", staleYamlPath, err)
 	}
-	currentPackage := c.Args().Get(0)
-	paths, err := p.GetPaths()
-	if err != nil {
-		return fmt.Errorf("failed to get paths: %w", err)
+	if err := yaml.UnmarshalStrict(contents, &state); err != nil {
+		return staleState{}, fmt.Errorf("failed to parse %s: %w", staleYamlPath, err)
+	}
+	if state.Packages == nil {
+		state.Packages = map[string]*upstreamHistory{}
 	}
+	return state, nil
+}
 
-	packageWrappers, err := pkg.ListPackageWrappers(paths, currentPackage)
+func writeStaleState(staleYamlPath string, state staleState) error {
+	contents, err := yaml.Marshal(state)
 	if err != nil {
-		return fmt.Errorf("failed to list packages: %w", err)
+		return fmt.Errorf("failed to marshal stale state: %w", err)
 	}
-	packageWrapper := packageWrappers[0]
+	if err := os.WriteFile(staleYamlPath, contents, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", staleYamlPath, err)
+	}
+	return nil
+}
 
-	// set Hidden: true in upstream.yaml
-	packageWrapper.UpstreamYaml.Hidden = true
-	upstreamYamlPath := filepath.Join(packageWrapper.Path, upstreamYamlFile)
-	if err := upstreamyaml.Write(upstreamYamlPath, packageWrapper.UpstreamYaml); err != nil {
-		return fmt.Errorf("failed to write upstream.yaml: %w", err)
+// recordUpstreamResult updates history with the result of fetching the
+// upstream at now. upstreamVersions are the fetched versions, and
+// fetchErr is the error from fetching them.
+func recordUpstreamResult(history *upstreamHistory, upstreamVersions repo.ChartVersions, fetchErr error, now time.Time) {
+	if fetchErr != nil {
+		history.ConsecutiveFailures++
+		history.LastError = fetchErr.Error()
+		return
+	}
+	history.ConsecutiveFailures = 0
+	history.LastError = ""
+	history.LastSuccess = &now
+
+	var latest *repo.ChartVersion
+	var latestVersion *semver.Version
+	for _, upstreamVersion := range upstreamVersions {
+		version, err := semver.NewVersion(upstreamVersion.Version)
+		if err != nil || version.Prerelease() != "" {
+			continue
+		}
+		if latestVersion == nil || version.GreaterThan(latestVersion) {
+			latest = upstreamVersion
+			latestVersion = version
+		}
+	}
+	if latest == nil {
+		return
 	}
+	if !latest.Created.IsZero() {
+		created := latest.Created
+		history.LatestRelease = &created
+	} else if latest.Version != history.LatestVersion || history.LatestRelease == nil {
+		history.LatestRelease = &now
+	}
+	history.LatestVersion = latest.Version
+}
 
-	vendor := packageWrapper.Vendor
-	chartName := packageWrapper.Name
-	if err := annotate(paths, vendor, chartName, annotationHidden, "true", false, false); err != nil {
-		return fmt.Errorf("failed to annotate package: %w", err)
+// upstreamResult is the result of fetching the upstream of a package.
+type upstreamResult struct {
+	upstreamVersions repo.ChartVersions
+	err              error
+}
+
+// recordUpstreamResults records results, keyed by full package name, in
+// the stale state at staleYamlPath, so that fetch failures in update count
+// towards the consecutive failures reported by the stale command.
+func recordUpstreamResults(staleYamlPath string, results map[string]upstreamResult, now time.Time) error {
+	state, err := readStaleState(staleYamlPath)
+	if err != nil {
+		return err
 	}
-	if err := writeIndex(paths); err != nil {
-		return fmt.Errorf("failed to write index: %w", err)
+	for fullName, result := range results {
+		history, ok := state.Packages[fullName]
+		if !ok {
+			history = &upstreamHistory{}
+			state.Packages[fullName] = history
+		}
+		recordUpstreamResult(history, result.upstreamVersions, result.err, now)
 	}
+	return writeStaleState(staleYamlPath, state)
+}
 
-	return nil
+// getStaleReasons returns why the package with history is stale: its
+// upstream has failed in at least maxFailures runs in a row, or it has
+// not released in more than maxDays days. It returns nil if the package
+// is not stale.
+func getStaleReasons(history upstreamHistory, maxFailures, maxDays int, now time.Time) []string {
+	var reasons []string
+	if history.ConsecutiveFailures >= maxFailures {
+		reasons = append(reasons, fmt.Sprintf("upstream could not be fetched in %d runs in a row: %s", history.ConsecutiveFailures, history.LastError))
+	}
+	if history.LatestRelease != nil {
+		days := int(now.Sub(*history.LatestRelease).Hours() / 24)
+		if days > maxDays {
+			reasons = append(reasons, fmt.Sprintf("latest upstream version %s was released %d days ago", history.LatestVersion, days))
+		}
+	}
+	return reasons
 }
 
-// CLI function call - Generates automated commit
-func autoUpdate(c *cli.Context) error {
+// reportStalePackages fetches the upstream of each package that is not
+// deprecated, records the result in stale.yaml, and prints the packages
+// whose upstream is unreachable or abandoned. These are candidates for
+// deprecation. Like many other subcommands, the PACKAGE environment
+// variable can be used to work on a single package.
+func reportStalePackages(c *cli.Context) error {
+	if staleFailures < 1 {
+		return errors.New("--failures must be at least 1")
+	}
+	if staleDays < 1 {
+		return errors.New("--days must be at least 1")
+	}
 	currentPackage := os.Getenv(packageEnvVariable)
 	paths, err := p.GetPaths()
 	if err != nil {
-		logrus.Fatalf("failed to get paths: %s", err)
+		return fmt.Errorf("failed to get paths: %w", err)
 	}
 	packageWrappers, err := pkg.ListPackageWrappers(paths, currentPackage)
 	if err != nil {
-		logrus.Fatalf("failed to list packages: %s", err)
+		return fmt.Errorf("failed to list packages: %w", err)
+	}
+	sortPackageWrappers(packageWrappers)
+	state, err := readStaleState(paths.StaleYaml)
+	if err != nil {
+		return err
 	}
 
-	updatablePackageWrappers := make([]pkg.PackageWrapper, 0, len(packageWrappers))
+	now := time.Now().UTC().Truncate(time.Second)
+	checkedPackages := map[string]bool{}
+	stalePackages := make([]stalePackage, 0)
 	for _, packageWrapper := range packageWrappers {
 		if packageWrapper.UpstreamYaml.Deprecated {
-			logrus.Warnf("Package %s is deprecated; skipping update", packageWrapper.FullName())
 			continue
 		}
+		fullName := packageWrapper.FullName()
+		checkedPackages[fullName] = true
+		history, ok := state.Packages[fullName]
+		if !ok {
+			history = &upstreamHistory{}
+			state.Packages[fullName] = history
+		}
 
-		updatable, err := packageWrapper.Populate(paths)
+		sourceMetadata, err := fetcher.FetchUpstream(*packageWrapper.UpstreamYaml)
 		if err != nil {
-			logrus.Errorf("failed to populate %s: %s", packageWrapper.FullName(), err)
+			logrus.Warnf("failed to fetch upstream of %s: %s", fullName, err)
+		}
+		recordUpstreamResult(history, sourceMetadata.Versions, err, now)
+		if reasons := getStaleReasons(*history, staleFailures, staleDays, now); len(reasons) > 0 {
+			stalePackages = append(stalePackages, stalePackage{Package: fullName, Reasons: reasons})
+		}
+	}
+	// forget packages that have been removed or deprecated
+	if currentPackage == "" {
+		for fullName := range state.Packages {
+			if !checkedPackages[fullName] {
+				delete(state.Packages, fullName)
+			}
+		}
+	}
+	if err := writeStaleState(paths.StaleYaml, state); err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		output, err := json.MarshalIndent(stalePackages, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal stale packages to JSON: %w", err)
+		}
+		fmt.Println(string(output))
+		return nil
+	}
+	if len(stalePackages) == 0 {
+		logrus.Info("no stale packages")
+	}
+	for _, stalePackage := range stalePackages {
+		fmt.Printf("%s: %s\n", stalePackage.Package, strings.Join(stalePackage.Reasons, "; "))
+	}
+	return nil
+}
+
+// shownAnnotations are the annotations that the show command prints for
+// each chart version.
+var shownAnnotations = []string{
+	annotationAutoInstall,
+	annotationDisplayName,
+	annotationExperimental,
+	annotationFeatured,
+	annotationHidden,
+	annotationKubeVersion,
+	annotationNamespace,
+	annotationReleaseName,
+}
+
+// packageInfo is everything that the show command prints about a
+// package.
+type packageInfo struct {
+	Package      string                     `json:"package"`
+	Path         string                     `json:"path"`
+	UpstreamYaml *upstreamyaml.UpstreamYaml `json:"upstreamYaml"`
+	OverlayFiles []string                   `json:"overlayFiles"`
+	// Icon is empty if the package has no downloaded icon.
+	Icon     string             `json:"icon,omitempty"`
+	Versions []chartVersionInfo `json:"versions"`
+	Findings []validate.Finding `json:"findings"`
+}
+
+// chartVersionInfo describes a chart version in index.yaml.
+type chartVersionInfo struct {
+	Version     string            `json:"version"`
+	AppVersion  string            `json:"appVersion,omitempty"`
+	Created     time.Time         `json:"created"`
+	Deprecated  bool              `json:"deprecated,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// getPackageInfo collects what the show command prints about
+// packageWrapper, except for validation findings. chartVersions are the
+// package's chart versions in index.yaml.
+func getPackageInfo(paths p.Paths, packageWrapper pkg.PackageWrapper, chartVersions repo.ChartVersions) (packageInfo, error) {
+	info := packageInfo{
+		Package:      packageWrapper.FullName(),
+		Path:         packageWrapper.Path,
+		UpstreamYaml: packageWrapper.UpstreamYaml,
+		OverlayFiles: []string{},
+		Versions:     make([]chartVersionInfo, 0, len(chartVersions)),
+		Findings:     []validate.Finding{},
+	}
+
+	overlayFiles, err := packageWrapper.GetOverlayFiles()
+	if err != nil {
+		return packageInfo{}, fmt.Errorf("failed to get overlay files: %w", err)
+	}
+	for overlayFile := range overlayFiles {
+		info.OverlayFiles = append(info.OverlayFiles, filepath.ToSlash(overlayFile))
+	}
+	slices.Sort(info.OverlayFiles)
+
+	if iconPath, err := icons.GetDownloadedIconPath(paths, packageWrapper.Name); err != nil {
+		logrus.Debug(err)
+	} else {
+		info.Icon = iconPath
+	}
+
+	for _, chartVersion := range chartVersions {
+		versionInfo := chartVersionInfo{
+			Version:     chartVersion.Version,
+			AppVersion:  chartVersion.AppVersion,
+			Created:     chartVersion.Created,
+			Deprecated:  chartVersion.Deprecated,
+			Annotations: map[string]string{},
+		}
+		for _, annotation := range shownAnnotations {
+			if value, ok := chartVersion.Annotations[annotation]; ok {
+				versionInfo.Annotations[annotation] = value
+			}
+		}
+		info.Versions = append(info.Versions, versionInfo)
+	}
+
+	return info, nil
+}
+
+// isPackageFinding returns whether finding is about the package with
+// vendor and name. Findings about its icon or its chart archives may
+// not name the package, so they are matched by file.
+func isPackageFinding(finding validate.Finding, vendor, name string) bool {
+	if finding.Package == vendor+"/"+name {
+		return true
+	}
+	for _, directory := range []string{"packages", "charts"} {
+		if strings.HasPrefix(finding.File, path.Join(directory, vendor, name)+"/") {
+			return true
+		}
+	}
+	if iconFile, ok := strings.CutPrefix(finding.File, "assets/icons/"); ok {
+		return strings.TrimSuffix(iconFile, path.Ext(iconFile)) == name
+	}
+	if tgzFile, ok := strings.CutPrefix(finding.File, path.Join("assets", vendor, name)+"-"); ok {
+		_, err := semver.NewVersion(strings.TrimSuffix(tgzFile, ".tgz"))
+		return err == nil
+	}
+	return false
+}
+
+// showPackage prints what is shipped for a package: its upstream.yaml,
+// overlay files, icon, chart versions and validation findings.
+// Only the validations that check each package on its own are run, and
+// only on this package. Validations that compare against the validation
+// upstreams are not run, so no network access is needed.
+func showPackage(c *cli.Context) error {
+	if c.Args().Len() > 1 {
+		return errors.New("flags must come before the package name")
+	} else if c.Args().Len() != 1 {
+		return errors.New("must provide package name in <vendor>/<chart> format as argument")
+	}
+	fullName := c.Args().Get(0)
+	vendor, name, err := splitPackageName(fullName)
+	if err != nil {
+		return err
+	}
+	paths, err := p.GetPaths()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+	packageWrappers, err := pkg.ListPackageWrappers(paths, fullName)
+	if err != nil {
+		return fmt.Errorf("failed to list packages: %w", err)
+	}
+	indexYaml, err := repo.LoadIndexFile(paths.IndexYaml)
+	if err != nil {
+		return fmt.Errorf("failed to load index.yaml: %w", err)
+	}
+	info, err := getPackageInfo(paths, packageWrappers[0], indexYaml.Entries[name])
+	if err != nil {
+		return err
+	}
+
+	configYaml, err := validate.ReadConfig(paths.ConfigurationYaml)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration.yaml: %w", err)
+	}
+	options := validate.Options{Package: fullName}
+	for _, validation := range validate.List() {
+		if validation.RequiresUpstream {
+			options.Skip = append(options.Skip, validation.Name)
+		}
+	}
+	result, err := validate.Run(paths, configYaml, options)
+	if err != nil {
+		return fmt.Errorf("failed to run validations: %w", err)
+	}
+	for _, finding := range result.Findings {
+		if isPackageFinding(finding, vendor, name) {
+			info.Findings = append(info.Findings, finding)
+		}
+	}
+
+	if jsonOutput {
+		output, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal package info to JSON: %w", err)
+		}
+		fmt.Println(string(output))
+		return nil
+	}
+	return writePackageInfo(os.Stdout, info)
+}
+
+// writePackageInfo writes info to w in human-readable form.
+func writePackageInfo(w io.Writer, info packageInfo) error {
+	upstreamYaml, err := yaml.Marshal(info.UpstreamYaml)
+	if err != nil {
+		return fmt.Errorf("failed to marshal upstream.yaml: %w", err)
+	}
+
+	fmt.Fprintf(w, "Package: %s\n", info.Package)
+	fmt.Fprintf(w, "Path: %s\n", info.Path)
+	fmt.Fprintf(w, "Icon: %s\n", valueOrDash(info.Icon))
+
+	fmt.Fprintf(w, "\nupstream.yaml:\n")
+	for _, line := range strings.Split(strings.TrimSuffix(string(upstreamYaml), "\n"), "\n") {
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+
+	fmt.Fprintf(w, "\nOverlay files:\n")
+	if len(info.OverlayFiles) == 0 {
+		fmt.Fprintf(w, "  none\n")
+	}
+	for _, overlayFile := range info.OverlayFiles {
+		fmt.Fprintf(w, "  %s\n", overlayFile)
+	}
+
+	fmt.Fprintf(w, "\nVersions:\n")
+	if len(info.Versions) == 0 {
+		fmt.Fprintf(w, "  none\n")
+	} else {
+		tabWriter := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tabWriter, "  VERSION\tAPP VERSION\tCREATED\tDEPRECATED\tANNOTATIONS")
+		for _, version := range info.Versions {
+			annotations := make([]string, 0, len(version.Annotations))
+			for _, annotation := range shownAnnotations {
+				if value, ok := version.Annotations[annotation]; ok {
+					annotations = append(annotations, strings.TrimPrefix(annotation, "catalog.cattle.io/")+"="+value)
+				}
+			}
+			fmt.Fprintf(tabWriter, "  %s\t%s\t%s\t%t\t%s\n", version.Version, valueOrDash(version.AppVersion),
+				version.Created.Format(time.DateOnly), version.Deprecated, valueOrDash(strings.Join(annotations, ", ")))
+		}
+		if err := tabWriter.Flush(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(w, "\nFindings:\n")
+	if len(info.Findings) == 0 {
+		fmt.Fprintf(w, "  none\n")
+	}
+	for _, finding := range info.Findings {
+		prefix := ""
+		if finding.Severity == validate.SeverityWarning {
+			prefix = "warning: "
+		}
+		if _, err := fmt.Fprintf(w, "  %s%s\n", prefix, finding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addFeaturedChart adds the "featured" annotation to a chart.
+func addFeaturedChart(c *cli.Context) error {
+	if c.Args().Len() != 2 {
+		logrus.Fatalf("Please provide the chart name and featured number (1 - %d) as arguments\n", featuredMax)
+	}
+	featuredChart := c.Args().Get(0)
+	inputIndex := c.Args().Get(1)
+	featuredNumber, err := strconv.Atoi(inputIndex)
+	if err != nil {
+		return fmt.Errorf("failed to parse given index %q: %w", inputIndex, err)
+	}
+	if featuredNumber < 1 || featuredNumber > featuredMax {
+		return fmt.Errorf("featured number must be between %d and %d", 1, featuredMax)
+	}
+
+	paths, err := p.GetPaths()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+	packageList, err := pkg.ListPackageWrappers(paths, featuredChart)
+	if err != nil {
+		return fmt.Errorf("failed to list packages: %w", err)
+	}
+	packageWrapper := packageList[0]
+
+	featuredVersions := getByAnnotation(paths, annotationFeatured, inputIndex)
+	if len(featuredVersions) > 0 {
+		for chartName := range featuredVersions {
+			logrus.Errorf("%s already featured at index %d\n", chartName, featuredNumber)
+		}
+	} else {
+		vendor := packageWrapper.Vendor
+		chartName := packageWrapper.Name
+		paths, err := p.GetPaths()
+		if err != nil {
+			return fmt.Errorf("failed to get paths: %w", err)
+		}
+		if err := annotate(paths, vendor, chartName, annotationFeatured, inputIndex, false, true); err != nil {
+			return fmt.Errorf("failed to annotate %q: %w", packageWrapper.FullName(), err)
+		}
+		if err := writeIndex(paths); err != nil {
+			return fmt.Errorf("failed to write index: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// removeFeaturedChart removes the "featured" annotation from a chart.
+func removeFeaturedChart(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		logrus.Fatal("Please provide the chart name as argument")
+	}
+	featuredChart := c.Args().Get(0)
+	paths, err := p.GetPaths()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	packageList, err := pkg.ListPackageWrappers(paths, featuredChart)
+	if err != nil {
+		return fmt.Errorf("failed to list packages: %w", err)
+	}
+	packageWrapper := packageList[0]
+
+	vendor := packageWrapper.Vendor
+	chartName := packageWrapper.Name
+	if err := annotate(paths, vendor, chartName, annotationFeatured, "", true, false); err != nil {
+		return fmt.Errorf("failed to deannotate %q: %w", packageWrapper.FullName(), err)
+	}
+
+	if err := writeIndex(paths); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+
+	return nil
+}
+
+// featuredSlot is a featured slot and the packages that occupy it. More
+// than one package in a slot is a conflict.
+type featuredSlot struct {
+	Slot     int      `json:"slot"`
+	Packages []string `json:"packages"`
+	Conflict bool     `json:"conflict"`
+}
+
+// invalidFeaturedValue is a featured annotation whose value is not a
+// slot between 1 and featuredMax.
+type invalidFeaturedValue struct {
+	Package string `json:"package"`
+	Value   string `json:"value"`
+}
+
+// featuredListing describes the featured annotations in index.yaml.
+type featuredListing struct {
+	Slots   []featuredSlot         `json:"slots"`
+	Invalid []invalidFeaturedValue `json:"invalid"`
+}
+
+// getFeaturedListing reads the featured annotations from the latest
+// featured version of each chart in indexYaml. packageNames maps chart
+// names to full package names; charts without a package are listed by
+// chart name.
+func getFeaturedListing(indexYaml *repo.IndexFile, packageNames map[string]string) featuredListing {
+	slotToPackages := map[int][]string{}
+	listing := featuredListing{
+		Slots:   []featuredSlot{},
+		Invalid: []invalidFeaturedValue{},
+	}
+	for chartName, chartVersions := range indexYaml.Entries {
+		packageName, ok := packageNames[chartName]
+		if !ok {
+			packageName = chartName
+		}
+		for _, chartVersion := range chartVersions {
+			value, ok := chartVersion.Annotations[annotationFeatured]
+			if !ok {
+				continue
+			}
+			slot, err := strconv.Atoi(value)
+			if err != nil || slot < 1 || slot > featuredMax {
+				listing.Invalid = append(listing.Invalid, invalidFeaturedValue{Package: packageName, Value: value})
+			} else {
+				slotToPackages[slot] = append(slotToPackages[slot], packageName)
+			}
+			break
+		}
+	}
+
+	for slot := 1; slot <= featuredMax; slot++ {
+		packageNames, ok := slotToPackages[slot]
+		if !ok {
 			continue
 		}
+		slices.Sort(packageNames)
+		listing.Slots = append(listing.Slots, featuredSlot{
+			Slot:     slot,
+			Packages: packageNames,
+			Conflict: len(packageNames) > 1,
+		})
+	}
+	slices.SortFunc(listing.Invalid, func(a, b invalidFeaturedValue) int {
+		return strings.Compare(a.Package, b.Package)
+	})
 
-		if len(packageWrapper.FetchVersions) == 0 {
-			logrus.Infof("%s is up-to-date\n", packageWrapper.FullName())
+	return listing
+}
+
+// loadFeaturedListing returns the featured listing for the repository,
+// along with all of its packages keyed by full package name.
+func loadFeaturedListing(paths p.Paths) (featuredListing, map[string]pkg.PackageWrapper, error) {
+	indexYaml, err := repo.LoadIndexFile(paths.IndexYaml)
+	if err != nil {
+		return featuredListing{}, nil, fmt.Errorf("failed to load index.yaml: %w", err)
+	}
+	packageWrappers, err := pkg.ListPackageWrappers(paths, "")
+	if err != nil {
+		return featuredListing{}, nil, fmt.Errorf("failed to list packages: %w", err)
+	}
+	packageNames := make(map[string]string, len(packageWrappers))
+	fullNameToPackageWrapper := make(map[string]pkg.PackageWrapper, len(packageWrappers))
+	for _, packageWrapper := range packageWrappers {
+		packageNames[packageWrapper.Name] = packageWrapper.FullName()
+		fullNameToPackageWrapper[packageWrapper.FullName()] = packageWrapper
+	}
+	return getFeaturedListing(indexYaml, packageNames), fullNameToPackageWrapper, nil
+}
+
+// parseFeaturedSlots returns the slot of each package in packageNames,
+// which are ordered from slot 1 to slot featuredMax.
+func parseFeaturedSlots(packageNames []string) (map[string]int, error) {
+	if len(packageNames) > featuredMax {
+		return nil, fmt.Errorf("got %d packages but there are only %d featured slots", len(packageNames), featuredMax)
+	}
+	slots := make(map[string]int, len(packageNames))
+	for i, packageName := range packageNames {
+		if _, ok := slots[packageName]; ok {
+			return nil, fmt.Errorf("package %q is given more than once", packageName)
 		}
-		for _, version := range packageWrapper.FetchVersions {
-			logrus.Infof("\n  Package: %s\n  Source: %s\n  Version: %s\n  URL: %s\n",
-				packageWrapper.FullName(), packageWrapper.SourceMetadata.Source, version.Version, version.URLs[0])
+		slots[packageName] = i + 1
+	}
+	return slots, nil
+}
+
+// applyFeaturedSlots sets the featured annotation on the latest chart
+// version of each package in packageWrappers to the package's slot in
+// slots, and removes it from all other chart versions. Packages that
+// are not in slots are unfeatured. All charts are changed in memory
+// before any of them are written, and if writing fails, the packages
+// that were already written are restored, so that an error does not
+// leave only some of the packages changed.
+func applyFeaturedSlots(paths p.Paths, packageWrappers []pkg.PackageWrapper, slots map[string]int) error {
+	packageCharts := make([][]*ChartWrapper, 0, len(packageWrappers))
+	backups := make([]chartsBackup, 0, len(packageWrappers))
+	for _, packageWrapper := range packageWrappers {
+		backup, err := backupCharts(paths, packageWrapper.Vendor, packageWrapper.Name)
+		if err != nil {
+			return fmt.Errorf("failed to back up charts for %s: %w", packageWrapper.FullName(), err)
 		}
+		backups = append(backups, backup)
 
-		if updatable {
-			updatablePackageWrappers = append(updatablePackageWrappers, packageWrapper)
+		chartWrappers, err := loadExistingCharts(paths, packageWrapper.Vendor, packageWrapper.Name)
+		if err != nil {
+			return fmt.Errorf("failed to load existing charts for %s: %w", packageWrapper.FullName(), err)
+		}
+		slot := slots[packageWrapper.FullName()]
+		if slot != 0 && len(chartWrappers) == 0 {
+			return fmt.Errorf("cannot feature %s: it has no chart versions", packageWrapper.FullName())
+		}
+		for i, chartWrapper := range chartWrappers {
+			if slot != 0 && i == 0 {
+				chartWrapper.Modified = conform.AnnotateChart(chartWrapper.Chart, annotationFeatured, strconv.Itoa(slot), true)
+			} else {
+				chartWrapper.Modified = conform.DeannotateChart(chartWrapper.Chart, annotationFeatured, "")
+			}
+		}
+		packageCharts = append(packageCharts, chartWrappers)
+	}
+
+	// restore undoes the changes to the packages up to and including the
+	// one at index last
+	restore := func(err error, last int) error {
+		errs := []error{err}
+		for _, backup := range backups[:last+1] {
+			if restoreErr := backup.restore(paths); restoreErr != nil {
+				errs = append(errs, fmt.Errorf("failed to restore %s/%s: %w", backup.vendor, backup.chartName, restoreErr))
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	for i, packageWrapper := range packageWrappers {
+		if err := writeCharts(paths, packageWrapper.Vendor, packageWrapper.Name, packageCharts[i]); err != nil {
+			return restore(fmt.Errorf("failed to write charts for %s: %w", packageWrapper.FullName(), err), i)
+		}
+	}
+
+	if err := writeIndex(paths); err != nil {
+		return restore(fmt.Errorf("failed to write index: %w", err), len(packageWrappers)-1)
+	}
+
+	return nil
+}
+
+// setFeaturedCharts features the given packages in the given order,
+// and unfeatures all other packages.
+func setFeaturedCharts(c *cli.Context) error {
+	if c.Args().Len() == 0 {
+		return fmt.Errorf("must provide between 1 and %d packages as arguments", featuredMax)
+	}
+	slots, err := parseFeaturedSlots(c.Args().Slice())
+	if err != nil {
+		return err
+	}
+	paths, err := p.GetPaths()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+	listing, fullNameToPackageWrapper, err := loadFeaturedListing(paths)
+	if err != nil {
+		return err
+	}
+
+	packageWrappers := make([]pkg.PackageWrapper, 0, featuredMax)
+	for _, packageName := range c.Args().Slice() {
+		packageWrapper, ok := fullNameToPackageWrapper[packageName]
+		if !ok {
+			return fmt.Errorf("failed to find package %q", packageName)
+		}
+		packageWrappers = append(packageWrappers, packageWrapper)
+	}
+
+	// unfeature packages that are currently featured but are not given
+	currentlyFeatured := make([]string, 0)
+	for _, slot := range listing.Slots {
+		currentlyFeatured = append(currentlyFeatured, slot.Packages...)
+	}
+	for _, invalid := range listing.Invalid {
+		currentlyFeatured = append(currentlyFeatured, invalid.Package)
+	}
+	for _, packageName := range currentlyFeatured {
+		if _, ok := slots[packageName]; ok {
+			continue
+		}
+		packageWrapper, ok := fullNameToPackageWrapper[packageName]
+		if !ok {
+			logrus.Warnf("%s is featured in index.yaml but has no package; not unfeaturing it", packageName)
+			continue
+		}
+		packageWrappers = append(packageWrappers, packageWrapper)
+	}
+
+	return applyFeaturedSlots(paths, packageWrappers, slots)
+}
+
+// swapFeaturedCharts exchanges the featured slots of two packages. One
+// of the packages may be unfeatured, in which case it takes the slot of
+// the other package.
+func swapFeaturedCharts(c *cli.Context) error {
+	if c.Args().Len() != 2 {
+		return errors.New("must provide exactly two packages as arguments")
+	}
+	first := c.Args().Get(0)
+	second := c.Args().Get(1)
+	if first == second {
+		return errors.New("cannot swap a package with itself")
+	}
+	paths, err := p.GetPaths()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+	listing, fullNameToPackageWrapper, err := loadFeaturedListing(paths)
+	if err != nil {
+		return err
+	}
+
+	currentSlots := map[string]int{}
+	for _, slot := range listing.Slots {
+		for _, packageName := range slot.Packages {
+			currentSlots[packageName] = slot.Slot
+		}
+	}
+	packageWrappers := make([]pkg.PackageWrapper, 0, 2)
+	for _, packageName := range []string{first, second} {
+		packageWrapper, ok := fullNameToPackageWrapper[packageName]
+		if !ok {
+			return fmt.Errorf("failed to find package %q", packageName)
+		}
+		packageWrappers = append(packageWrappers, packageWrapper)
+	}
+	if currentSlots[first] == 0 && currentSlots[second] == 0 {
+		return fmt.Errorf("neither %s nor %s is featured", first, second)
+	}
+
+	slots := map[string]int{
+		first:  currentSlots[second],
+		second: currentSlots[first],
+	}
+	return applyFeaturedSlots(paths, packageWrappers, slots)
+}
+
+// listFeaturedCharts prints the featured packages by slot. Conflicts and
+// invalid featured annotations are reported rather than treated as errors.
+func listFeaturedCharts(c *cli.Context) error {
+	paths, err := p.GetPaths()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+	listing, _, err := loadFeaturedListing(paths)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		output, err := json.MarshalIndent(listing, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal featured charts to JSON: %w", err)
+		}
+		fmt.Println(string(output))
+		return nil
+	}
+
+	for _, slot := range listing.Slots {
+		if slot.Conflict {
+			logrus.Errorf("Multiple charts given featured index %d", slot.Slot)
+		}
+		fmt.Printf("%d: %s\n", slot.Slot, strings.Join(slot.Packages, ", "))
+	}
+	for _, invalid := range listing.Invalid {
+		logrus.Errorf("%s has invalid featured annotation value %q", invalid.Package, invalid.Value)
+	}
+
+	return nil
+}
+
+// annotatableAnnotations are the annotations that the annotate command
+// may change. They are catalog.cattle.io annotations, which may be
+// changed in released chart versions, that are not managed by another
+// command. Each may also be set by a field of upstream.yaml, in which
+// case annotate refuses to change it for that package.
+var annotatableAnnotations = []string{
+	"catalog.cattle.io/os",
+	"catalog.cattle.io/permits-os",
+	"catalog.cattle.io/provides-gvr",
+	"catalog.cattle.io/rancher-version",
+	"catalog.cattle.io/requests-cpu",
+	"catalog.cattle.io/requests-memory",
+	"catalog.cattle.io/scope",
+	"catalog.cattle.io/type",
+	"catalog.cattle.io/ui-component",
+}
+
+// parseAnnotationArg parses an annotate argument of the form
+// <key>=<value>, or <key> if valueOptional is true. The
+// catalog.cattle.io/ prefix of the key may be omitted. It returns an
+// error if the annotation is not in annotatableAnnotations.
+func parseAnnotationArg(arg string, valueOptional bool) (annotation, value string, err error) {
+	annotation, value, hasValue := strings.Cut(arg, "=")
+	if annotation == "" || (!hasValue && !valueOptional) {
+		return "", "", fmt.Errorf("annotation %q is not in <key>=<value> format", arg)
+	}
+	if !strings.Contains(annotation, "/") {
+		annotation = "catalog.cattle.io/" + annotation
+	}
+	if !slices.Contains(annotatableAnnotations, annotation) || !validate.IsIgnoredAnnotation(annotation) {
+		return "", "", fmt.Errorf("annotation %s cannot be changed with annotate; must be one of %s", annotation, strings.Join(annotatableAnnotations, ", "))
+	}
+	if value == "" && !valueOptional {
+		return "", "", fmt.Errorf("annotation %s must have a value", annotation)
+	}
+	return annotation, value, nil
+}
+
+// annotatePackage sets or removes an annotation on the stored chart
+// versions of a package and regenerates index.yaml.
+func annotatePackage(c *cli.Context) error {
+	if c.Args().Len() > 2 {
+		return errors.New("flags must come before the package name and annotation")
+	} else if c.Args().Len() != 2 {
+		return errors.New("must provide package name and annotation in <key>=<value> format as arguments")
+	}
+	if _, _, err := splitPackageName(c.Args().Get(0)); err != nil {
+		return err
+	}
+	annotation, value, err := parseAnnotationArg(c.Args().Get(1), annotateRemove)
+	if err != nil {
+		return err
+	}
+	paths, err := p.GetPaths()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+	return annotateChartVersions(paths, c.Args().Get(0), annotation, value, annotateRemove, annotateLatestOnly)
+}
+
+// annotateChartVersions does the work of annotatePackage for package
+// currentPackage. Values that are set are checked with the same rules as
+// the upstream.yaml field that sets annotation.
+func annotateChartVersions(paths p.Paths, currentPackage, annotation, value string, remove, latestOnly bool) error {
+	if !remove {
+		if err := upstreamyaml.ValidateCatalogAnnotation(annotation, value); err != nil {
+			return fmt.Errorf("invalid annotation value: %w", err)
+		}
+	}
+	packageWrappers, err := pkg.ListPackageWrappers(paths, currentPackage)
+	if err != nil {
+		return fmt.Errorf("failed to list packages: %w", err)
+	}
+	packageWrapper := packageWrappers[0]
+	if upstreamYaml := packageWrapper.UpstreamYaml; upstreamYaml != nil {
+		if _, ok := upstreamYaml.CatalogAnnotations()[annotation]; ok {
+			return fmt.Errorf("%s is set by %s in upstream.yaml; change it there and run validate --fix", annotation, upstreamyaml.CatalogAnnotationField(annotation))
+		}
+	}
+
+	vendor := packageWrapper.Vendor
+	chartName := packageWrapper.Name
+	existingCharts, err := loadExistingCharts(paths, vendor, chartName)
+	if err != nil {
+		return fmt.Errorf("failed to load existing charts: %w", err)
+	}
+	if len(existingCharts) == 0 {
+		return fmt.Errorf("%s has no chart versions", packageWrapper.FullName())
+	}
+
+	if err := annotate(paths, vendor, chartName, annotation, value, remove, latestOnly); err != nil {
+		return fmt.Errorf("failed to annotate package: %w", err)
+	}
+	if err := writeIndex(paths); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+
+	return nil
+}
+
+// hideChart ensures each released version of a package has the "hidden"
+// annotation set to "true". This hides the package in the Rancher UI.
+func hideChart(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		logrus.Fatal("Must provide exactly one package name as argument")
+	}
+	currentPackage := c.Args().Get(0)
+	paths, err := p.GetPaths()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	packageWrappers, err := pkg.ListPackageWrappers(paths, currentPackage)
+	if err != nil {
+		return fmt.Errorf("failed to list packages: %w", err)
+	}
+	packageWrapper := packageWrappers[0]
+
+	// set Hidden: true in upstream.yaml
+	packageWrapper.UpstreamYaml.Hidden = true
+	upstreamYamlPath := filepath.Join(packageWrapper.Path, upstreamYamlFile)
+	if err := upstreamyaml.Write(upstreamYamlPath, packageWrapper.UpstreamYaml); err != nil {
+		return fmt.Errorf("failed to write upstream.yaml: %w", err)
+	}
+
+	vendor := packageWrapper.Vendor
+	chartName := packageWrapper.Name
+	if err := annotate(paths, vendor, chartName, annotationHidden, "true", false, false); err != nil {
+		return fmt.Errorf("failed to annotate package: %w", err)
+	}
+	if err := writeIndex(paths); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+
+	return nil
+}
+
+// unhideChart reverses hideChart. It sets Hidden to false in the package's
+// upstream.yaml and removes the "hidden" annotation from each released
+// version of the package.
+func unhideChart(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return errors.New("must provide exactly one package name as argument")
+	}
+	paths, err := p.GetPaths()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+	return unhidePackage(paths, c.Args().Get(0))
+}
+
+// unhidePackage does the work of unhideChart for package currentPackage.
+func unhidePackage(paths p.Paths, currentPackage string) error {
+	packageWrappers, err := pkg.ListPackageWrappers(paths, currentPackage)
+	if err != nil {
+		return fmt.Errorf("failed to list packages: %w", err)
+	}
+	packageWrapper := packageWrappers[0]
+
+	// set Hidden: false in upstream.yaml
+	packageWrapper.UpstreamYaml.Hidden = false
+	upstreamYamlPath := filepath.Join(packageWrapper.Path, upstreamYamlFile)
+	if err := upstreamyaml.Write(upstreamYamlPath, packageWrapper.UpstreamYaml); err != nil {
+		return fmt.Errorf("failed to write upstream.yaml: %w", err)
+	}
+
+	vendor := packageWrapper.Vendor
+	chartName := packageWrapper.Name
+	if err := annotate(paths, vendor, chartName, annotationHidden, "", true, false); err != nil {
+		return fmt.Errorf("failed to deannotate package: %w", err)
+	}
+	if err := writeIndex(paths); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+
+	return nil
+}
+
+// CLI function call - Generates automated commit
+func autoUpdate(c *cli.Context) error {
+	currentPackage := os.Getenv(packageEnvVariable)
+	paths, err := p.GetPaths()
+	if err != nil {
+		logrus.Fatalf("failed to get paths: %s", err)
+	}
+	packageWrappers, err := pkg.ListPackageWrappers(paths, currentPackage)
+	if err != nil {
+		logrus.Fatalf("failed to list packages: %s", err)
+	}
+
+	updatablePackageWrappers := make([]pkg.PackageWrapper, 0, len(packageWrappers))
+	upstreamResults := map[string]upstreamResult{}
+	for _, packageWrapper := range packageWrappers {
+		if packageWrapper.UpstreamYaml.Deprecated {
+			logrus.Warnf("Package %s is deprecated; skipping update", packageWrapper.FullName())
+			continue
+		}
+
+		updatable, err := packageWrapper.Populate(paths)
+		// Populate only sets SourceMetadata once the upstream is fetched
+		if packageWrapper.SourceMetadata == nil {
+			upstreamResults[packageWrapper.FullName()] = upstreamResult{err: err}
+		} else {
+			upstreamResults[packageWrapper.FullName()] = upstreamResult{upstreamVersions: packageWrapper.SourceMetadata.Versions}
+		}
+		if err != nil {
+			logrus.Errorf("failed to populate %s: %s", packageWrapper.FullName(), err)
+			continue
+		}
+
+		if len(packageWrapper.FetchVersions) == 0 {
+			logrus.Infof("%s is up-to-date\n", packageWrapper.FullName())
+		}
+		for _, version := range packageWrapper.FetchVersions {
+			logrus.Infof("\n  Package: %s\n  Source: %s\n  Version: %s\n  URL: %s\n",
+				packageWrapper.FullName(), packageWrapper.SourceMetadata.Source, version.Version, version.URLs[0])
+		}
+
+		if updatable {
+			updatablePackageWrappers = append(updatablePackageWrappers, packageWrapper)
+		}
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := recordUpstreamResults(paths.StaleYaml, upstreamResults, now); err != nil {
+		logrus.Fatalf("failed to record upstream results: %s", err)
+	}
+
+	if len(updatablePackageWrappers) == 0 {
+		return nil
+	}
+
+	updatedPackageWrappers := make([]pkg.PackageWrapper, 0, len(updatablePackageWrappers))
+	skippedList := make([]string, 0, len(updatablePackageWrappers))
+	for _, packageWrapper := range updatablePackageWrappers {
+		integratedVersions, err := ApplyUpdates(paths, packageWrapper)
+		if err != nil {
+			logrus.Errorf("failed to apply updates for chart %q: %s", packageWrapper.Name, err)
+			skippedList = append(skippedList, packageWrapper.Name)
+		} else if len(integratedVersions) == 0 {
+			logrus.Warnf("All new chart versions of %s were rejected", packageWrapper.FullName())
+		} else {
+			// only the integrated versions go in the commit message
+			packageWrapper.FetchVersions = integratedVersions
+			updatedPackageWrappers = append(updatedPackageWrappers, packageWrapper)
+		}
+	}
+	if len(skippedList) >= len(updatablePackageWrappers) {
+		logrus.Fatal("All packages skipped. Exiting...")
+	}
+	if len(skippedList) > 0 {
+		logrus.Errorf("Skipped due to error: %v", skippedList)
+	}
+
+	if err := writeIndex(paths); err != nil {
+		logrus.Fatalf("failed to write index.yaml: %s", err)
+	}
+
+	if makeCommit && len(updatedPackageWrappers) > 0 {
+		if err := commitChanges(paths, updatedPackageWrappers); err != nil {
+			logrus.Fatalf("failed to commit changes: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// CLI function call - Validates repo against released
+func validateRepo(c *cli.Context) error {
+	paths, err := p.GetPaths()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+	if validateList {
+		return listValidations(paths)
+	}
+	if validateFix {
+		if err := fixAnnotationMismatches(paths, os.Getenv(packageEnvVariable)); err != nil {
+			return fmt.Errorf("failed to fix annotations: %w", err)
+		}
+	}
+	options := validate.Options{
+		Only: validateOnly.Value(),
+		Skip: validateSkip.Value(),
+		Base: validateBase,
+	}
+	return runValidations(paths, options, validate.Format(validateFormat))
+}
+
+// fixAnnotationMismatches makes the released chart versions of each
+// package reflect its upstream.yaml again, and prints each fix it makes.
+// If currentPackage is not empty, only that package is fixed.
+func fixAnnotationMismatches(paths p.Paths, currentPackage string) error {
+	mismatches, err := validate.GetAnnotationMismatches(paths, currentPackage)
+	if err != nil {
+		return err
+	}
+	if len(mismatches) == 0 {
+		return nil
+	}
+
+	// A fix applies to all of a package's chart versions, or to its
+	// latest chart version, so there is no need to apply it once for
+	// each chart version.
+	type fix struct {
+		Vendor     string
+		Chart      string
+		Key        string
+		Value      string
+		LatestOnly bool
+	}
+	applied := map[fix]bool{}
+	for _, mismatch := range mismatches {
+		fmt.Printf("fixing: %s\n", mismatch)
+		currentFix := fix{
+			Vendor:     mismatch.Vendor,
+			Chart:      mismatch.Chart,
+			Key:        mismatch.Key,
+			Value:      mismatch.Expected,
+			LatestOnly: mismatch.LatestOnly,
+		}
+		if applied[currentFix] {
+			continue
+		}
+		applied[currentFix] = true
+		if mismatch.Key == validate.DeprecatedField {
+			err = setChartsDeprecated(paths, mismatch.Vendor, mismatch.Chart, true)
+		} else {
+			err = annotate(paths, mismatch.Vendor, mismatch.Chart, mismatch.Key, mismatch.Expected, false, mismatch.LatestOnly)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fix %s/%s: %w", mismatch.Vendor, mismatch.Chart, err)
+		}
+	}
+
+	if err := writeIndex(paths); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return nil
+}
+
+// runValidations runs the validations selected by options on the
+// repository and writes what they find to stdout in the passed format.
+// Only findings with error severity cause it to return an error.
+func runValidations(paths p.Paths, options validate.Options, format validate.Format) error {
+	if !slices.Contains(validate.Formats, format) {
+		return fmt.Errorf("unknown format %q; must be one of %v", format, validate.Formats)
+	}
+	configYaml, err := validate.ReadConfig(paths.ConfigurationYaml)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration.yaml: %w", err)
+	}
+
+	result, err := validate.Run(paths, configYaml, options)
+	if err != nil {
+		return fmt.Errorf("failed to run validations: %w", err)
+	}
+	if err := validate.WriteReport(os.Stdout, format, result); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if len(result.Errors()) > 0 {
+		return errors.New("validation failed")
+	}
+
+	return nil
+}
+
+// listValidations prints the name, severity and description of each
+// validation, and whether it is enabled in configuration.yaml.
+func listValidations(paths p.Paths) error {
+	configYaml, err := validate.ReadConfig(paths.ConfigurationYaml)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration.yaml: %w", err)
+	}
+
+	tabWriter := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tabWriter, "NAME\tSEVERITY\tENABLED\tDESCRIPTION")
+	for _, validation := range validate.List() {
+		fmt.Fprintf(tabWriter, "%s\t%s\t%t\t%s\n", validation.Name, configYaml.GetSeverity(validation.Name), configYaml.IsEnabled(validation.Name), validation.Description)
+	}
+	return tabWriter.Flush()
+}
+
+// cullCharts removes chart versions that are not kept by any retention
+// rule. The rules of a package come from the Retention field of its
+// upstream.yaml and can be overridden with flags. If the optional days
+// argument is passed, chart versions that are newer than that number of
+// days are also kept. Packages that have no retention rules are not
+// culled. Like many other subcommands, the PACKAGE environment variable
+// can be used to work on a single package.
+func cullCharts(c *cli.Context) error {
+	currentPackage := os.Getenv(packageEnvVariable)
+	paths, err := p.GetPaths()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+	packageWrappers, err := pkg.ListPackageWrappers(paths, currentPackage)
+	if err != nil {
+		return fmt.Errorf("failed to list packages: %w", err)
+	}
+
+	// parse days argument
+	var newerChartVersions map[string][]string
+	if c.Args().Len() > 0 {
+		rawDays := c.Args().Get(0)
+		daysInt64, err := strconv.ParseInt(rawDays, 10, strconv.IntSize)
+		if err != nil {
+			return fmt.Errorf("failed to convert %q to integer: %w", rawDays, err)
+		}
+		days := int(daysInt64)
+
+		_, newerChartVersions, err = getOlderAndNewerChartVersions(paths, days)
+		if err != nil {
+			return fmt.Errorf("failed to get older and newer chart versions: %w", err)
+		}
+	}
+
+	if cullKeepNewest < 0 {
+		return errors.New("--keep-newest must not be negative")
+	}
+	pins, err := parsePins(cullPins.Value())
+	if err != nil {
+		return err
+	}
+	indexYaml, err := repo.LoadIndexFile(paths.IndexYaml)
+	if err != nil {
+		return fmt.Errorf("failed to load index.yaml: %w", err)
+	}
+	autoInstallVersions := getAutoInstallVersions(indexYaml)
+
+	skippedPackages := make([]string, 0, len(packageWrappers))
+	changed := false
+	for _, packageWrapper := range packageWrappers {
+		retention := upstreamyaml.Retention{}
+		if packageWrapper.UpstreamYaml.Retention != nil {
+			retention = *packageWrapper.UpstreamYaml.Retention
+		}
+		if c.IsSet("keep-newest") {
+			retention.KeepNewest = cullKeepNewest
+		}
+		if c.IsSet("keep-latest-patches") {
+			retention.KeepLatestPatches = cullKeepLatestPatches
+		}
+		retention.Pinned = append(slices.Clone(retention.Pinned), pins[packageWrapper.FullName()]...)
+		if newerChartVersions == nil && packageWrapper.UpstreamYaml.Retention == nil &&
+			!c.IsSet("keep-newest") && !c.IsSet("keep-latest-patches") {
+			logrus.Debugf("%s has no retention rules; not culling it", packageWrapper.FullName())
+			continue
+		}
+
+		logrus.Infof("culling %s", packageWrapper.FullName())
+		existingCharts, err := loadExistingCharts(paths, packageWrapper.Vendor, packageWrapper.Name)
+		if err != nil {
+			logrus.Errorf("failed to load existing charts for %q: %s", packageWrapper.FullName(), err)
+			skippedPackages = append(skippedPackages, packageWrapper.FullName())
+			continue
+		}
+		if len(existingCharts) == 0 {
+			continue
+		}
+
+		alwaysKept := map[string]bool{}
+		for _, version := range newerChartVersions[packageWrapper.Name] {
+			alwaysKept[version] = true
+		}
+		for version := range autoInstallVersions[packageWrapper.Name] {
+			alwaysKept[version] = true
+		}
+		keptCharts, removedCharts, err := selectChartsToKeep(existingCharts, retention, alwaysKept)
+		if err != nil {
+			logrus.Errorf("failed to apply retention rules to %s: %s", packageWrapper.FullName(), err)
+			skippedPackages = append(skippedPackages, packageWrapper.FullName())
+			continue
+		}
+		if len(keptCharts) == 0 {
+			logrus.Errorf("no versions of %s would remain; skipping...",
+				packageWrapper.FullName())
+			skippedPackages = append(skippedPackages, packageWrapper.FullName())
+			continue
+		}
+		if len(removedCharts) == 0 {
+			continue
+		}
+
+		if cullDryRun {
+			culledPaths, err := getCulledPaths(paths, packageWrapper.Vendor, packageWrapper.Name, removedCharts)
+			if err != nil {
+				logrus.Errorf("failed to list paths to cull for %q: %s", packageWrapper.FullName(), err)
+				skippedPackages = append(skippedPackages, packageWrapper.FullName())
+				continue
+			}
+			for _, culledPath := range culledPaths {
+				fmt.Println(culledPath)
+			}
+			continue
+		}
+
+		if err := writeCharts(paths, packageWrapper.Vendor, packageWrapper.Name, keptCharts); err != nil {
+			logrus.Errorf("failed to write charts for %q: %s", packageWrapper.FullName(), err)
+			skippedPackages = append(skippedPackages, packageWrapper.FullName())
+			continue
+		}
+		changed = true
+	}
+
+	if len(skippedPackages) > 0 {
+		logrus.Errorf("skipped due to error:\n%s", strings.Join(skippedPackages, "\n"))
+	}
+	if len(packageWrappers) > 0 && len(skippedPackages) == len(packageWrappers) {
+		logrus.Fatal("all packages skipped")
+	}
+
+	if !changed {
+		return nil
+	}
+	if err := writeIndex(paths); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+
+	return nil
+}
+
+// getCulledPaths returns the chart archives in assets/ and the chart
+// directories in charts/ that culling removedCharts from package
+// <vendor>/<chartName> removes. Paths that do not exist are left out.
+func getCulledPaths(paths p.Paths, vendor, chartName string, removedCharts []*ChartWrapper) ([]string, error) {
+	culledPaths := make([]string, 0, 2*len(removedCharts))
+	for _, removedChart := range removedCharts {
+		candidates := []string{
+			filepath.Join(paths.Assets, vendor, getTgzFilename(removedChart.Chart)),
+			filepath.Join(paths.Charts, vendor, chartName, removedChart.Metadata.Version),
+		}
+		for _, candidate := range candidates {
+			if exists, err := utils.Exists(candidate); err != nil {
+				return nil, fmt.Errorf("failed to check %s for existence: %w", candidate, err)
+			} else if exists {
+				culledPaths = append(culledPaths, candidate)
+			}
+		}
+	}
+	return culledPaths, nil
+}
+
+// selectChartsToKeep splits chartWrappers, which must be sorted newest
+// first, into the charts that are kept by retention or are in alwaysKept,
+// and the charts that are not.
+func selectChartsToKeep(chartWrappers []*ChartWrapper, retention upstreamyaml.Retention, alwaysKept map[string]bool) (kept, removed []*ChartWrapper, err error) {
+	pinned := map[string]bool{}
+	for _, pin := range retention.Pinned {
+		pinnedVersion, err := semver.NewVersion(pin)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse pinned version %q: %w", pin, err)
+		}
+		pinned[pinnedVersion.String()] = true
+	}
+
+	seenMinorVersions := map[string]bool{}
+	for i, chartWrapper := range chartWrappers {
+		version, err := semver.NewVersion(chartWrapper.Metadata.Version)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse version %q: %w", chartWrapper.Metadata.Version, err)
+		}
+		minorVersion := fmt.Sprintf("%d.%d", version.Major(), version.Minor())
+		latestPatch := !seenMinorVersions[minorVersion]
+		seenMinorVersions[minorVersion] = true
+
+		keep := alwaysKept[chartWrapper.Metadata.Version] ||
+			pinned[version.String()] ||
+			i < retention.KeepNewest ||
+			(retention.KeepLatestPatches && latestPatch)
+		if keep {
+			kept = append(kept, chartWrapper)
+		} else {
+			removed = append(removed, chartWrapper)
+		}
+	}
+
+	return kept, removed, nil
+}
+
+// parsePins parses pins of the form <vendor>/<chart>=<version> into a
+// map of full package names to pinned versions.
+func parsePins(rawPins []string) (map[string][]string, error) {
+	pins := map[string][]string{}
+	for _, rawPin := range rawPins {
+		fullName, version, ok := strings.Cut(rawPin, "=")
+		if !ok {
+			return nil, fmt.Errorf("pin %q is not in <vendor>/<chart>=<version> format", rawPin)
+		}
+		if _, _, err := splitPackageName(fullName); err != nil {
+			return nil, fmt.Errorf("invalid pin %q: %w", rawPin, err)
+		}
+		if _, err := semver.NewVersion(version); err != nil {
+			return nil, fmt.Errorf("invalid version in pin %q: %w", rawPin, err)
+		}
+		pins[fullName] = append(pins[fullName], version)
+	}
+	return pins, nil
+}
+
+// getAutoInstallVersions returns the chart versions that are referenced by
+// the auto-install annotation of any chart version in indexYaml, as a map
+// of chart name to set of versions. The annotation has the form
+// <chart>=<version>, where a version of "match" refers to the version of
+// the chart that has the annotation.
+func getAutoInstallVersions(indexYaml *repo.IndexFile) map[string]map[string]bool {
+	autoInstallVersions := map[string]map[string]bool{}
+	for _, chartVersions := range indexYaml.Entries {
+		for _, chartVersion := range chartVersions {
+			autoInstall, ok := chartVersion.Annotations[annotationAutoInstall]
+			if !ok {
+				continue
+			}
+			chartName, version, ok := strings.Cut(autoInstall, "=")
+			if !ok {
+				continue
+			}
+			if version == "match" {
+				version = chartVersion.Version
+			}
+			if autoInstallVersions[chartName] == nil {
+				autoInstallVersions[chartName] = map[string]bool{}
+			}
+			autoInstallVersions[chartName][version] = true
+		}
+	}
+	return autoInstallVersions
+}
+
+// getOlderAndNewerChartVersions splits the chartVersions in
+// index.yaml into two groups: one that is more than days days old,
+// and one that is less than days days old. They are returned as maps
+// of chart name to slices of versions, one version per chartVersion.
+// The older versions are the first return value and the newer
+// versions are the second return value.
+func getOlderAndNewerChartVersions(paths p.Paths, days int) (map[string][]string, map[string][]string, error) {
+	indexYaml, err := repo.LoadIndexFile(paths.IndexYaml)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read index file: %w", err)
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -days)
+	olderVersions := make(map[string][]string)
+	newerVersions := make(map[string][]string)
+	for chartName, chartVersions := range indexYaml.Entries {
+		for _, chartVersion := range chartVersions {
+			if chartVersion.Created.After(cutoff) {
+				newerVersions[chartName] = append(newerVersions[chartName], chartVersion.Version)
+			} else {
+				olderVersions[chartName] = append(olderVersions[chartName], chartVersion.Version)
+			}
+		}
+	}
+
+	return olderVersions, newerVersions, nil
+}
+
+// repairRepo makes charts/ and index.yaml consistent with the chart
+// archives in assets/, which are treated as the source of truth. Each
+// fix that it makes is printed.
+func repairRepo(c *cli.Context) error {
+	paths, err := p.GetPaths()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	fixes, repairErr := repairChartsDirectory(paths)
+	for _, fix := range fixes {
+		fmt.Println(fix)
+	}
+
+	oldIndexYaml, err := os.ReadFile(paths.IndexYaml)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read index.yaml: %w", err)
+	}
+	if err := writeIndex(paths); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	newIndexYaml, err := os.ReadFile(paths.IndexYaml)
+	if err != nil {
+		return fmt.Errorf("failed to read index.yaml: %w", err)
+	}
+	if !bytes.Equal(oldIndexYaml, newIndexYaml) {
+		fmt.Printf("rewrote %s\n", paths.IndexYaml)
+	} else if len(fixes) == 0 && repairErr == nil {
+		logrus.Info("nothing to repair")
+	}
+
+	return repairErr
+}
+
+// repairChartsDirectory ensures that each chart archive in assets/ is
+// unpacked to charts/<vendor>/<chart>/<version>/ with the same contents,
+// and that charts/ contains nothing else. It returns a description of
+// each fix that it makes. Chart archives that cannot be loaded are
+// reported in the returned error, and the charts/ directory of their
+// vendor is left as is.
+func repairChartsDirectory(paths p.Paths) ([]string, error) {
+	fixes := make([]string, 0)
+	errs := make([]error, 0)
+
+	vendorEntries, err := os.ReadDir(paths.Assets)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read %s: %w", paths.Assets, err)
+	}
+	expectedChartDirs := map[string]bool{}
+	skippedVendors := map[string]bool{}
+	for _, vendorEntry := range vendorEntries {
+		vendorPath := filepath.Join(paths.Assets, vendorEntry.Name())
+		if !vendorEntry.IsDir() || filepath.Clean(vendorPath) == filepath.Clean(paths.Icons) {
+			continue
+		}
+		vendor := vendorEntry.Name()
+		tgzPaths, err := filepath.Glob(filepath.Join(vendorPath, "*.tgz"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list chart archives in %s: %w", vendorPath, err)
+		}
+		for _, tgzPath := range tgzPaths {
+			helmChart, err := loader.LoadFile(tgzPath)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to load %s: %w", tgzPath, err))
+				skippedVendors[vendor] = true
+				continue
+			}
+			chartDir := filepath.Join(paths.Charts, vendor, helmChart.Name(), helmChart.Metadata.Version)
+			expectedChartDirs[chartDir] = true
+
+			exists, err := utils.Exists(chartDir)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check %s for existence: %w", chartDir, err)
+			}
+			reason := "missing"
+			if exists {
+				match, err := conform.ChartDirectoryMatchesArchive(tgzPath, chartDir)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("failed to compare %s to %s: %w", chartDir, tgzPath, err))
+					continue
+				}
+				if match {
+					continue
+				}
+				reason = "did not match " + tgzPath
+			}
+			if err := os.RemoveAll(chartDir); err != nil {
+				return nil, fmt.Errorf("failed to remove %s: %w", chartDir, err)
+			}
+			if err := conform.Gunzip(tgzPath, chartDir); err != nil {
+				return nil, fmt.Errorf("failed to unpack %s to %s: %w", tgzPath, chartDir, err)
+			}
+			fixes = append(fixes, fmt.Sprintf("regenerated %s (%s)", chartDir, reason))
+		}
+	}
+
+	// remove anything in charts/<vendor>/<chart>/ that does not come
+	// from a chart archive
+	versionPaths, err := filepath.Glob(filepath.Join(paths.Charts, "*", "*", "*"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chart directories: %w", err)
+	}
+	for _, versionPath := range versionPaths {
+		vendor := filepath.Base(filepath.Dir(filepath.Dir(versionPath)))
+		if expectedChartDirs[versionPath] || skippedVendors[vendor] {
+			continue
+		}
+		if err := os.RemoveAll(versionPath); err != nil {
+			return nil, fmt.Errorf("failed to remove %s: %w", versionPath, err)
+		}
+		fixes = append(fixes, fmt.Sprintf("removed %s (no chart archive in %s)", versionPath, paths.Assets))
+	}
+	chartPaths, err := filepath.Glob(filepath.Join(paths.Charts, "*", "*"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chart directories: %w", err)
+	}
+	vendorPaths, err := filepath.Glob(filepath.Join(paths.Charts, "*"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vendor directories: %w", err)
+	}
+	for _, dirPath := range append(chartPaths, vendorPaths...) {
+		if err := removeDirIfEmpty(dirPath); err != nil {
+			return nil, err
+		}
+	}
+
+	return fixes, errors.Join(errs...)
+}
+
+// diffCharts prints how two chart archives differ, ignoring the same
+// changes that the released-chart-modifications validation ignores.
+func diffCharts(c *cli.Context) error {
+	if c.Args().Len() != 2 {
+		return errors.New("must provide two charts as arguments")
+	}
+	paths, err := p.GetPaths()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+	oldPath, err := resolveChartArchive(paths, c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	newPath, err := resolveChartArchive(paths, c.Args().Get(1))
+	if err != nil {
+		return err
+	}
+
+	chartDiff, err := validate.DiffHelmCharts(oldPath, newPath)
+	if err != nil {
+		return fmt.Errorf("failed to diff %s and %s: %w", oldPath, newPath, err)
+	}
+	if chartDiff.Match() {
+		fmt.Println("charts do not differ")
+		return nil
+	}
+	fmt.Print(chartDiff)
+	return nil
+}
+
+// resolveChartArchive returns the path to the chart archive referred to
+// by chartRef, which is either the path to a .tgz file or a chart version
+// in assets/ in <vendor>/<chart>@<version> format.
+func resolveChartArchive(paths p.Paths, chartRef string) (string, error) {
+	if strings.HasSuffix(chartRef, ".tgz") {
+		if _, err := os.Stat(chartRef); err != nil {
+			return "", fmt.Errorf("failed to stat %s: %w", chartRef, err)
+		}
+		return chartRef, nil
+	}
+	fullName, version, ok := strings.Cut(chartRef, "@")
+	if !ok || version == "" {
+		return "", fmt.Errorf("chart %q is neither a .tgz file nor in <vendor>/<chart>@<version> format", chartRef)
+	}
+	vendor, name, err := splitPackageName(fullName)
+	if err != nil {
+		return "", err
+	}
+	tgzPath := filepath.Join(paths.Assets, vendor, fmt.Sprintf("%s-%s.tgz", name, version))
+	if _, err := os.Stat(tgzPath); errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("version %s of %s is not in %s", version, fullName, paths.Assets)
+	} else if err != nil {
+		return "", fmt.Errorf("failed to stat %s: %w", tgzPath, err)
+	}
+	return tgzPath, nil
+}
+
+// addPackage creates a new package from the upstream given in the
+// command's flags. The upstream is fetched to check that it works and to
+// fill in upstream.yaml fields from the metadata of its latest chart.
+func addPackage(c *cli.Context) error {
+	if c.Args().Len() > 1 {
+		return errors.New("flags must come before the package name")
+	} else if c.Args().Len() != 1 {
+		return errors.New("must provide package name in <vendor>/<chart> format as argument")
+	}
+	fullName := c.Args().Get(0)
+	vendor, chartName, err := splitPackageName(fullName)
+	if err != nil {
+		return err
+	}
+	paths, err := p.GetPaths()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	packagePath := filepath.Join(paths.Packages, vendor, chartName)
+	if exists, err := utils.Exists(packagePath); err != nil {
+		return fmt.Errorf("failed to check %s for existence: %w", packagePath, err)
+	} else if exists {
+		return fmt.Errorf("package %s already exists", fullName)
+	}
+	packageWrappers, err := pkg.ListPackageWrappers(paths, "")
+	if err != nil {
+		return fmt.Errorf("failed to list packages: %w", err)
+	}
+	if err := checkPackageNameAvailable(packageWrappers, chartName); err != nil {
+		return err
+	}
+
+	if addOCIRepo != "" {
+		if addUpstreamYaml.HelmRepo != "" {
+			return errors.New("--helm-repo and --oci-repo cannot both be set")
+		}
+		if !strings.HasPrefix(addOCIRepo, "oci://") {
+			return fmt.Errorf("OCI repository %q must begin with oci://", addOCIRepo)
+		}
+		addUpstreamYaml.HelmRepo = addOCIRepo
+	}
+	upstreamYaml, err := upstreamyaml.New(addUpstreamYaml)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Fetching upstream of %s", fullName)
+	sourceMetadata, err := fetcher.FetchUpstream(*upstreamYaml)
+	if err != nil {
+		return fmt.Errorf("failed to fetch upstream: %w", err)
+	}
+	if len(sourceMetadata.Versions) == 0 {
+		return errors.New("upstream has no chart versions")
+	}
+	latestVersion := sourceMetadata.Versions[0]
+	latestMetadata := latestVersion.Metadata
+	if sourceMetadata.Source != "Git" {
+		// Load the chart to check that it can be downloaded, and because
+		// some upstreams (i.e. OCI registries) do not provide its metadata.
+		helmChart, err := fetcher.LoadChartFromURL(latestVersion.URLs[0])
+		if err != nil {
+			return fmt.Errorf("failed to load chart version %s: %w", latestVersion.Version, err)
+		}
+		latestMetadata = helmChart.Metadata
+	}
+	prefillUpstreamYaml(upstreamYaml, vendor, chartName, latestMetadata)
+
+	if err := writeNewPackage(packagePath, upstreamYaml, latestMetadata); err != nil {
+		return err
+	}
+	logrus.Infof("Created package %s; please review %s", fullName, packagePath)
+
+	if !addRunUpdate {
+		return nil
+	}
+	packageWrappers, err = pkg.ListPackageWrappers(paths, fullName)
+	if err != nil {
+		return fmt.Errorf("failed to list packages: %w", err)
+	}
+	packageWrapper := packageWrappers[0]
+	updatable, err := packageWrapper.Populate(paths)
+	if err != nil {
+		return fmt.Errorf("failed to populate %s: %w", fullName, err)
+	}
+	if !updatable {
+		return nil
+	}
+	if _, err := ApplyUpdates(paths, packageWrapper); err != nil {
+		return fmt.Errorf("failed to apply updates for %s: %w", fullName, err)
+	}
+	if err := writeIndex(paths); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+
+	return nil
+}
+
+// splitPackageName splits a full package name of the form
+// <vendor>/<name> into its vendor and name.
+func splitPackageName(fullName string) (vendor, name string, err error) {
+	vendor, name, ok := strings.Cut(fullName, "/")
+	if !ok || vendor == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", fmt.Errorf("package name %q is not in <vendor>/<chart> format", fullName)
+	}
+	return vendor, name, nil
+}
+
+// checkPackageNameAvailable returns an error if any of packageWrappers,
+// regardless of vendor, is named chartName. Packages must have unique
+// names because charts are stored in index.yaml by name only.
+func checkPackageNameAvailable(packageWrappers []pkg.PackageWrapper, chartName string) error {
+	for _, packageWrapper := range packageWrappers {
+		if packageWrapper.Name == chartName {
+			return fmt.Errorf("package name %q is already used by %s", chartName, packageWrapper.FullName())
 		}
 	}
+	return nil
+}
 
-	if len(updatablePackageWrappers) == 0 {
-		return nil
+// writeNewPackage writes upstreamYaml and a stub app-readme.md to the
+// new package directory packagePath. If writing fails, packagePath is
+// removed so that no half-written package is left behind.
+func writeNewPackage(packagePath string, upstreamYaml *upstreamyaml.UpstreamYaml, metadata *chart.Metadata) (err error) {
+	if err := os.MkdirAll(filepath.Join(packagePath, "overlay"), 0o755); err != nil {
+		return fmt.Errorf("failed to create package directory: %w", err)
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		if removeErr := os.RemoveAll(packagePath); removeErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to remove %s: %w", packagePath, removeErr))
+		}
+	}()
+	if err := upstreamyaml.Write(filepath.Join(packagePath, upstreamYamlFile), upstreamYaml); err != nil {
+		return fmt.Errorf("failed to write upstream.yaml: %w", err)
 	}
+	appReadmePath := filepath.Join(packagePath, "overlay", "app-readme.md")
+	if err := os.WriteFile(appReadmePath, getAppReadmeStub(upstreamYaml.DisplayName, metadata), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", appReadmePath, err)
+	}
+	return nil
+}
 
-	updatedPackageWrappers := make([]pkg.PackageWrapper, 0, len(updatablePackageWrappers))
-	skippedList := make([]string, 0, len(updatablePackageWrappers))
-	for _, packageWrapper := range updatablePackageWrappers {
-		if err := ApplyUpdates(paths, packageWrapper); err != nil {
-			logrus.Errorf("failed to apply updates for chart %q: %s", packageWrapper.Name, err)
-			skippedList = append(skippedList, packageWrapper.Name)
+// prefillUpstreamYaml sets fields of upstreamYaml that are not already
+// set for package <vendor>/<chartName> from the metadata of the
+// upstream's latest chart. If the upstream chart has a different name
+// than the package, ChartMetadata.Name renames it, since chart versions
+// are stored and looked up by package name.
+func prefillUpstreamYaml(upstreamYaml *upstreamyaml.UpstreamYaml, vendor, chartName string, metadata *chart.Metadata) {
+	if upstreamYaml.DisplayName == "" {
+		if displayName := metadata.Annotations[annotationDisplayName]; displayName != "" {
+			upstreamYaml.DisplayName = displayName
 		} else {
-			updatedPackageWrappers = append(updatedPackageWrappers, packageWrapper)
+			upstreamYaml.DisplayName = metadata.Name
 		}
 	}
-	if len(skippedList) >= len(updatablePackageWrappers) {
-		logrus.Fatal("All packages skipped. Exiting...")
+	if upstreamYaml.Vendor == "" {
+		upstreamYaml.Vendor = vendor
 	}
-	if len(skippedList) > 0 {
-		logrus.Errorf("Skipped due to error: %v", skippedList)
+	if upstreamYaml.ChartMetadata.Name == "" && metadata.Name != chartName {
+		upstreamYaml.ChartMetadata.Name = chartName
 	}
-
-	if err := writeIndex(paths); err != nil {
-		logrus.Fatalf("failed to write index.yaml: %s", err)
+	if upstreamYaml.ChartMetadata.KubeVersion == "" && metadata.KubeVersion != "" {
+		upstreamYaml.ChartMetadata.KubeVersion = conform.NormalizeKubeVersion(metadata.KubeVersion)
 	}
+}
 
-	if makeCommit {
-		if err := commitChanges(paths, updatedPackageWrappers); err != nil {
-			logrus.Fatalf("failed to commit changes: %s", err)
-		}
+// getAppReadmeStub returns the contents of a placeholder app-readme.md.
+func getAppReadmeStub(displayName string, metadata *chart.Metadata) []byte {
+	description := metadata.Description
+	if description == "" {
+		description = "TODO: describe this chart."
 	}
-
-	return nil
+	return []byte(fmt.Sprintf("# %s\n\n%s\n", displayName, description))
 }
 
-// CLI function call - Validates repo against released
-func validateRepo(c *cli.Context) error {
+// movePackage moves a package to a different vendor. The package
+// directory, its chart versions in assets/ and its charts/ directory are
+// moved without changing the contents of any released chart version,
+// except for partner-charts-specific annotations when --display-name
+// is given. Validations are run once the package has been moved.
+func movePackage(c *cli.Context) error {
+	if c.Args().Len() > 2 {
+		return errors.New("flags must come before the package names")
+	} else if c.Args().Len() != 2 {
+		return errors.New("must provide old and new package names in <vendor>/<chart> format as arguments")
+	}
 	paths, err := p.GetPaths()
 	if err != nil {
 		return fmt.Errorf("failed to get paths: %w", err)
 	}
-	configYaml, err := validate.ReadConfig(paths.ConfigurationYaml)
-	if err != nil {
-		logrus.Fatalf("failed to read configuration.yaml: %s\n", err)
-	}
 
-	validationErrors := validate.Run(paths, configYaml)
-	if len(validationErrors) > 0 {
-		fmt.Println(errors.Join(validationErrors...))
-		return errors.New("validation failed")
+	if err := movePackageFiles(paths, c.Args().Get(0), c.Args().Get(1), moveDisplayName, moveVendor); err != nil {
+		return err
 	}
 
-	return nil
+	return runValidations(paths, validate.Options{}, validate.FormatText)
 }
 
-// cullCharts removes chart versions that are older than the passed number of
-// days. Like many other subcommands, the PACKAGE environment variable can be
-// used to work on a single package.
-func cullCharts(c *cli.Context) error {
-	currentPackage := os.Getenv(packageEnvVariable)
-	paths, err := p.GetPaths()
+// movePackageFiles does the work of movePackage. It moves package
+// oldFullName, its chart archives and its charts directory to
+// newFullName, and sets the display name and vendor of the package to
+// displayName and vendorName if they are not empty. Nothing is moved if
+// a destination already exists, and if a later step fails, the steps
+// that were already done are undone.
+func movePackageFiles(paths p.Paths, oldFullName, newFullName, displayName, vendorName string) (err error) {
+	oldVendor, oldName, err := splitPackageName(oldFullName)
 	if err != nil {
-		return fmt.Errorf("failed to get paths: %w", err)
+		return err
 	}
-	packageWrappers, err := pkg.ListPackageWrappers(paths, currentPackage)
+	newVendor, newName, err := splitPackageName(newFullName)
 	if err != nil {
-		return fmt.Errorf("failed to list packages: %w", err)
+		return err
+	}
+	if oldName != newName {
+		return fmt.Errorf("cannot rename %s to %s: chart names cannot change because released chart versions cannot be modified", oldName, newName)
+	}
+	if oldVendor == newVendor {
+		return fmt.Errorf("%s and %s are the same package", oldFullName, newFullName)
 	}
 
-	// parse days argument
-	rawDays := c.Args().Get(0)
-	daysInt64, err := strconv.ParseInt(rawDays, 10, strconv.IntSize)
+	packageWrappers, err := pkg.ListPackageWrappers(paths, oldFullName)
 	if err != nil {
-		return fmt.Errorf("failed to convert %q to integer: %w", rawDays, err)
+		return fmt.Errorf("failed to list packages: %w", err)
 	}
-	days := int(daysInt64)
+	packageWrapper := packageWrappers[0]
 
-	_, newerChartVersions, err := getOlderAndNewerChartVersions(paths, days)
+	// getExistingChartTgzFiles matches any chart whose name begins with
+	// the package name, so only keep charts that belong to this package.
+	existingCharts, err := loadExistingCharts(paths, oldVendor, oldName)
 	if err != nil {
-		return fmt.Errorf("failed to get older and newer chart versions: %w", err)
+		return fmt.Errorf("failed to load existing charts: %w", err)
+	}
+	chartWrappers := make([]*ChartWrapper, 0, len(existingCharts))
+	for _, chartWrapper := range existingCharts {
+		if chartWrapper.Name() == oldName {
+			chartWrappers = append(chartWrappers, chartWrapper)
+		}
 	}
 
-	skippedPackages := make([]string, 0, len(packageWrappers))
-	for _, packageWrapper := range packageWrappers {
-		logrus.Infof("culling %s", packageWrapper.FullName())
-		existingCharts, err := loadExistingCharts(paths, packageWrapper.Vendor, packageWrapper.Name)
-		if err != nil {
-			logrus.Errorf("failed to load existing charts for %q: %s", packageWrapper.FullName(), err)
-			skippedPackages = append(skippedPackages, packageWrapper.FullName())
-			continue
+	// check everything before moving anything
+	oldPackagePath := filepath.Join(paths.Packages, oldVendor, oldName)
+	newPackagePath := filepath.Join(paths.Packages, newVendor, newName)
+	oldChartsPath := filepath.Join(paths.Charts, oldVendor, oldName)
+	newChartsPath := filepath.Join(paths.Charts, newVendor, newName)
+	destinations := []string{newPackagePath, newChartsPath}
+	for _, chartWrapper := range chartWrappers {
+		destinations = append(destinations, filepath.Join(paths.Assets, newVendor, getTgzFilename(chartWrapper.Chart)))
+	}
+	for _, destination := range destinations {
+		if exists, err := utils.Exists(destination); err != nil {
+			return fmt.Errorf("failed to check %s for existence: %w", destination, err)
+		} else if exists {
+			return fmt.Errorf("cannot move %s to %s: %s already exists", oldFullName, newFullName, destination)
 		}
+	}
 
-		keptCharts := make([]*ChartWrapper, 0, len(existingCharts))
-		for _, existingChart := range existingCharts {
-			if slices.Contains(newerChartVersions[packageWrapper.Name], existingChart.Metadata.Version) {
-				keptCharts = append(keptCharts, existingChart)
+	// keep what is needed to undo the move if a step fails
+	oldUpstreamYamlPath := filepath.Join(oldPackagePath, upstreamYamlFile)
+	upstreamYamlContents, err := os.ReadFile(oldUpstreamYamlPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", oldUpstreamYamlPath, err)
+	}
+	backup, err := backupCharts(paths, oldVendor, oldName)
+	if err != nil {
+		return fmt.Errorf("failed to back up charts: %w", err)
+	}
+	moves := make([][2]string, 0, len(chartWrappers)+2)
+	move := func(oldPath, newPath string) error {
+		if err := moveFile(oldPath, newPath); err != nil {
+			return err
+		}
+		moves = append(moves, [2]string{oldPath, newPath})
+		return nil
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		errs := []error{err}
+		for i := len(moves) - 1; i >= 0; i-- {
+			if undoErr := moveFile(moves[i][1], moves[i][0]); undoErr != nil {
+				errs = append(errs, fmt.Errorf("failed to undo move: %w", undoErr))
 			}
 		}
-		if len(keptCharts) == 0 {
-			logrus.Errorf("no versions of %s would remain; skipping...",
-				packageWrapper.FullName())
-			skippedPackages = append(skippedPackages, packageWrapper.FullName())
-			continue
+		if writeErr := os.WriteFile(oldUpstreamYamlPath, upstreamYamlContents, 0o644); writeErr != nil {
+			errs = append(errs, fmt.Errorf("failed to restore upstream.yaml: %w", writeErr))
 		}
+		if restoreErr := backup.restore(paths); restoreErr != nil {
+			errs = append(errs, fmt.Errorf("failed to restore charts: %w", restoreErr))
+		}
+		for _, dir := range []string{paths.Packages, paths.Assets, paths.Charts} {
+			if removeErr := removeDirIfEmpty(filepath.Join(dir, newVendor)); removeErr != nil {
+				errs = append(errs, removeErr)
+			}
+		}
+		err = errors.Join(errs...)
+	}()
 
-		if err := writeCharts(paths, packageWrapper.Vendor, packageWrapper.Name, keptCharts); err != nil {
-			logrus.Errorf("failed to write charts for %q: %s", packageWrapper.FullName(), err)
-			skippedPackages = append(skippedPackages, packageWrapper.FullName())
-			continue
+	logrus.Infof("Moving %s to %s", oldFullName, newFullName)
+	if err := move(oldPackagePath, newPackagePath); err != nil {
+		return err
+	}
+	for _, chartWrapper := range chartWrappers {
+		tgzFilename := getTgzFilename(chartWrapper.Chart)
+		if err := move(filepath.Join(paths.Assets, oldVendor, tgzFilename), filepath.Join(paths.Assets, newVendor, tgzFilename)); err != nil {
+			return err
+		}
+	}
+	if exists, err := utils.Exists(oldChartsPath); err != nil {
+		return fmt.Errorf("failed to check %s for existence: %w", oldChartsPath, err)
+	} else if exists {
+		if err := move(oldChartsPath, newChartsPath); err != nil {
+			return err
 		}
 	}
 
-	if len(skippedPackages) > 0 {
-		logrus.Errorf("skipped due to error:\n%s", strings.Join(skippedPackages, "\n"))
+	if displayName != "" || vendorName != "" {
+		if displayName != "" {
+			packageWrapper.UpstreamYaml.DisplayName = displayName
+		}
+		if vendorName != "" {
+			packageWrapper.UpstreamYaml.Vendor = vendorName
+		}
+		upstreamYamlPath := filepath.Join(newPackagePath, upstreamYamlFile)
+		if err := upstreamyaml.Write(upstreamYamlPath, packageWrapper.UpstreamYaml); err != nil {
+			return fmt.Errorf("failed to write upstream.yaml: %w", err)
+		}
 	}
-	if len(skippedPackages) == len(packageWrappers) {
-		logrus.Fatal("all packages skipped")
+	if displayName != "" {
+		if err := annotate(paths, newVendor, newName, annotationDisplayName, displayName, false, false); err != nil {
+			return fmt.Errorf("failed to annotate %s: %w", newFullName, err)
+		}
 	}
 
 	if err := writeIndex(paths); err != nil {
 		return fmt.Errorf("failed to write index: %w", err)
 	}
 
+	for _, dir := range []string{paths.Packages, paths.Assets, paths.Charts} {
+		if err := removeDirIfEmpty(filepath.Join(dir, oldVendor)); err != nil {
+			logrus.Warn(err)
+		}
+	}
+
 	return nil
 }
 
-// getOlderAndNewerChartVersions splits the chartVersions in
-// index.yaml into two groups: one that is more than days days old,
-// and one that is less than days days old. They are returned as maps
-// of chart name to slices of versions, one version per chartVersion.
-// The older versions are the first return value and the newer
-// versions are the second return value.
-func getOlderAndNewerChartVersions(paths p.Paths, days int) (map[string][]string, map[string][]string, error) {
-	indexYaml, err := repo.LoadIndexFile(paths.IndexYaml)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to read index file: %w", err)
+// moveFile renames oldPath to newPath, creating the parent directory of
+// newPath if necessary.
+func moveFile(oldPath, newPath string) error {
+	if err := os.MkdirAll(filepath.Dir(newPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create parent directory of %s: %w", newPath, err)
 	}
-
-	cutoff := time.Now().AddDate(0, 0, -days)
-	olderVersions := make(map[string][]string)
-	newerVersions := make(map[string][]string)
-	for chartName, chartVersions := range indexYaml.Entries {
-		for _, chartVersion := range chartVersions {
-			if chartVersion.Created.After(cutoff) {
-				newerVersions[chartName] = append(newerVersions[chartName], chartVersion.Version)
-			} else {
-				olderVersions[chartName] = append(olderVersions[chartName], chartVersion.Version)
-			}
-		}
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return fmt.Errorf("failed to move %s to %s: %w", oldPath, newPath, err)
 	}
+	return nil
+}
 
-	return olderVersions, newerVersions, nil
+// removeDirIfEmpty removes dirPath if it exists and is empty.
+func removeDirIfEmpty(dirPath string) error {
+	entries, err := os.ReadDir(dirPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read %s: %w", dirPath, err)
+	}
+	if len(entries) > 0 {
+		return nil
+	}
+	if err := os.Remove(dirPath); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", dirPath, err)
+	}
+	return nil
 }
 
 func removePackage(c *cli.Context) error {
@@ -1052,20 +3252,69 @@ func deprecatePackage(c *cli.Context) error {
 		return fmt.Errorf("failed to write upstream.yaml: %w", err)
 	}
 
-	// set deprecated: true in each chart version's Chart.yaml
-	chartWrappers, err := loadExistingCharts(paths, packageWrapper.Vendor, packageWrapper.Name)
+	if err := setChartsDeprecated(paths, packageWrapper.Vendor, packageWrapper.Name, true); err != nil {
+		return err
+	}
+
+	if err := writeIndex(paths); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+
+	return nil
+}
+
+// setChartsDeprecated sets the deprecated field in the Chart.yaml of
+// each released version of a package.
+func setChartsDeprecated(paths p.Paths, vendor, chartName string, deprecated bool) error {
+	chartWrappers, err := loadExistingCharts(paths, vendor, chartName)
 	if err != nil {
 		return fmt.Errorf("failed to load existing charts: %w", err)
 	}
 	for _, chartWrapper := range chartWrappers {
-		if !chartWrapper.Metadata.Deprecated {
-			chartWrapper.Metadata.Deprecated = true
+		if chartWrapper.Metadata.Deprecated != deprecated {
+			chartWrapper.Metadata.Deprecated = deprecated
 			chartWrapper.Modified = true
 		}
 	}
-	if err := writeCharts(paths, packageWrapper.Vendor, packageWrapper.Name, chartWrappers); err != nil {
+	if err := writeCharts(paths, vendor, chartName, chartWrappers); err != nil {
 		return fmt.Errorf("failed to write charts: %w", err)
 	}
+	return nil
+}
+
+// undeprecatePackage reverses deprecatePackage. It sets Deprecated to
+// false in the package's upstream.yaml and in the Chart.yaml of each
+// released version of the package.
+func undeprecatePackage(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return errors.New("must provide package name as argument")
+	}
+	paths, err := p.GetPaths()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+	return undeprecate(paths, c.Args().Get(0))
+}
+
+// undeprecate does the work of undeprecatePackage for package
+// currentPackage.
+func undeprecate(paths p.Paths, currentPackage string) error {
+	packageWrappers, err := pkg.ListPackageWrappers(paths, currentPackage)
+	if err != nil {
+		return fmt.Errorf("failed to list package wrappers: %w", err)
+	}
+	packageWrapper := packageWrappers[0]
+
+	// set Deprecated: false in upstream.yaml
+	packageWrapper.UpstreamYaml.Deprecated = false
+	upstreamYamlPath := filepath.Join(packageWrapper.Path, upstreamYamlFile)
+	if err := upstreamyaml.Write(upstreamYamlPath, packageWrapper.UpstreamYaml); err != nil {
+		return fmt.Errorf("failed to write upstream.yaml: %w", err)
+	}
+
+	if err := setChartsDeprecated(paths, packageWrapper.Vendor, packageWrapper.Name, false); err != nil {
+		return err
+	}
 
 	if err := writeIndex(paths); err != nil {
 		return fmt.Errorf("failed to write index: %w", err)
@@ -1090,6 +3339,61 @@ func main() {
 			Usage:  "List packages",
 			Action: listPackages,
 		},
+		{
+			Name:   "status",
+			Usage:  "Show the stored and upstream versions and flags of each package",
+			Action: showStatus,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:        "format",
+					Usage:       "Output format (table, json or markdown)",
+					Value:       "table",
+					Destination: &statusFormat,
+				},
+				&cli.BoolFlag{
+					Name:        "offline",
+					Usage:       "Do not check upstreams for new versions",
+					Destination: &statusOffline,
+				},
+			},
+		},
+		{
+			Name:   "stale",
+			Usage:  "Record upstream failures and releases in stale.yaml and report packages with unreachable or abandoned upstreams",
+			Action: reportStalePackages,
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:        "failures",
+					Usage:       "Report packages whose upstream could not be fetched in this many runs in a row",
+					Value:       3,
+					Destination: &staleFailures,
+				},
+				&cli.IntFlag{
+					Name:        "days",
+					Usage:       "Report packages whose latest upstream version is older than this many days",
+					Value:       365,
+					Destination: &staleDays,
+				},
+				&cli.BoolFlag{
+					Name:        "json",
+					Usage:       "Print stale packages as JSON",
+					Destination: &jsonOutput,
+				},
+			},
+		},
+		{
+			Name:      "show",
+			Usage:     "Show the configuration, chart versions and validation findings of a package",
+			Action:    showPackage,
+			ArgsUsage: "<vendor>/<chart>",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:        "json",
+					Usage:       "Print package information as JSON",
+					Destination: &jsonOutput,
+				},
+			},
+		},
 		{
 			Name:   "update",
 			Usage:  "Download and integrate new chart versions from upstreams",
@@ -1109,11 +3413,35 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:      "annotate",
+			Usage:     "Set or remove a catalog.cattle.io annotation on the stored versions of a package",
+			Action:    annotatePackage,
+			ArgsUsage: "[flags] <package> <key>=<value>",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:        "remove",
+					Usage:       "Remove the annotation; if a value is given, only where it has that value",
+					Destination: &annotateRemove,
+				},
+				&cli.BoolFlag{
+					Name:        "latest-only",
+					Usage:       "Only change the latest stored version",
+					Destination: &annotateLatestOnly,
+				},
+			},
+		},
 		{
 			Name:   "hide",
 			Usage:  "Apply 'catalog.cattle.io/hidden' annotation to all stored versions of chart",
 			Action: hideChart,
 		},
+		{
+			Name:      "unhide",
+			Usage:     "Remove 'catalog.cattle.io/hidden' annotation from all stored versions of chart",
+			Action:    unhideChart,
+			ArgsUsage: "<package>",
+		},
 		{
 			Name:  "feature",
 			Usage: "Commands related to the 'catalog.cattle.io/featured' annotation",
@@ -1122,6 +3450,13 @@ func main() {
 					Name:   "list",
 					Usage:  "List currently featured charts",
 					Action: listFeaturedCharts,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{
+							Name:        "json",
+							Usage:       "Print featured charts as JSON",
+							Destination: &jsonOutput,
+						},
+					},
 				},
 				{
 					Name:   "add",
@@ -1133,18 +3468,179 @@ func main() {
 					Usage:  "Remove 'catalog.cattle.io/featured' annotation from chart",
 					Action: removeFeaturedChart,
 				},
+				{
+					Name:      "set",
+					Usage:     fmt.Sprintf("Feature up to %d packages in the given order, and unfeature all others", featuredMax),
+					Action:    setFeaturedCharts,
+					ArgsUsage: "<package> [<package>...]",
+				},
+				{
+					Name:      "swap",
+					Usage:     "Swap the featured slots of two packages",
+					Action:    swapFeaturedCharts,
+					ArgsUsage: "<package> <package>",
+				},
 			},
 		},
 		{
 			Name:   "validate",
 			Usage:  "Run validations on the repository",
 			Action: validateRepo,
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name:        "only",
+					Usage:       "Run only the named validation. Can be repeated.",
+					Destination: validateOnly,
+				},
+				&cli.StringSliceFlag{
+					Name:        "skip",
+					Usage:       "Do not run the named validation. Can be repeated.",
+					Destination: validateSkip,
+				},
+				&cli.StringFlag{
+					Name:        "base",
+					Usage:       "Compare against released chart versions at this revision of the local git repository, e.g. origin/main, instead of cloning the validation upstreams",
+					Destination: &validateBase,
+				},
+				&cli.StringFlag{
+					Name:        "format",
+					Usage:       "Output format of findings: text, json, sarif or junit",
+					Value:       string(validate.FormatText),
+					Destination: &validateFormat,
+				},
+				&cli.BoolFlag{
+					Name:        "list",
+					Usage:       "List the validations instead of running them",
+					Destination: &validateList,
+				},
+				&cli.BoolFlag{
+					Name:        "fix",
+					Usage:       "Re-apply annotations and deprecation from upstream.yaml to released chart versions before validating",
+					Destination: &validateFix,
+				},
+			},
+		},
+		{
+			Name:   "repair",
+			Usage:  "Rebuild charts/ and index.yaml from the chart archives in assets/",
+			Action: repairRepo,
+		},
+		{
+			Name:      "diff",
+			Usage:     "Show how two chart versions differ, ignoring changes that validation ignores",
+			Action:    diffCharts,
+			ArgsUsage: "<chart> <chart>",
+			Description: "Each chart is either the path to a chart archive or a chart version in\n" +
+				"<vendor>/<chart>@<version> format, which refers to the archive in assets/.",
 		},
 		{
 			Name:      "cull",
-			Usage:     "Remove chart versions older than a number of days",
+			Usage:     "Remove chart versions that are not kept by retention rules or are older than a number of days",
 			Action:    cullCharts,
-			ArgsUsage: "<days>",
+			ArgsUsage: "[<days>]",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:        "dry-run",
+					Usage:       "Print the files and directories that would be removed without removing them",
+					Destination: &cullDryRun,
+				},
+				&cli.IntFlag{
+					Name:        "keep-newest",
+					Usage:       "Keep this many of the newest chart versions of each package, overriding Retention.KeepNewest",
+					Destination: &cullKeepNewest,
+				},
+				&cli.BoolFlag{
+					Name:        "keep-latest-patches",
+					Usage:       "Keep the latest patch version of each minor version, overriding Retention.KeepLatestPatches",
+					Destination: &cullKeepLatestPatches,
+				},
+				&cli.StringSliceFlag{
+					Name:        "pin",
+					Usage:       "Keep a chart version, in <vendor>/<chart>=<version> format. May be given more than once",
+					Destination: cullPins,
+				},
+			},
+		},
+		{
+			Name:      "add",
+			Usage:     "Create a new package from an upstream",
+			Action:    addPackage,
+			ArgsUsage: "[flags] <vendor>/<chart>",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:        "helm-repo",
+					Usage:       "URL of the upstream helm repository",
+					Destination: &addUpstreamYaml.HelmRepo,
+				},
+				&cli.StringFlag{
+					Name:        "oci-repo",
+					Usage:       "oci:// URL of the upstream OCI registry path that contains the chart",
+					Destination: &addOCIRepo,
+				},
+				&cli.StringFlag{
+					Name:        "chart",
+					Usage:       "Name of the chart in the helm repository or OCI registry",
+					Destination: &addUpstreamYaml.HelmChart,
+				},
+				&cli.StringFlag{
+					Name:        "artifacthub-repo",
+					Usage:       "Name of the upstream repository on Artifact Hub",
+					Destination: &addUpstreamYaml.ArtifactHubRepo,
+				},
+				&cli.StringFlag{
+					Name:        "artifacthub-package",
+					Usage:       "Name of the package in the Artifact Hub repository",
+					Destination: &addUpstreamYaml.ArtifactHubPackage,
+				},
+				&cli.StringFlag{
+					Name:        "git-repo",
+					Usage:       "URL of the upstream git repository",
+					Destination: &addUpstreamYaml.GitRepo,
+				},
+				&cli.StringFlag{
+					Name:        "git-branch",
+					Usage:       "Branch of the git repository to use",
+					Destination: &addUpstreamYaml.GitBranch,
+				},
+				&cli.StringFlag{
+					Name:        "git-subdirectory",
+					Usage:       "Subdirectory of the git repository that contains the chart",
+					Destination: &addUpstreamYaml.GitSubdirectory,
+				},
+				&cli.BoolFlag{
+					Name:        "github-release",
+					Usage:       "Use the latest GitHub release of the git repository",
+					Destination: &addUpstreamYaml.GitHubRelease,
+				},
+				&cli.StringFlag{
+					Name:        "fetch",
+					Usage:       "Value of Fetch in upstream.yaml (latest, newer or all)",
+					Destination: &addUpstreamYaml.Fetch,
+				},
+				&cli.BoolFlag{
+					Name:        "update",
+					Usage:       "Integrate chart versions from the upstream after creating the package",
+					Destination: &addRunUpdate,
+				},
+			},
+		},
+		{
+			Name:      "move",
+			Usage:     "Move a package and all of its associated chart versions to a different vendor",
+			Action:    movePackage,
+			ArgsUsage: "[flags] <old vendor>/<chart> <new vendor>/<chart>",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:        "display-name",
+					Usage:       "Set DisplayName in upstream.yaml and the display name annotation of all chart versions",
+					Destination: &moveDisplayName,
+				},
+				&cli.StringFlag{
+					Name:        "vendor",
+					Usage:       "Set Vendor in upstream.yaml",
+					Destination: &moveVendor,
+				},
+			},
 		},
 		{
 			Name:      "remove",
@@ -1166,11 +3662,19 @@ func main() {
 			Action:    deprecatePackage,
 			ArgsUsage: "<package>",
 		},
+		{
+			Name:      "undeprecate",
+			Usage:     "Undeprecate a package and all of its associated chart versions",
+			Action:    undeprecatePackage,
+			ArgsUsage: "<package>",
+		},
 	}
 
 	err := app.Run(os.Args)
 	if err != nil {
-		fmt.Printf("error: %s", err)
+		// stdout may contain a machine-readable report, such as the
+		// output of validate --format json, so errors go to stderr
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
 		os.Exit(1)
 	}
 }
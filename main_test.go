@@ -1,15 +1,28 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 
+	"github.com/rancher/partner-charts-ci/pkg/conform"
+	"github.com/rancher/partner-charts-ci/pkg/fetcher"
 	p "github.com/rancher/partner-charts-ci/pkg/paths"
 	"github.com/rancher/partner-charts-ci/pkg/pkg"
 	"github.com/rancher/partner-charts-ci/pkg/upstreamyaml"
+	"github.com/rancher/partner-charts-ci/pkg/validate"
 	"github.com/stretchr/testify/assert"
 
 	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/repo"
 )
 
 func getPaths(t *testing.T, repoRoot string) p.Paths {
@@ -23,9 +36,93 @@ func getPaths(t *testing.T, repoRoot string) p.Paths {
 		Icons:             filepath.Join(assets, "icons"),
 		IndexYaml:         filepath.Join(repoRoot, "index.yaml"),
 		Packages:          filepath.Join(repoRoot, "packages"),
+		StaleYaml:         filepath.Join(repoRoot, "stale.yaml"),
 	}
 }
 
+// getPackagePaths returns paths for a repository in a temporary directory
+// and changes to that directory, since pkg.ListPackageWrappers requires
+// the packages directory to be relative.
+func getPackagePaths(t *testing.T) p.Paths {
+	t.Helper()
+	repoRoot := t.TempDir()
+	t.Chdir(repoRoot)
+	paths := getPaths(t, repoRoot)
+	paths.Packages = "packages"
+	return paths
+}
+
+// writeTestPackage creates package <vendor>/<chartName> with the given
+// upstream.yaml contents and an icon, writes one chart version for each
+// of versions to assets/ and charts/, and regenerates index.yaml.
+func writeTestPackage(t *testing.T, paths p.Paths, vendor, chartName, upstreamYaml string, versions ...string) {
+	t.Helper()
+	packageDir := filepath.Join(paths.Packages, vendor, chartName)
+	if err := os.MkdirAll(packageDir, 0o755); err != nil {
+		t.Fatalf("failed to create %s: %s", packageDir, err)
+	}
+	if err := os.WriteFile(filepath.Join(packageDir, upstreamYamlFile), []byte(upstreamYaml), 0o644); err != nil {
+		t.Fatalf("failed to write upstream.yaml: %s", err)
+	}
+	if err := os.MkdirAll(paths.Icons, 0o755); err != nil {
+		t.Fatalf("failed to create %s: %s", paths.Icons, err)
+	}
+	if err := os.WriteFile(filepath.Join(paths.Icons, chartName+".png"), []byte("icon"), 0o644); err != nil {
+		t.Fatalf("failed to write icon: %s", err)
+	}
+	chartWrappers := make([]*ChartWrapper, 0, len(versions))
+	for _, version := range versions {
+		chartWrappers = append(chartWrappers, NewChartWrapper(&chart.Chart{
+			Metadata: &chart.Metadata{
+				APIVersion:  "v2",
+				Name:        chartName,
+				Version:     version,
+				Annotations: map[string]string{annotationDisplayName: chartName},
+			},
+		}))
+	}
+	if err := writeCharts(paths, vendor, chartName, chartWrappers); err != nil {
+		t.Fatalf("failed to write charts: %s", err)
+	}
+	if err := writeIndex(paths); err != nil {
+		t.Fatalf("failed to write index: %s", err)
+	}
+}
+
+// loadChartsYaml loads the Chart.yaml of each chart version of package
+// <vendor>/<chartName> in charts/, keyed by version.
+func loadChartsYaml(t *testing.T, paths p.Paths, vendor, chartName string) map[string]*chart.Metadata {
+	t.Helper()
+	chartYamlPaths, err := filepath.Glob(filepath.Join(paths.Charts, vendor, chartName, "*", "Chart.yaml"))
+	if err != nil {
+		t.Fatalf("failed to glob for Chart.yaml files: %s", err)
+	}
+	chartsYaml := map[string]*chart.Metadata{}
+	for _, chartYamlPath := range chartYamlPaths {
+		metadata, err := chartutil.LoadChartfile(chartYamlPath)
+		if err != nil {
+			t.Fatalf("failed to load %s: %s", chartYamlPath, err)
+		}
+		chartsYaml[metadata.Version] = metadata
+	}
+	return chartsYaml
+}
+
+// getIndexCreated returns the created field of each version of chartName
+// in index.yaml, keyed by version.
+func getIndexCreated(t *testing.T, paths p.Paths, chartName string) map[string]time.Time {
+	t.Helper()
+	indexYaml, err := repo.LoadIndexFile(paths.IndexYaml)
+	if err != nil {
+		t.Fatalf("failed to load index.yaml: %s", err)
+	}
+	created := map[string]time.Time{}
+	for _, chartVersion := range indexYaml.Entries[chartName] {
+		created[chartVersion.Version] = chartVersion.Created
+	}
+	return created
+}
+
 func TestMain(t *testing.T) {
 	t.Run("applyOverlayFiles", func(t *testing.T) {
 		t.Run("should add files that do not already exist", func(t *testing.T) {
@@ -77,6 +174,29 @@ func TestMain(t *testing.T) {
 	})
 
 	t.Run("addAnnotations", func(t *testing.T) {
+		t.Run("should set catalog annotations from upstream.yaml over those of the upstream chart", func(t *testing.T) {
+			packageWrapper := pkg.PackageWrapper{
+				UpstreamYaml: &upstreamyaml.UpstreamYaml{
+					PermitsOS:      []string{"linux", "windows"},
+					RancherVersion: ">= 2.9.0-0",
+				},
+			}
+			helmChart := &chart.Chart{
+				Metadata: &chart.Metadata{
+					Annotations: map[string]string{
+						upstreamyaml.AnnotationRancherVersion: ">= 2.7.0-0",
+						upstreamyaml.AnnotationScope:          "downstream",
+					},
+				},
+			}
+			if err := addAnnotations(packageWrapper, helmChart); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			assert.Equal(t, "linux,windows", helmChart.Metadata.Annotations[upstreamyaml.AnnotationPermitsOS])
+			assert.Equal(t, ">= 2.9.0-0", helmChart.Metadata.Annotations[upstreamyaml.AnnotationRancherVersion])
+			assert.Equal(t, "downstream", helmChart.Metadata.Annotations[upstreamyaml.AnnotationScope])
+		})
+
 		t.Run("should set auto-install annotation properly", func(t *testing.T) {
 			for _, autoInstall := range []string{"", "some-chart"} {
 				packageWrapper := pkg.PackageWrapper{
@@ -297,6 +417,52 @@ func TestMain(t *testing.T) {
 				assert.Equal(t, testCase.ExpectedValue, value)
 			}
 		})
+
+		t.Run("should normalize kube-version annotation unless PreserveKubeVersion is set", func(t *testing.T) {
+			testCases := []struct {
+				CurrentAnnotation   string
+				KubeVersion         string
+				PreserveKubeVersion bool
+				ExpectedValue       string
+			}{
+				{
+					KubeVersion:   ">=1.21",
+					ExpectedValue: ">=1.21-0",
+				},
+				{
+					KubeVersion:         ">=1.21",
+					PreserveKubeVersion: true,
+					ExpectedValue:       ">=1.21",
+				},
+				{
+					CurrentAnnotation: ">=1.25",
+					KubeVersion:       ">=1.21",
+					ExpectedValue:     ">=1.25-0",
+				},
+			}
+			for _, testCase := range testCases {
+				packageWrapper := pkg.PackageWrapper{
+					UpstreamYaml: &upstreamyaml.UpstreamYaml{
+						PreserveKubeVersion: testCase.PreserveKubeVersion,
+					},
+				}
+				helmChart := &chart.Chart{
+					Metadata: &chart.Metadata{
+						KubeVersion:  testCase.KubeVersion,
+						Dependencies: []*chart.Dependency{},
+					},
+				}
+				if testCase.CurrentAnnotation != "" {
+					helmChart.Metadata.Annotations = map[string]string{
+						annotationKubeVersion: testCase.CurrentAnnotation,
+					}
+				}
+				if err := addAnnotations(packageWrapper, helmChart); err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				assert.Equal(t, testCase.ExpectedValue, helmChart.Metadata.Annotations[annotationKubeVersion])
+			}
+		})
 	})
 
 	t.Run("ensureFeaturedAnnotation", func(t *testing.T) {
@@ -380,14 +546,16 @@ func TestMain(t *testing.T) {
 				{
 					Chart: &chart.Chart{
 						Metadata: &chart.Metadata{
-							Name: "existingChart1",
+							Name:    "existingChart1",
+							Version: "1.0.0",
 						},
 					},
 				},
 				{
 					Chart: &chart.Chart{
 						Metadata: &chart.Metadata{
-							Name: "existingChart2",
+							Name:    "existingChart2",
+							Version: "1.1.0",
 							Annotations: map[string]string{
 								annotationFeatured: featuredAnnotationValue,
 							},
@@ -399,14 +567,16 @@ func TestMain(t *testing.T) {
 				{
 					Chart: &chart.Chart{
 						Metadata: &chart.Metadata{
-							Name: "newChart1",
+							Name:    "newChart1",
+							Version: "1.2.0",
 						},
 					},
 				},
 				{
 					Chart: &chart.Chart{
 						Metadata: &chart.Metadata{
-							Name: "newChart2",
+							Name:    "newChart2",
+							Version: "1.3.0",
 						},
 					},
 				},
@@ -437,6 +607,62 @@ func TestMain(t *testing.T) {
 				assert.False(t, chartWrapper.Modified)
 			}
 		})
+
+		newChartWrapper := func(version string, annotations map[string]string) *ChartWrapper {
+			return NewChartWrapper(&chart.Chart{
+				Metadata: &chart.Metadata{
+					Name:        "testChart",
+					Version:     version,
+					Annotations: annotations,
+				},
+			})
+		}
+
+		t.Run("should put featured annotation on highest version when new charts are not in order", func(t *testing.T) {
+			existingCharts := []*ChartWrapper{
+				newChartWrapper("1.0.0", map[string]string{annotationFeatured: "3"}),
+			}
+			newCharts := []*ChartWrapper{
+				newChartWrapper("1.10.0", nil),
+				newChartWrapper("1.9.0", nil),
+			}
+			if err := ensureFeaturedAnnotation(existingCharts, newCharts); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			assert.Equal(t, "3", newCharts[0].Metadata.Annotations[annotationFeatured])
+			assert.NotContains(t, newCharts[1].Metadata.Annotations, annotationFeatured)
+			assert.NotContains(t, existingCharts[0].Metadata.Annotations, annotationFeatured)
+			assert.True(t, newCharts[0].Modified)
+			assert.False(t, newCharts[1].Modified)
+			assert.True(t, existingCharts[0].Modified)
+		})
+
+		t.Run("should leave featured annotation on existing chart when it is the highest version", func(t *testing.T) {
+			existingCharts := []*ChartWrapper{
+				newChartWrapper("2.0.0", map[string]string{annotationFeatured: "1"}),
+			}
+			newCharts := []*ChartWrapper{
+				newChartWrapper("1.5.0", map[string]string{annotationFeatured: "1"}),
+			}
+			if err := ensureFeaturedAnnotation(existingCharts, newCharts); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			assert.Equal(t, "1", existingCharts[0].Metadata.Annotations[annotationFeatured])
+			assert.NotContains(t, newCharts[0].Metadata.Annotations, annotationFeatured)
+			assert.False(t, existingCharts[0].Modified)
+			assert.True(t, newCharts[0].Modified)
+		})
+
+		t.Run("should return error when a chart version is not valid semver", func(t *testing.T) {
+			existingCharts := []*ChartWrapper{
+				newChartWrapper("1.0.0", map[string]string{annotationFeatured: "1"}),
+			}
+			newCharts := []*ChartWrapper{
+				newChartWrapper("not-a-version", nil),
+			}
+			err := ensureFeaturedAnnotation(existingCharts, newCharts)
+			assert.ErrorContains(t, err, `failed to parse version "not-a-version"`)
+		})
 	})
 
 	t.Run("loadExistingCharts", func(t *testing.T) {
@@ -608,4 +834,1442 @@ func TestMain(t *testing.T) {
 			assert.Nil(t, newChartsFromDisk[1].Metadata.Annotations)
 		})
 	})
+
+	t.Run("ensureDependencies", func(t *testing.T) {
+		newParentChart := func(dependencyVersion, repository string) *chart.Chart {
+			return &chart.Chart{
+				Metadata: &chart.Metadata{
+					APIVersion: "v2",
+					Name:       "parent",
+					Version:    "1.0.0",
+					Dependencies: []*chart.Dependency{
+						{
+							Name:       "subchart",
+							Version:    dependencyVersion,
+							Repository: repository,
+						},
+					},
+				},
+			}
+		}
+		newSubchart := func(version string) *chart.Chart {
+			return &chart.Chart{
+				Metadata: &chart.Metadata{
+					APIVersion: "v2",
+					Name:       "subchart",
+					Version:    version,
+				},
+			}
+		}
+
+		t.Run("should succeed when all dependencies are present", func(t *testing.T) {
+			helmChart := newParentChart("1.x", "")
+			helmChart.AddDependency(newSubchart("1.2.3"))
+			assert.Nil(t, ensureDependencies(helmChart, helmChart.Metadata.Dependencies))
+		})
+
+		t.Run("should return error when subchart does not satisfy version constraint", func(t *testing.T) {
+			helmChart := newParentChart("2.x", "")
+			helmChart.AddDependency(newSubchart("1.2.3"))
+			assert.ErrorContains(t, ensureDependencies(helmChart, helmChart.Metadata.Dependencies), `subchart "subchart" has version 1.2.3, which does not satisfy "2.x"`)
+		})
+
+		t.Run("should return error when subchart does not match Chart.lock", func(t *testing.T) {
+			helmChart := newParentChart("1.x", "")
+			helmChart.Lock = &chart.Lock{
+				Dependencies: []*chart.Dependency{
+					{
+						Name:    "subchart",
+						Version: "1.2.4",
+					},
+				},
+			}
+			helmChart.AddDependency(newSubchart("1.2.3"))
+			assert.ErrorContains(t, ensureDependencies(helmChart, helmChart.Metadata.Dependencies), "Chart.lock requires 1.2.4")
+		})
+
+		t.Run("should match Chart.lock entries by name and repository", func(t *testing.T) {
+			helmChart := newParentChart("1.x", "https://charts.example.com")
+			helmChart.Lock = &chart.Lock{
+				Dependencies: []*chart.Dependency{
+					{
+						Name:       "subchart",
+						Version:    "1.2.4",
+						Repository: "https://other.example.com",
+					},
+					{
+						Name:       "subchart",
+						Version:    "1.2.3",
+						Repository: "https://charts.example.com",
+					},
+				},
+			}
+			helmChart.AddDependency(newSubchart("1.2.3"))
+			assert.Nil(t, ensureDependencies(helmChart, helmChart.Metadata.Dependencies))
+		})
+
+		t.Run("should return error when dependency is declared more than once", func(t *testing.T) {
+			helmChart := newParentChart("1.x", "")
+			helmChart.Metadata.Dependencies = append(helmChart.Metadata.Dependencies, helmChart.Metadata.Dependencies[0])
+			helmChart.AddDependency(newSubchart("1.2.3"))
+			assert.ErrorContains(t, ensureDependencies(helmChart, helmChart.Metadata.Dependencies), `dependency "subchart" is declared more than once`)
+		})
+
+		t.Run("should return error when missing dependency has no repository", func(t *testing.T) {
+			helmChart := newParentChart("1.x", "")
+			assert.ErrorContains(t, ensureDependencies(helmChart, helmChart.Metadata.Dependencies), "has no repository")
+		})
+
+		t.Run("should vendor missing dependency from its repository", func(t *testing.T) {
+			repoDir := t.TempDir()
+			for _, version := range []string{"1.2.3", "1.3.0", "2.0.0"} {
+				if _, err := chartutil.Save(newSubchart(version), repoDir); err != nil {
+					t.Fatalf("failed to save subchart: %s", err)
+				}
+			}
+			server := httptest.NewServer(http.FileServer(http.Dir(repoDir)))
+			defer server.Close()
+			indexFile, err := repo.IndexDirectory(repoDir, server.URL)
+			if err != nil {
+				t.Fatalf("failed to index repository: %s", err)
+			}
+			if err := indexFile.WriteFile(filepath.Join(repoDir, "index.yaml"), 0o644); err != nil {
+				t.Fatalf("failed to write index.yaml: %s", err)
+			}
+
+			helmChart := newParentChart("1.x", server.URL)
+			if err := ensureDependencies(helmChart, helmChart.Metadata.Dependencies); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			assert.Len(t, helmChart.Dependencies(), 1)
+			assert.Equal(t, "1.3.0", helmChart.Dependencies()[0].Metadata.Version)
+
+			lockedChart := newParentChart("1.x", server.URL)
+			lockedChart.Lock = &chart.Lock{
+				Dependencies: []*chart.Dependency{
+					{
+						Name:       "subchart",
+						Version:    "1.2.3",
+						Repository: server.URL,
+					},
+				},
+			}
+			if err := ensureDependencies(lockedChart, lockedChart.Metadata.Dependencies); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			assert.Len(t, lockedChart.Dependencies(), 1)
+			assert.Equal(t, "1.2.3", lockedChart.Dependencies()[0].Metadata.Version)
+		})
+	})
+
+	t.Run("integrateCharts", func(t *testing.T) {
+		t.Run("should reject new charts whose dependencies cannot be completed", func(t *testing.T) {
+			paths := getPaths(t, t.TempDir())
+			if err := os.MkdirAll(paths.Icons, 0o755); err != nil {
+				t.Fatalf("failed to create %s: %s", paths.Icons, err)
+			}
+			if err := os.WriteFile(filepath.Join(paths.Icons, "testchart.png"), []byte("icon"), 0o644); err != nil {
+				t.Fatalf("failed to write icon: %s", err)
+			}
+			packageWrapper := pkg.PackageWrapper{
+				Name:         "testchart",
+				Vendor:       "vendor",
+				Path:         filepath.Join(paths.Packages, "vendor", "testchart"),
+				UpstreamYaml: &upstreamyaml.UpstreamYaml{},
+			}
+			newChart := func(version string, dependencies ...*chart.Dependency) *ChartWrapper {
+				return NewChartWrapper(&chart.Chart{
+					Metadata: &chart.Metadata{
+						APIVersion:   "v2",
+						Name:         "testchart",
+						Version:      version,
+						Dependencies: dependencies,
+					},
+				})
+			}
+			newCharts := []*ChartWrapper{
+				newChart("1.0.0"),
+				newChart("1.1.0", &chart.Dependency{Name: "subchart", Version: "1.x"}),
+				newChart("1.2.0"),
+			}
+
+			integratedCharts, err := integrateCharts(paths, packageWrapper, []*ChartWrapper{}, newCharts)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			versions := make([]string, 0, len(integratedCharts))
+			for _, integratedChart := range integratedCharts {
+				versions = append(versions, integratedChart.Metadata.Version)
+			}
+			assert.Equal(t, []string{"1.0.0", "1.2.0"}, versions)
+		})
+
+		t.Run("should not check dependencies added by upstream.yaml against Chart.lock", func(t *testing.T) {
+			paths := getPaths(t, t.TempDir())
+			if err := os.MkdirAll(paths.Icons, 0o755); err != nil {
+				t.Fatalf("failed to create %s: %s", paths.Icons, err)
+			}
+			if err := os.WriteFile(filepath.Join(paths.Icons, "testchart.png"), []byte("icon"), 0o644); err != nil {
+				t.Fatalf("failed to write icon: %s", err)
+			}
+			packageWrapper := pkg.PackageWrapper{
+				Name:   "testchart",
+				Vendor: "vendor",
+				Path:   filepath.Join(paths.Packages, "vendor", "testchart"),
+				UpstreamYaml: &upstreamyaml.UpstreamYaml{
+					ChartMetadata: chart.Metadata{
+						Dependencies: []*chart.Dependency{{Name: "extra", Version: "1.x"}},
+					},
+				},
+			}
+			helmChart := &chart.Chart{
+				Metadata: &chart.Metadata{
+					APIVersion:   "v2",
+					Name:         "testchart",
+					Version:      "1.0.0",
+					Dependencies: []*chart.Dependency{{Name: "subchart", Version: "1.x"}},
+				},
+				Lock: &chart.Lock{
+					Dependencies: []*chart.Dependency{{Name: "subchart", Version: "1.2.3"}},
+				},
+			}
+			for _, name := range []string{"subchart", "extra"} {
+				helmChart.AddDependency(&chart.Chart{
+					Metadata: &chart.Metadata{APIVersion: "v2", Name: name, Version: "1.2.3"},
+				})
+			}
+
+			integratedCharts, err := integrateCharts(paths, packageWrapper, []*ChartWrapper{}, []*ChartWrapper{NewChartWrapper(helmChart)})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if assert.Len(t, integratedCharts, 1) {
+				assert.Len(t, integratedCharts[0].Metadata.Dependencies, 2)
+			}
+		})
+
+		t.Run("should reject only new charts that use removed APIs", func(t *testing.T) {
+			paths := getPaths(t, t.TempDir())
+			if err := os.MkdirAll(paths.Icons, 0o755); err != nil {
+				t.Fatalf("failed to create %s: %s", paths.Icons, err)
+			}
+			if err := os.WriteFile(filepath.Join(paths.Icons, "testchart.png"), []byte("icon"), 0o644); err != nil {
+				t.Fatalf("failed to write icon: %s", err)
+			}
+			packageWrapper := pkg.PackageWrapper{
+				Name:         "testchart",
+				Vendor:       "vendor",
+				Path:         filepath.Join(paths.Packages, "vendor", "testchart"),
+				UpstreamYaml: &upstreamyaml.UpstreamYaml{},
+			}
+			newChart := func(version, apiVersion string) *ChartWrapper {
+				podSecurityPolicy := "apiVersion: " + apiVersion + "\nkind: PodSecurityPolicy\nmetadata:\n  name: testchart\n"
+				return NewChartWrapper(&chart.Chart{
+					Metadata: &chart.Metadata{APIVersion: "v2", Name: "testchart", Version: version, KubeVersion: ">=1.21-0"},
+					Templates: []*chart.File{
+						{Name: "templates/psp.yaml", Data: []byte(podSecurityPolicy)},
+					},
+				})
+			}
+			newCharts := []*ChartWrapper{
+				newChart("1.0.0", "policy/v1beta1"),
+				newChart("1.1.0", "policy/v1"),
+			}
+
+			integratedCharts, err := integrateCharts(paths, packageWrapper, []*ChartWrapper{}, newCharts)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if assert.Len(t, integratedCharts, 1) {
+				assert.Equal(t, "1.1.0", integratedCharts[0].Metadata.Version)
+			}
+		})
+	})
+
+	t.Run("ApplyUpdates", func(t *testing.T) {
+		t.Run("should return only the chart versions that were integrated", func(t *testing.T) {
+			paths := getPackagePaths(t)
+			writeTestPackage(t, paths, "vendor", "testchart", "HelmRepo: https://charts.example.com\nHelmChart: testchart\n")
+			packageWrappers, err := pkg.ListPackageWrappers(paths, "vendor/testchart")
+			if err != nil {
+				t.Fatalf("failed to list packages: %s", err)
+			}
+			packageWrapper := packageWrappers[0]
+
+			repoDir := t.TempDir()
+			server := httptest.NewServer(http.FileServer(http.Dir(repoDir)))
+			defer server.Close()
+			packageWrapper.SourceMetadata = &fetcher.ChartSourceMetadata{Source: "HelmRepo"}
+			for _, version := range []string{"1.0.0", "1.1.0"} {
+				helmChart := &chart.Chart{
+					Metadata: &chart.Metadata{APIVersion: "v2", Name: "testchart", Version: version},
+				}
+				if version == "1.1.0" {
+					helmChart.Metadata.Dependencies = []*chart.Dependency{{Name: "subchart", Version: "1.x"}}
+				}
+				if _, err := chartutil.Save(helmChart, repoDir); err != nil {
+					t.Fatalf("failed to save chart: %s", err)
+				}
+				packageWrapper.FetchVersions = append(packageWrapper.FetchVersions, &repo.ChartVersion{
+					Metadata: helmChart.Metadata,
+					URLs:     []string{server.URL + "/testchart-" + version + ".tgz"},
+				})
+			}
+
+			integratedVersions, err := ApplyUpdates(paths, packageWrapper)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if assert.Len(t, integratedVersions, 1) {
+				assert.Equal(t, "1.0.0", integratedVersions[0].Version)
+			}
+			chartsYaml := loadChartsYaml(t, paths, "vendor", "testchart")
+			assert.Contains(t, chartsYaml, "1.0.0")
+			assert.NotContains(t, chartsYaml, "1.1.0")
+		})
+	})
+
+	t.Run("getFeaturedListing", func(t *testing.T) {
+		newChartVersion := func(name, version, featured string) *repo.ChartVersion {
+			chartVersion := &repo.ChartVersion{
+				Metadata: &chart.Metadata{
+					Name:        name,
+					Version:     version,
+					Annotations: map[string]string{},
+				},
+			}
+			if featured != "" {
+				chartVersion.Annotations[annotationFeatured] = featured
+			}
+			return chartVersion
+		}
+		packageNames := map[string]string{
+			"chart1": "vendor1/chart1",
+			"chart2": "vendor2/chart2",
+			"chart3": "vendor3/chart3",
+		}
+
+		t.Run("should list featured packages by slot", func(t *testing.T) {
+			indexYaml := &repo.IndexFile{
+				Entries: map[string]repo.ChartVersions{
+					"chart1": {newChartVersion("chart1", "1.0.0", "2")},
+					"chart2": {newChartVersion("chart2", "2.0.0", "1"), newChartVersion("chart2", "1.0.0", "")},
+					"chart3": {newChartVersion("chart3", "1.0.0", "")},
+				},
+			}
+			listing := getFeaturedListing(indexYaml, packageNames)
+			assert.Equal(t, []featuredSlot{
+				{Slot: 1, Packages: []string{"vendor2/chart2"}},
+				{Slot: 2, Packages: []string{"vendor1/chart1"}},
+			}, listing.Slots)
+			assert.Empty(t, listing.Invalid)
+		})
+
+		t.Run("should report conflicts and invalid values", func(t *testing.T) {
+			indexYaml := &repo.IndexFile{
+				Entries: map[string]repo.ChartVersions{
+					"chart1":   {newChartVersion("chart1", "1.0.0", "3")},
+					"chart2":   {newChartVersion("chart2", "1.0.0", "3")},
+					"chart3":   {newChartVersion("chart3", "1.0.0", "first")},
+					"orphaned": {newChartVersion("orphaned", "1.0.0", "6")},
+				},
+			}
+			listing := getFeaturedListing(indexYaml, packageNames)
+			assert.Equal(t, []featuredSlot{
+				{Slot: 3, Packages: []string{"vendor1/chart1", "vendor2/chart2"}, Conflict: true},
+			}, listing.Slots)
+			assert.Equal(t, []invalidFeaturedValue{
+				{Package: "orphaned", Value: "6"},
+				{Package: "vendor3/chart3", Value: "first"},
+			}, listing.Invalid)
+		})
+	})
+
+	t.Run("packageStatus", func(t *testing.T) {
+		now := time.Date(2025, 6, 11, 0, 0, 0, 0, time.UTC)
+		newChartVersion := func(version string, created time.Time, annotations map[string]string) *repo.ChartVersion {
+			return &repo.ChartVersion{
+				Metadata: &chart.Metadata{Name: "chart1", Version: version, Annotations: annotations},
+				Created:  created,
+			}
+		}
+		packageWrapper := pkg.PackageWrapper{
+			Vendor: "vendor1",
+			Name:   "chart1",
+			UpstreamYaml: &upstreamyaml.UpstreamYaml{
+				HelmRepo:   "oci://ghcr.io/example/charts",
+				HelmChart:  "chart1",
+				Deprecated: true,
+			},
+		}
+
+		t.Run("getPackageStatus should read flags from latest version and age from latest Created", func(t *testing.T) {
+			lastIntegrated := now.AddDate(0, 0, -10)
+			chartVersions := repo.ChartVersions{
+				newChartVersion("2.0.0", now.AddDate(0, 0, -30), map[string]string{annotationHidden: "true", annotationFeatured: "2"}),
+				newChartVersion("1.5.0", lastIntegrated, map[string]string{annotationFeatured: "1"}),
+			}
+			status := getPackageStatus(packageWrapper, chartVersions, now)
+			assert.Equal(t, "vendor1/chart1", status.Package)
+			assert.Equal(t, "OCI", status.Source)
+			assert.Equal(t, "2.0.0", status.LatestStored)
+			assert.True(t, status.Hidden)
+			assert.True(t, status.Deprecated)
+			assert.Equal(t, "2", status.Featured)
+			if assert.NotNil(t, status.LastIntegrated) && assert.NotNil(t, status.AgeDays) {
+				assert.Equal(t, lastIntegrated, *status.LastIntegrated)
+				assert.Equal(t, 10, *status.AgeDays)
+			}
+		})
+
+		t.Run("getPackageStatus should handle package without chart versions", func(t *testing.T) {
+			status := getPackageStatus(packageWrapper, nil, now)
+			assert.Equal(t, "", status.LatestStored)
+			assert.Nil(t, status.LastIntegrated)
+		})
+
+		t.Run("setUpstreamStatus should count newer upstream versions and ignore pre-releases", func(t *testing.T) {
+			status := packageStatus{LatestStored: "1.0.0"}
+			upstreamVersions := repo.ChartVersions{
+				newChartVersion("2.0.0-rc.1", now, nil),
+				newChartVersion("1.2.0", now, nil),
+				newChartVersion("1.1.0", now, nil),
+				newChartVersion("1.0.0", now, nil),
+				newChartVersion("0.9.0", now, nil),
+			}
+			assert.Nil(t, setUpstreamStatus(&status, upstreamVersions))
+			assert.Equal(t, "1.2.0", status.LatestUpstream)
+			assert.Equal(t, 2, status.VersionsBehind)
+		})
+
+		t.Run("writeStatus should write markdown table", func(t *testing.T) {
+			ageDays := 10
+			lastIntegrated := now.AddDate(0, 0, -ageDays)
+			statuses := []packageStatus{
+				{Package: "vendor1/chart1", Source: "HelmRepo", LatestStored: "1.0.0", LatestUpstream: "1.2.0", VersionsBehind: 2, Featured: "1", LastIntegrated: &lastIntegrated, AgeDays: &ageDays},
+				{Package: "vendor2/chart2", Source: "Git", Deprecated: true},
+			}
+			buffer := &bytes.Buffer{}
+			if !assert.Nil(t, writeStatus(buffer, "markdown", statuses)) {
+				return
+			}
+			assert.Equal(t, "| Package | Source | Stored | Upstream | Behind | Hidden | Deprecated | Featured | Last integrated |\n"+
+				"| --- | --- | --- | --- | --- | --- | --- | --- | --- |\n"+
+				"| vendor1/chart1 | HelmRepo | 1.0.0 | 1.2.0 | 2 | false | false | 1 | 2025-06-01 (10d ago) |\n"+
+				"| vendor2/chart2 | Git | - | - | - | false | true | - | - |\n", buffer.String())
+		})
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		now := time.Date(2025, 6, 11, 0, 0, 0, 0, time.UTC)
+		newChartVersion := func(version string, created time.Time) *repo.ChartVersion {
+			return &repo.ChartVersion{
+				Metadata: &chart.Metadata{Name: "chart1", Version: version},
+				Created:  created,
+			}
+		}
+
+		t.Run("recordUpstreamResult should count consecutive failures and reset them on success", func(t *testing.T) {
+			history := &upstreamHistory{}
+			recordUpstreamResult(history, nil, errors.New("404 Not Found"), now)
+			recordUpstreamResult(history, nil, errors.New("404 Not Found"), now)
+			assert.Equal(t, 2, history.ConsecutiveFailures)
+			assert.Equal(t, "404 Not Found", history.LastError)
+
+			recordUpstreamResult(history, repo.ChartVersions{newChartVersion("1.0.0", time.Time{})}, nil, now)
+			assert.Equal(t, 0, history.ConsecutiveFailures)
+			assert.Equal(t, "", history.LastError)
+			assert.Equal(t, &now, history.LastSuccess)
+		})
+
+		t.Run("recordUpstreamResult should use Created of latest non-prerelease version", func(t *testing.T) {
+			created := now.AddDate(-1, 0, 0)
+			history := &upstreamHistory{}
+			upstreamVersions := repo.ChartVersions{
+				newChartVersion("2.0.0-rc.1", now),
+				newChartVersion("1.1.0", created),
+				newChartVersion("1.0.0", created.AddDate(0, -1, 0)),
+			}
+			recordUpstreamResult(history, upstreamVersions, nil, now)
+			assert.Equal(t, "1.1.0", history.LatestVersion)
+			assert.Equal(t, &created, history.LatestRelease)
+		})
+
+		t.Run("recordUpstreamResult should use time latest version was first seen if Created is not known", func(t *testing.T) {
+			firstSeen := now.AddDate(0, 0, -100)
+			history := &upstreamHistory{}
+			recordUpstreamResult(history, repo.ChartVersions{newChartVersion("1.0.0", time.Time{})}, nil, firstSeen)
+			recordUpstreamResult(history, repo.ChartVersions{newChartVersion("1.0.0", time.Time{})}, nil, now)
+			assert.Equal(t, &firstSeen, history.LatestRelease)
+
+			recordUpstreamResult(history, repo.ChartVersions{newChartVersion("1.1.0", time.Time{})}, nil, now)
+			assert.Equal(t, &now, history.LatestRelease)
+		})
+
+		t.Run("getStaleReasons", func(t *testing.T) {
+			oldRelease := now.AddDate(0, 0, -400)
+			newRelease := now.AddDate(0, 0, -10)
+			testCases := []struct {
+				Description     string
+				History         upstreamHistory
+				ExpectedReasons []string
+			}{
+				{
+					Description: "should not report healthy upstream",
+					History:     upstreamHistory{ConsecutiveFailures: 2, LatestVersion: "1.0.0", LatestRelease: &newRelease},
+				},
+				{
+					Description:     "should report unreachable upstream",
+					History:         upstreamHistory{ConsecutiveFailures: 3, LastError: "404 Not Found"},
+					ExpectedReasons: []string{"upstream could not be fetched in 3 runs in a row: 404 Not Found"},
+				},
+				{
+					Description:     "should report upstream without recent release",
+					History:         upstreamHistory{LatestVersion: "1.0.0", LatestRelease: &oldRelease},
+					ExpectedReasons: []string{"latest upstream version 1.0.0 was released 400 days ago"},
+				},
+			}
+			for _, testCase := range testCases {
+				t.Run(testCase.Description, func(t *testing.T) {
+					assert.Equal(t, testCase.ExpectedReasons, getStaleReasons(testCase.History, 3, 365, now))
+				})
+			}
+		})
+
+		t.Run("recordUpstreamResults should update stale.yaml", func(t *testing.T) {
+			staleYamlPath := filepath.Join(t.TempDir(), "stale.yaml")
+			state := staleState{Packages: map[string]*upstreamHistory{
+				"vendor1/chart1": {ConsecutiveFailures: 2, LastError: "404 Not Found"},
+				"vendor2/chart2": {ConsecutiveFailures: 1, LastError: "404 Not Found"},
+			}}
+			if !assert.Nil(t, writeStaleState(staleYamlPath, state)) {
+				return
+			}
+
+			results := map[string]upstreamResult{
+				"vendor1/chart1": {err: errors.New("404 Not Found")},
+				"vendor2/chart2": {upstreamVersions: repo.ChartVersions{newChartVersion("1.0.0", now)}},
+			}
+			if !assert.Nil(t, recordUpstreamResults(staleYamlPath, results, now)) {
+				return
+			}
+			readState, err := readStaleState(staleYamlPath)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, 3, readState.Packages["vendor1/chart1"].ConsecutiveFailures)
+			assert.Equal(t, 0, readState.Packages["vendor2/chart2"].ConsecutiveFailures)
+			assert.Equal(t, "1.0.0", readState.Packages["vendor2/chart2"].LatestVersion)
+		})
+
+		t.Run("readStaleState and writeStaleState should round-trip", func(t *testing.T) {
+			staleYamlPath := filepath.Join(t.TempDir(), "stale.yaml")
+			state, err := readStaleState(staleYamlPath)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Empty(t, state.Packages)
+
+			state.Packages["vendor1/chart1"] = &upstreamHistory{ConsecutiveFailures: 1, LastError: "404 Not Found", LatestVersion: "1.0.0", LatestRelease: &now}
+			if !assert.Nil(t, writeStaleState(staleYamlPath, state)) {
+				return
+			}
+			readState, err := readStaleState(staleYamlPath)
+			assert.Nil(t, err)
+			assert.Equal(t, state, readState)
+		})
+	})
+
+	t.Run("show", func(t *testing.T) {
+		t.Run("getPackageInfo should list overlay files, icon and chart versions with key annotations", func(t *testing.T) {
+			paths := getPaths(t, t.TempDir())
+			packagePath := filepath.Join(paths.Packages, "vendor1", "chart1")
+			for _, filePath := range []string{
+				filepath.Join(packagePath, "overlay", "app-readme.md"),
+				filepath.Join(packagePath, "overlay", "templates", "extra.yaml"),
+				filepath.Join(paths.Icons, "chart1.png"),
+			} {
+				if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+					t.Fatalf("failed to create parent of %s: %s", filePath, err)
+				}
+				if err := os.WriteFile(filePath, []byte("test"), 0o644); err != nil {
+					t.Fatalf("failed to write %s: %s", filePath, err)
+				}
+			}
+			packageWrapper := pkg.PackageWrapper{
+				Path:         packagePath,
+				Vendor:       "vendor1",
+				Name:         "chart1",
+				UpstreamYaml: &upstreamyaml.UpstreamYaml{GitRepo: "https://github.com/example/example"},
+			}
+			created := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+			chartVersions := repo.ChartVersions{
+				{
+					Metadata: &chart.Metadata{
+						Name:        "chart1",
+						Version:     "1.0.0",
+						AppVersion:  "v1",
+						Annotations: map[string]string{annotationHidden: "true", "example.com/other": "value"},
+					},
+					Created: created,
+				},
+			}
+
+			info, err := getPackageInfo(paths, packageWrapper, chartVersions)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, "vendor1/chart1", info.Package)
+			assert.Equal(t, []string{"app-readme.md", "templates/extra.yaml"}, info.OverlayFiles)
+			assert.Equal(t, filepath.Join(paths.Icons, "chart1.png"), info.Icon)
+			assert.Equal(t, []chartVersionInfo{
+				{
+					Version:     "1.0.0",
+					AppVersion:  "v1",
+					Created:     created,
+					Annotations: map[string]string{annotationHidden: "true"},
+				},
+			}, info.Versions)
+		})
+
+		t.Run("isPackageFinding", func(t *testing.T) {
+			testCases := []struct {
+				Description string
+				Finding     validate.Finding
+				Expected    bool
+			}{
+				{
+					Description: "should match finding with package",
+					Finding:     validate.Finding{Package: "vendor1/chart1"},
+					Expected:    true,
+				},
+				{
+					Description: "should match finding with file in package directory",
+					Finding:     validate.Finding{File: "packages/vendor1/chart1/upstream.yaml"},
+					Expected:    true,
+				},
+				{
+					Description: "should not match finding with file in package directory with same prefix",
+					Finding:     validate.Finding{File: "charts/vendor1/chart10/1.0.0/Chart.yaml"},
+				},
+				{
+					Description: "should not match finding of other package",
+					Finding:     validate.Finding{Package: "vendor2/chart1"},
+				},
+				{
+					Description: "should match finding with icon of package",
+					Finding:     validate.Finding{File: "assets/icons/chart1.png"},
+					Expected:    true,
+				},
+				{
+					Description: "should not match finding with icon of other package",
+					Finding:     validate.Finding{File: "assets/icons/chart10.png"},
+				},
+				{
+					Description: "should match finding with chart archive of package",
+					Finding:     validate.Finding{File: "assets/vendor1/chart1-1.0.0.tgz"},
+					Expected:    true,
+				},
+				{
+					Description: "should not match finding with chart archive of package with same prefix",
+					Finding:     validate.Finding{File: "assets/vendor1/chart1-extra-1.0.0.tgz"},
+				},
+			}
+			for _, testCase := range testCases {
+				t.Run(testCase.Description, func(t *testing.T) {
+					assert.Equal(t, testCase.Expected, isPackageFinding(testCase.Finding, "vendor1", "chart1"))
+				})
+			}
+		})
+	})
+
+	t.Run("parseAnnotationArg", func(t *testing.T) {
+		t.Run("annotatable annotations should be ignored by released chart validation", func(t *testing.T) {
+			for _, annotation := range annotatableAnnotations {
+				assert.True(t, validate.IsIgnoredAnnotation(annotation), annotation)
+			}
+		})
+
+		testCases := []struct {
+			Description        string
+			Arg                string
+			ValueOptional      bool
+			ExpectedAnnotation string
+			ExpectedValue      string
+			ExpectedError      string
+		}{
+			{
+				Description:        "should add catalog.cattle.io prefix",
+				Arg:                "permits-os=linux,windows",
+				ExpectedAnnotation: "catalog.cattle.io/permits-os",
+				ExpectedValue:      "linux,windows",
+			},
+			{
+				Description:        "should accept full annotation",
+				Arg:                "catalog.cattle.io/rancher-version=>= 2.9.0-0",
+				ExpectedAnnotation: "catalog.cattle.io/rancher-version",
+				ExpectedValue:      ">= 2.9.0-0",
+			},
+			{
+				Description:        "should accept annotation without value if value is optional",
+				Arg:                "ui-component",
+				ValueOptional:      true,
+				ExpectedAnnotation: "catalog.cattle.io/ui-component",
+			},
+			{
+				Description:   "should require value",
+				Arg:           "ui-component",
+				ExpectedError: `annotation "ui-component" is not in <key>=<value> format`,
+			},
+			{
+				Description:   "should reject empty value",
+				Arg:           "ui-component=",
+				ExpectedError: "annotation catalog.cattle.io/ui-component must have a value",
+			},
+			{
+				Description:        "should accept empty value if value is optional",
+				Arg:                "ui-component=",
+				ValueOptional:      true,
+				ExpectedAnnotation: "catalog.cattle.io/ui-component",
+			},
+			{
+				Description:   "should reject annotation managed by another command",
+				Arg:           "hidden=true",
+				ExpectedError: "annotation catalog.cattle.io/hidden cannot be changed with annotate",
+			},
+			{
+				Description:   "should reject annotation that is not a catalog.cattle.io annotation",
+				Arg:           "example.com/team=a",
+				ExpectedError: "annotation example.com/team cannot be changed with annotate",
+			},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.Description, func(t *testing.T) {
+				annotation, value, err := parseAnnotationArg(testCase.Arg, testCase.ValueOptional)
+				if testCase.ExpectedError != "" {
+					assert.ErrorContains(t, err, testCase.ExpectedError)
+					return
+				}
+				assert.Nil(t, err)
+				assert.Equal(t, testCase.ExpectedAnnotation, annotation)
+				assert.Equal(t, testCase.ExpectedValue, value)
+			})
+		}
+	})
+
+	t.Run("annotateChartVersions", func(t *testing.T) {
+		upstreamYaml := "HelmRepo: https://charts.example.com\nHelmChart: testchart\n"
+
+		t.Run("should annotate chart archives, charts and index.yaml", func(t *testing.T) {
+			paths := getPackagePaths(t)
+			writeTestPackage(t, paths, "vendor", "testchart", upstreamYaml, "1.0.0", "1.1.0")
+			created := getIndexCreated(t, paths, "testchart")
+
+			if err := annotateChartVersions(paths, "vendor/testchart", upstreamyaml.AnnotationUIComponent, "testchart", false, false); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			chartWrappers, err := loadExistingCharts(paths, "vendor", "testchart")
+			if err != nil {
+				t.Fatalf("failed to load charts: %s", err)
+			}
+			assert.Len(t, chartWrappers, 2)
+			for _, chartWrapper := range chartWrappers {
+				assert.Equal(t, "testchart", chartWrapper.Metadata.Annotations[upstreamyaml.AnnotationUIComponent])
+			}
+			chartsYaml := loadChartsYaml(t, paths, "vendor", "testchart")
+			assert.Len(t, chartsYaml, 2)
+			for _, metadata := range chartsYaml {
+				assert.Equal(t, "testchart", metadata.Annotations[upstreamyaml.AnnotationUIComponent])
+			}
+			indexYaml, err := repo.LoadIndexFile(paths.IndexYaml)
+			if err != nil {
+				t.Fatalf("failed to load index.yaml: %s", err)
+			}
+			assert.Len(t, indexYaml.Entries["testchart"], 2)
+			for _, chartVersion := range indexYaml.Entries["testchart"] {
+				assert.Equal(t, "testchart", chartVersion.Annotations[upstreamyaml.AnnotationUIComponent])
+			}
+			assert.Equal(t, created, getIndexCreated(t, paths, "testchart"))
+		})
+
+		t.Run("should reject value that upstream.yaml would reject", func(t *testing.T) {
+			paths := getPackagePaths(t)
+			writeTestPackage(t, paths, "vendor", "testchart", upstreamYaml, "1.0.0")
+			err := annotateChartVersions(paths, "vendor/testchart", upstreamyaml.AnnotationPermitsOS, "linux,darwin", false, false)
+			assert.ErrorContains(t, err, `catalog.cattle.io/permits-os must be one of "linux", "windows" but is "darwin"`)
+			chartsYaml := loadChartsYaml(t, paths, "vendor", "testchart")
+			assert.NotContains(t, chartsYaml["1.0.0"].Annotations, upstreamyaml.AnnotationPermitsOS)
+		})
+
+		t.Run("should reject package without chart versions", func(t *testing.T) {
+			paths := getPackagePaths(t)
+			writeTestPackage(t, paths, "vendor", "testchart", upstreamYaml)
+			for _, latestOnly := range []bool{false, true} {
+				err := annotateChartVersions(paths, "vendor/testchart", upstreamyaml.AnnotationUIComponent, "testchart", false, latestOnly)
+				assert.ErrorContains(t, err, "vendor/testchart has no chart versions")
+			}
+		})
+	})
+
+	t.Run("parseFeaturedSlots", func(t *testing.T) {
+		t.Run("should assign slots in order", func(t *testing.T) {
+			slots, err := parseFeaturedSlots([]string{"vendor1/chart1", "vendor2/chart2"})
+			assert.Nil(t, err)
+			assert.Equal(t, map[string]int{"vendor1/chart1": 1, "vendor2/chart2": 2}, slots)
+		})
+
+		t.Run("should return error for too many packages", func(t *testing.T) {
+			_, err := parseFeaturedSlots([]string{"a/a", "b/b", "c/c", "d/d", "e/e", "f/f"})
+			assert.ErrorContains(t, err, "got 6 packages but there are only 5 featured slots")
+		})
+
+		t.Run("should return error for duplicate packages", func(t *testing.T) {
+			_, err := parseFeaturedSlots([]string{"a/a", "b/b", "a/a"})
+			assert.ErrorContains(t, err, `package "a/a" is given more than once`)
+		})
+	})
+
+	t.Run("applyFeaturedSlots", func(t *testing.T) {
+		upstreamYaml := "HelmRepo: https://charts.example.com\nHelmChart: chart\n"
+		setUp := func(t *testing.T) (p.Paths, []pkg.PackageWrapper) {
+			t.Helper()
+			paths := getPackagePaths(t)
+			writeTestPackage(t, paths, "vendor1", "chart1", upstreamYaml, "1.0.0")
+			writeTestPackage(t, paths, "vendor2", "chart2", upstreamYaml, "1.0.0")
+			if err := applyFeaturedSlots(paths, []pkg.PackageWrapper{{Vendor: "vendor1", Name: "chart1"}}, map[string]int{"vendor1/chart1": 1}); err != nil {
+				t.Fatalf("failed to feature vendor1/chart1: %s", err)
+			}
+			return paths, []pkg.PackageWrapper{
+				{Vendor: "vendor1", Name: "chart1"},
+				{Vendor: "vendor2", Name: "chart2"},
+			}
+		}
+		getFeatured := func(t *testing.T, paths p.Paths, vendor, chartName string) string {
+			t.Helper()
+			chartWrappers, err := loadExistingCharts(paths, vendor, chartName)
+			if err != nil {
+				t.Fatalf("failed to load charts: %s", err)
+			}
+			return chartWrappers[0].Metadata.Annotations[annotationFeatured]
+		}
+
+		t.Run("should move featured annotation between packages", func(t *testing.T) {
+			paths, packageWrappers := setUp(t)
+			if err := applyFeaturedSlots(paths, packageWrappers, map[string]int{"vendor2/chart2": 1}); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			assert.Empty(t, getFeatured(t, paths, "vendor1", "chart1"))
+			assert.Equal(t, "1", getFeatured(t, paths, "vendor2", "chart2"))
+			indexYaml, err := repo.LoadIndexFile(paths.IndexYaml)
+			if err != nil {
+				t.Fatalf("failed to load index.yaml: %s", err)
+			}
+			assert.Equal(t, "1", indexYaml.Entries["chart2"][0].Annotations[annotationFeatured])
+			assert.NotContains(t, indexYaml.Entries["chart1"][0].Annotations, annotationFeatured)
+		})
+
+		t.Run("should restore packages already written when writing fails", func(t *testing.T) {
+			paths, packageWrappers := setUp(t)
+			tgzPath := filepath.Join(paths.Assets, "vendor1", "chart1-1.0.0.tgz")
+			originalContents, err := os.ReadFile(tgzPath)
+			if err != nil {
+				t.Fatalf("failed to read %s: %s", tgzPath, err)
+			}
+			// charts/vendor2 being a file makes writing vendor2/chart2 fail
+			if err := os.RemoveAll(filepath.Join(paths.Charts, "vendor2")); err != nil {
+				t.Fatalf("failed to remove charts directory: %s", err)
+			}
+			if err := os.WriteFile(filepath.Join(paths.Charts, "vendor2"), []byte{}, 0o644); err != nil {
+				t.Fatalf("failed to write file: %s", err)
+			}
+
+			err = applyFeaturedSlots(paths, packageWrappers, map[string]int{"vendor2/chart2": 1})
+			assert.ErrorContains(t, err, "failed to write charts for vendor2/chart2")
+
+			contents, err := os.ReadFile(tgzPath)
+			if err != nil {
+				t.Fatalf("failed to read %s: %s", tgzPath, err)
+			}
+			assert.Equal(t, originalContents, contents)
+			match, err := conform.ChartDirectoryMatchesArchive(tgzPath, filepath.Join(paths.Charts, "vendor1", "chart1", "1.0.0"))
+			assert.Nil(t, err)
+			assert.True(t, match)
+			assert.Empty(t, getFeatured(t, paths, "vendor2", "chart2"))
+		})
+	})
+
+	t.Run("checkPackageNameAvailable", func(t *testing.T) {
+		packageWrappers := []pkg.PackageWrapper{
+			{Vendor: "vendor1", Name: "chart1"},
+			{Vendor: "vendor2", Name: "chart2"},
+		}
+
+		t.Run("should return nil when name is not used", func(t *testing.T) {
+			assert.Nil(t, checkPackageNameAvailable(packageWrappers, "chart3"))
+		})
+
+		t.Run("should return error when name is used by another vendor", func(t *testing.T) {
+			err := checkPackageNameAvailable(packageWrappers, "chart2")
+			assert.ErrorContains(t, err, `package name "chart2" is already used by vendor2/chart2`)
+		})
+	})
+
+	t.Run("prefillUpstreamYaml", func(t *testing.T) {
+		metadata := &chart.Metadata{
+			Name:        "testchart",
+			KubeVersion: ">=1.25",
+			Maintainers: []*chart.Maintainer{
+				{Name: "Test Vendor"},
+			},
+			Annotations: map[string]string{
+				annotationDisplayName: "Test Chart",
+			},
+		}
+
+		t.Run("should fill in fields from chart metadata", func(t *testing.T) {
+			upstreamYaml := &upstreamyaml.UpstreamYaml{}
+			prefillUpstreamYaml(upstreamYaml, "vendor", "testchart", metadata)
+			assert.Equal(t, "Test Chart", upstreamYaml.DisplayName)
+			assert.Equal(t, "vendor", upstreamYaml.Vendor)
+			assert.Equal(t, "", upstreamYaml.ChartMetadata.Name)
+			assert.Equal(t, ">=1.25-0", upstreamYaml.ChartMetadata.KubeVersion)
+		})
+
+		t.Run("should use chart name when there is no display name annotation", func(t *testing.T) {
+			upstreamYaml := &upstreamyaml.UpstreamYaml{}
+			prefillUpstreamYaml(upstreamYaml, "vendor", "testchart", &chart.Metadata{Name: "testchart"})
+			assert.Equal(t, "testchart", upstreamYaml.DisplayName)
+			assert.Equal(t, "", upstreamYaml.ChartMetadata.KubeVersion)
+		})
+
+		t.Run("should rename chart when upstream chart name differs from package name", func(t *testing.T) {
+			upstreamYaml := &upstreamyaml.UpstreamYaml{}
+			prefillUpstreamYaml(upstreamYaml, "vendor", "otherchart", metadata)
+			assert.Equal(t, "otherchart", upstreamYaml.ChartMetadata.Name)
+		})
+
+		t.Run("should not change fields that are already set", func(t *testing.T) {
+			upstreamYaml := &upstreamyaml.UpstreamYaml{
+				DisplayName: "Existing Name",
+				Vendor:      "Existing Vendor",
+			}
+			prefillUpstreamYaml(upstreamYaml, "vendor", "testchart", metadata)
+			assert.Equal(t, "Existing Name", upstreamYaml.DisplayName)
+			assert.Equal(t, "Existing Vendor", upstreamYaml.Vendor)
+		})
+	})
+
+	t.Run("writeNewPackage", func(t *testing.T) {
+		t.Run("should remove package directory when writing fails", func(t *testing.T) {
+			packagePath := filepath.Join(t.TempDir(), "packages", "vendor", "testchart")
+			// a directory where app-readme.md should be makes writing it fail
+			if err := os.MkdirAll(filepath.Join(packagePath, "overlay", "app-readme.md"), 0o755); err != nil {
+				t.Fatalf("failed to create directory: %s", err)
+			}
+			upstreamYaml := &upstreamyaml.UpstreamYaml{HelmRepo: "https://charts.example.com", HelmChart: "testchart"}
+			err := writeNewPackage(packagePath, upstreamYaml, &chart.Metadata{Name: "testchart"})
+			assert.ErrorContains(t, err, "app-readme.md")
+			assert.NoDirExists(t, packagePath)
+		})
+	})
+
+	t.Run("splitPackageName", func(t *testing.T) {
+		t.Run("should split full package name", func(t *testing.T) {
+			vendor, name, err := splitPackageName("vendor1/chart1")
+			assert.Nil(t, err)
+			assert.Equal(t, "vendor1", vendor)
+			assert.Equal(t, "chart1", name)
+		})
+
+		t.Run("should return error for invalid package names", func(t *testing.T) {
+			for _, fullName := range []string{"chart1", "/chart1", "vendor1/", "vendor1/chart1/extra"} {
+				_, _, err := splitPackageName(fullName)
+				assert.ErrorContains(t, err, "is not in <vendor>/<chart> format", fullName)
+			}
+		})
+	})
+
+	t.Run("getCulledPaths", func(t *testing.T) {
+		t.Run("should only return paths that exist", func(t *testing.T) {
+			paths := getPackagePaths(t)
+			writeTestPackage(t, paths, "vendor", "testchart", "HelmRepo: https://charts.example.com\nHelmChart: testchart\n", "1.0.0", "1.1.0")
+			removedChartDir := filepath.Join(paths.Charts, "vendor", "testchart", "1.0.0")
+			if err := os.RemoveAll(removedChartDir); err != nil {
+				t.Fatalf("failed to remove %s: %s", removedChartDir, err)
+			}
+			chartWrappers, err := loadExistingCharts(paths, "vendor", "testchart")
+			if err != nil {
+				t.Fatalf("failed to load charts: %s", err)
+			}
+			removedCharts := slices.DeleteFunc(chartWrappers, func(chartWrapper *ChartWrapper) bool {
+				return chartWrapper.Metadata.Version != "1.0.0"
+			})
+
+			culledPaths, err := getCulledPaths(paths, "vendor", "testchart", removedCharts)
+			assert.Nil(t, err)
+			assert.Equal(t, []string{filepath.Join(paths.Assets, "vendor", "testchart-1.0.0.tgz")}, culledPaths)
+		})
+	})
+
+	t.Run("fixAnnotationMismatches", func(t *testing.T) {
+		t.Run("should only fix currentPackage when it is set", func(t *testing.T) {
+			paths := getPackagePaths(t)
+			for _, chartName := range []string{"testchart", "otherchart"} {
+				upstreamYaml := "HelmRepo: https://charts.example.com\nHelmChart: " + chartName + "\nHidden: true\n"
+				writeTestPackage(t, paths, "vendor", chartName, upstreamYaml, "1.0.0")
+			}
+
+			if err := fixAnnotationMismatches(paths, "vendor/testchart"); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			assert.Equal(t, "true", loadChartsYaml(t, paths, "vendor", "testchart")["1.0.0"].Annotations[annotationHidden])
+			assert.NotContains(t, loadChartsYaml(t, paths, "vendor", "otherchart")["1.0.0"].Annotations, annotationHidden)
+		})
+	})
+
+	t.Run("unhidePackage", func(t *testing.T) {
+		t.Run("should unhide package and all of its chart versions", func(t *testing.T) {
+			paths := getPackagePaths(t)
+			upstreamYaml := "HelmRepo: https://charts.example.com\nHelmChart: testchart\nHidden: true\n"
+			writeTestPackage(t, paths, "vendor", "testchart", upstreamYaml, "1.0.0", "1.1.0")
+			if err := annotate(paths, "vendor", "testchart", annotationHidden, "true", false, false); err != nil {
+				t.Fatalf("failed to hide chart versions: %s", err)
+			}
+			if err := writeIndex(paths); err != nil {
+				t.Fatalf("failed to write index: %s", err)
+			}
+			created := getIndexCreated(t, paths, "testchart")
+
+			if err := unhidePackage(paths, "vendor/testchart"); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			parsedUpstreamYaml, err := upstreamyaml.Parse(filepath.Join(paths.Packages, "vendor", "testchart", upstreamYamlFile))
+			if err != nil {
+				t.Fatalf("failed to parse upstream.yaml: %s", err)
+			}
+			assert.False(t, parsedUpstreamYaml.Hidden)
+			chartWrappers, err := loadExistingCharts(paths, "vendor", "testchart")
+			if err != nil {
+				t.Fatalf("failed to load charts: %s", err)
+			}
+			assert.Len(t, chartWrappers, 2)
+			for _, chartWrapper := range chartWrappers {
+				assert.NotContains(t, chartWrapper.Metadata.Annotations, annotationHidden)
+			}
+			chartsYaml := loadChartsYaml(t, paths, "vendor", "testchart")
+			assert.Len(t, chartsYaml, 2)
+			for _, metadata := range chartsYaml {
+				assert.NotContains(t, metadata.Annotations, annotationHidden)
+			}
+			indexYaml, err := repo.LoadIndexFile(paths.IndexYaml)
+			if err != nil {
+				t.Fatalf("failed to load index.yaml: %s", err)
+			}
+			for _, chartVersion := range indexYaml.Entries["testchart"] {
+				assert.NotContains(t, chartVersion.Annotations, annotationHidden)
+			}
+			assert.Equal(t, created, getIndexCreated(t, paths, "testchart"))
+		})
+	})
+
+	t.Run("undeprecate", func(t *testing.T) {
+		t.Run("should undeprecate package and all of its chart versions", func(t *testing.T) {
+			paths := getPackagePaths(t)
+			upstreamYaml := "HelmRepo: https://charts.example.com\nHelmChart: testchart\nDeprecated: true\n"
+			writeTestPackage(t, paths, "vendor", "testchart", upstreamYaml, "1.0.0", "1.1.0")
+			if err := setChartsDeprecated(paths, "vendor", "testchart", true); err != nil {
+				t.Fatalf("failed to deprecate chart versions: %s", err)
+			}
+			if err := writeIndex(paths); err != nil {
+				t.Fatalf("failed to write index: %s", err)
+			}
+			created := getIndexCreated(t, paths, "testchart")
+
+			if err := undeprecate(paths, "vendor/testchart"); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			parsedUpstreamYaml, err := upstreamyaml.Parse(filepath.Join(paths.Packages, "vendor", "testchart", upstreamYamlFile))
+			if err != nil {
+				t.Fatalf("failed to parse upstream.yaml: %s", err)
+			}
+			assert.False(t, parsedUpstreamYaml.Deprecated)
+			chartWrappers, err := loadExistingCharts(paths, "vendor", "testchart")
+			if err != nil {
+				t.Fatalf("failed to load charts: %s", err)
+			}
+			assert.Len(t, chartWrappers, 2)
+			for _, chartWrapper := range chartWrappers {
+				assert.False(t, chartWrapper.Metadata.Deprecated)
+			}
+			chartsYaml := loadChartsYaml(t, paths, "vendor", "testchart")
+			assert.Len(t, chartsYaml, 2)
+			for _, metadata := range chartsYaml {
+				assert.False(t, metadata.Deprecated)
+			}
+			indexYaml, err := repo.LoadIndexFile(paths.IndexYaml)
+			if err != nil {
+				t.Fatalf("failed to load index.yaml: %s", err)
+			}
+			for _, chartVersion := range indexYaml.Entries["testchart"] {
+				assert.False(t, chartVersion.Deprecated)
+			}
+			assert.Equal(t, created, getIndexCreated(t, paths, "testchart"))
+		})
+	})
+
+	t.Run("movePackageFiles", func(t *testing.T) {
+		upstreamYaml := "HelmRepo: https://charts.example.com\nHelmChart: testchart\n"
+		versions := []string{"1.0.0", "1.1.0"}
+		// setUp writes vendor1/testchart and returns copies of its chart
+		// archives, keyed by version.
+		setUp := func(t *testing.T) (p.Paths, map[string]string) {
+			t.Helper()
+			paths := getPackagePaths(t)
+			writeTestPackage(t, paths, "vendor1", "testchart", upstreamYaml, versions...)
+			originals := map[string]string{}
+			copyDir := t.TempDir()
+			for _, version := range versions {
+				contents, err := os.ReadFile(filepath.Join(paths.Assets, "vendor1", "testchart-"+version+".tgz"))
+				if err != nil {
+					t.Fatalf("failed to read chart archive: %s", err)
+				}
+				originals[version] = filepath.Join(copyDir, "testchart-"+version+".tgz")
+				if err := os.WriteFile(originals[version], contents, 0o644); err != nil {
+					t.Fatalf("failed to copy chart archive: %s", err)
+				}
+			}
+			return paths, originals
+		}
+		assertFileContentsEqual := func(t *testing.T, expectedPath, actualPath string) {
+			t.Helper()
+			expected, err := os.ReadFile(expectedPath)
+			if err != nil {
+				t.Fatalf("failed to read %s: %s", expectedPath, err)
+			}
+			actual, err := os.ReadFile(actualPath)
+			if assert.Nil(t, err) {
+				assert.Equal(t, expected, actual)
+			}
+		}
+
+		t.Run("should move package, chart archives and charts directory", func(t *testing.T) {
+			paths, originals := setUp(t)
+			created := getIndexCreated(t, paths, "testchart")
+
+			if err := movePackageFiles(paths, "vendor1/testchart", "vendor2/testchart", "", ""); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			assert.FileExists(t, filepath.Join(paths.Packages, "vendor2", "testchart", upstreamYamlFile))
+			for _, dir := range []string{paths.Packages, paths.Assets, paths.Charts} {
+				assert.NoDirExists(t, filepath.Join(dir, "vendor1"))
+			}
+			for _, version := range versions {
+				tgzPath := filepath.Join(paths.Assets, "vendor2", "testchart-"+version+".tgz")
+				assertFileContentsEqual(t, originals[version], tgzPath)
+				match, err := conform.ChartDirectoryMatchesArchive(tgzPath, filepath.Join(paths.Charts, "vendor2", "testchart", version))
+				assert.Nil(t, err)
+				assert.True(t, match)
+			}
+			indexYaml, err := repo.LoadIndexFile(paths.IndexYaml)
+			if err != nil {
+				t.Fatalf("failed to load index.yaml: %s", err)
+			}
+			for _, chartVersion := range indexYaml.Entries["testchart"] {
+				assert.Equal(t, []string{"assets/vendor2/testchart-" + chartVersion.Version + ".tgz"}, chartVersion.URLs)
+			}
+			assert.Equal(t, created, getIndexCreated(t, paths, "testchart"))
+		})
+
+		t.Run("should only change display-name annotation when display name is given", func(t *testing.T) {
+			paths, originals := setUp(t)
+
+			if err := movePackageFiles(paths, "vendor1/testchart", "vendor2/testchart", "New Name", ""); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			for _, version := range versions {
+				tgzPath := filepath.Join(paths.Assets, "vendor2", "testchart-"+version+".tgz")
+				chartDiff, err := validate.DiffHelmCharts(originals[version], tgzPath)
+				if assert.Nil(t, err) {
+					assert.True(t, chartDiff.Match(), chartDiff.String())
+				}
+				originalChart, err := loader.LoadFile(originals[version])
+				if err != nil {
+					t.Fatalf("failed to load %s: %s", originals[version], err)
+				}
+				movedChart, err := loader.LoadFile(tgzPath)
+				if err != nil {
+					t.Fatalf("failed to load %s: %s", tgzPath, err)
+				}
+				assert.Equal(t, "New Name", movedChart.Metadata.Annotations[annotationDisplayName])
+				delete(originalChart.Metadata.Annotations, annotationDisplayName)
+				delete(movedChart.Metadata.Annotations, annotationDisplayName)
+				assert.Equal(t, originalChart.Metadata.Annotations, movedChart.Metadata.Annotations)
+			}
+			parsedUpstreamYaml, err := upstreamyaml.Parse(filepath.Join(paths.Packages, "vendor2", "testchart", upstreamYamlFile))
+			if assert.Nil(t, err) {
+				assert.Equal(t, "New Name", parsedUpstreamYaml.DisplayName)
+			}
+		})
+
+		t.Run("should not move anything if a destination exists", func(t *testing.T) {
+			paths, originals := setUp(t)
+			existingPath := filepath.Join(paths.Assets, "vendor2", "testchart-1.1.0.tgz")
+			if err := os.MkdirAll(filepath.Dir(existingPath), 0o755); err != nil {
+				t.Fatalf("failed to create directory: %s", err)
+			}
+			if err := os.WriteFile(existingPath, []byte("existing"), 0o644); err != nil {
+				t.Fatalf("failed to write %s: %s", existingPath, err)
+			}
+
+			err := movePackageFiles(paths, "vendor1/testchart", "vendor2/testchart", "", "")
+			assert.ErrorContains(t, err, "assets/vendor2/testchart-1.1.0.tgz already exists")
+
+			assert.FileExists(t, filepath.Join(paths.Packages, "vendor1", "testchart", upstreamYamlFile))
+			assert.NoDirExists(t, filepath.Join(paths.Packages, "vendor2"))
+			assert.NoDirExists(t, filepath.Join(paths.Charts, "vendor2"))
+			for _, version := range versions {
+				assertFileContentsEqual(t, originals[version], filepath.Join(paths.Assets, "vendor1", "testchart-"+version+".tgz"))
+			}
+		})
+
+		t.Run("should undo the move if a later step fails", func(t *testing.T) {
+			paths, originals := setUp(t)
+			upstreamYamlPath := filepath.Join(paths.Packages, "vendor1", "testchart", upstreamYamlFile)
+			originalUpstreamYaml, err := os.ReadFile(upstreamYamlPath)
+			if err != nil {
+				t.Fatalf("failed to read upstream.yaml: %s", err)
+			}
+			// writeIndex fails without an icon for the chart
+			if err := os.Remove(filepath.Join(paths.Icons, "testchart.png")); err != nil {
+				t.Fatalf("failed to remove icon: %s", err)
+			}
+
+			err = movePackageFiles(paths, "vendor1/testchart", "vendor2/testchart", "New Name", "")
+			assert.ErrorContains(t, err, "failed to write index")
+
+			upstreamYamlContents, err := os.ReadFile(upstreamYamlPath)
+			if assert.Nil(t, err) {
+				assert.Equal(t, originalUpstreamYaml, upstreamYamlContents)
+			}
+			for _, dir := range []string{paths.Packages, paths.Assets, paths.Charts} {
+				assert.NoDirExists(t, filepath.Join(dir, "vendor2"))
+			}
+			for _, version := range versions {
+				tgzPath := filepath.Join(paths.Assets, "vendor1", "testchart-"+version+".tgz")
+				assertFileContentsEqual(t, originals[version], tgzPath)
+				match, err := conform.ChartDirectoryMatchesArchive(tgzPath, filepath.Join(paths.Charts, "vendor1", "testchart", version))
+				assert.Nil(t, err)
+				assert.True(t, match)
+			}
+		})
+	})
+
+	t.Run("removeDirIfEmpty", func(t *testing.T) {
+		t.Run("should remove empty directory", func(t *testing.T) {
+			dirPath := filepath.Join(t.TempDir(), "empty")
+			if err := os.Mkdir(dirPath, 0o755); err != nil {
+				t.Fatalf("failed to create %s: %s", dirPath, err)
+			}
+			assert.Nil(t, removeDirIfEmpty(dirPath))
+			assert.NoDirExists(t, dirPath)
+		})
+
+		t.Run("should not remove directory with files", func(t *testing.T) {
+			dirPath := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dirPath, "file"), []byte{}, 0o644); err != nil {
+				t.Fatalf("failed to write file: %s", err)
+			}
+			assert.Nil(t, removeDirIfEmpty(dirPath))
+			assert.DirExists(t, dirPath)
+		})
+
+		t.Run("should return nil when directory does not exist", func(t *testing.T) {
+			assert.Nil(t, removeDirIfEmpty(filepath.Join(t.TempDir(), "missing")))
+		})
+	})
+
+	t.Run("repairChartsDirectory", func(t *testing.T) {
+		vendor := "testVendor"
+		chartName := "testChart"
+		writeTestCharts := func(t *testing.T) p.Paths {
+			t.Helper()
+			paths := getPaths(t, t.TempDir())
+			chartWrappers := []*ChartWrapper{
+				NewChartWrapper(&chart.Chart{
+					Metadata: &chart.Metadata{APIVersion: "v2", Name: chartName, Version: "2.3.4"},
+				}),
+				NewChartWrapper(&chart.Chart{
+					Metadata: &chart.Metadata{APIVersion: "v2", Name: chartName, Version: "1.2.3"},
+				}),
+			}
+			if err := writeCharts(paths, vendor, chartName, chartWrappers); err != nil {
+				t.Fatalf("unexpected error in writeCharts: %s", err)
+			}
+			return paths
+		}
+
+		t.Run("should not change a consistent repository", func(t *testing.T) {
+			paths := writeTestCharts(t)
+			fixes, err := repairChartsDirectory(paths)
+			assert.Nil(t, err)
+			assert.Empty(t, fixes)
+		})
+
+		t.Run("should regenerate missing and modified chart directories", func(t *testing.T) {
+			paths := writeTestCharts(t)
+			missingDir := filepath.Join(paths.Charts, vendor, chartName, "2.3.4")
+			if err := os.RemoveAll(missingDir); err != nil {
+				t.Fatalf("failed to remove %s: %s", missingDir, err)
+			}
+			modifiedFile := filepath.Join(paths.Charts, vendor, chartName, "1.2.3", "Chart.yaml")
+			if err := os.WriteFile(modifiedFile, []byte("name: modified\n"), 0o644); err != nil {
+				t.Fatalf("failed to write %s: %s", modifiedFile, err)
+			}
+
+			fixes, err := repairChartsDirectory(paths)
+			assert.Nil(t, err)
+			assert.Len(t, fixes, 2)
+			chartsFromDisk, err := loadExistingCharts(paths, vendor, chartName)
+			if err != nil {
+				t.Fatalf("unexpected error in loadExistingCharts: %s", err)
+			}
+			for _, chartWrapper := range chartsFromDisk {
+				chartDir := filepath.Join(paths.Charts, vendor, chartName, chartWrapper.Metadata.Version)
+				tgzPath := filepath.Join(paths.Assets, vendor, getTgzFilename(chartWrapper.Chart))
+				match, err := conform.ChartDirectoryMatchesArchive(tgzPath, chartDir)
+				assert.Nil(t, err)
+				assert.True(t, match)
+			}
+		})
+
+		t.Run("should remove chart directories without a chart archive", func(t *testing.T) {
+			paths := writeTestCharts(t)
+			orphanDirs := []string{
+				filepath.Join(paths.Charts, vendor, chartName, "0.1.0"),
+				filepath.Join(paths.Charts, "otherVendor", "otherChart", "1.0.0"),
+			}
+			for _, orphanDir := range orphanDirs {
+				if err := os.MkdirAll(orphanDir, 0o755); err != nil {
+					t.Fatalf("failed to create %s: %s", orphanDir, err)
+				}
+			}
+
+			fixes, err := repairChartsDirectory(paths)
+			assert.Nil(t, err)
+			assert.Len(t, fixes, 2)
+			assert.NoDirExists(t, orphanDirs[0])
+			assert.NoDirExists(t, filepath.Join(paths.Charts, "otherVendor"))
+			assert.DirExists(t, filepath.Join(paths.Charts, vendor, chartName, "1.2.3"))
+		})
+
+		t.Run("should leave charts of a vendor with an invalid chart archive alone", func(t *testing.T) {
+			paths := writeTestCharts(t)
+			invalidTgz := filepath.Join(paths.Assets, vendor, "broken-1.0.0.tgz")
+			if err := os.WriteFile(invalidTgz, []byte("not an archive"), 0o644); err != nil {
+				t.Fatalf("failed to write %s: %s", invalidTgz, err)
+			}
+			orphanDir := filepath.Join(paths.Charts, vendor, "broken", "1.0.0")
+			if err := os.MkdirAll(orphanDir, 0o755); err != nil {
+				t.Fatalf("failed to create %s: %s", orphanDir, err)
+			}
+
+			_, err := repairChartsDirectory(paths)
+			assert.ErrorContains(t, err, "failed to load "+invalidTgz)
+			assert.DirExists(t, orphanDir)
+		})
+	})
+
+	t.Run("selectChartsToKeep", func(t *testing.T) {
+		newChartWrappers := func(versions ...string) []*ChartWrapper {
+			chartWrappers := make([]*ChartWrapper, 0, len(versions))
+			for _, version := range versions {
+				chartWrappers = append(chartWrappers, NewChartWrapper(&chart.Chart{
+					Metadata: &chart.Metadata{Name: "testchart", Version: version},
+				}))
+			}
+			return chartWrappers
+		}
+		getVersions := func(chartWrappers []*ChartWrapper) []string {
+			versions := make([]string, 0, len(chartWrappers))
+			for _, chartWrapper := range chartWrappers {
+				versions = append(versions, chartWrapper.Metadata.Version)
+			}
+			return versions
+		}
+		chartWrappers := newChartWrappers("2.0.0", "1.1.1", "1.1.0", "1.0.1", "1.0.0")
+
+		t.Run("should keep newest versions", func(t *testing.T) {
+			kept, removed, err := selectChartsToKeep(chartWrappers, upstreamyaml.Retention{KeepNewest: 2}, nil)
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"2.0.0", "1.1.1"}, getVersions(kept))
+			assert.Equal(t, []string{"1.1.0", "1.0.1", "1.0.0"}, getVersions(removed))
+		})
+
+		t.Run("should keep latest patch of each minor version", func(t *testing.T) {
+			kept, removed, err := selectChartsToKeep(chartWrappers, upstreamyaml.Retention{KeepLatestPatches: true}, nil)
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"2.0.0", "1.1.1", "1.0.1"}, getVersions(kept))
+			assert.Equal(t, []string{"1.1.0", "1.0.0"}, getVersions(removed))
+		})
+
+		t.Run("should keep pinned and always kept versions", func(t *testing.T) {
+			retention := upstreamyaml.Retention{KeepNewest: 1, Pinned: []string{"v1.0.0"}}
+			kept, removed, err := selectChartsToKeep(chartWrappers, retention, map[string]bool{"1.1.0": true})
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"2.0.0", "1.1.0", "1.0.0"}, getVersions(kept))
+			assert.Equal(t, []string{"1.1.1", "1.0.1"}, getVersions(removed))
+		})
+
+		t.Run("should remove all versions when no rule keeps them", func(t *testing.T) {
+			kept, removed, err := selectChartsToKeep(chartWrappers, upstreamyaml.Retention{}, nil)
+			assert.Nil(t, err)
+			assert.Empty(t, kept)
+			assert.Len(t, removed, 5)
+		})
+	})
+
+	t.Run("parsePins", func(t *testing.T) {
+		t.Run("should parse pins by package", func(t *testing.T) {
+			pins, err := parsePins([]string{"vendor1/chart1=1.0.0", "vendor1/chart1=1.1.0", "vendor2/chart2=2.0.0"})
+			assert.Nil(t, err)
+			assert.Equal(t, map[string][]string{
+				"vendor1/chart1": {"1.0.0", "1.1.0"},
+				"vendor2/chart2": {"2.0.0"},
+			}, pins)
+		})
+
+		t.Run("should return error for invalid pins", func(t *testing.T) {
+			_, err := parsePins([]string{"vendor1/chart1"})
+			assert.ErrorContains(t, err, "is not in <vendor>/<chart>=<version> format")
+			_, err = parsePins([]string{"chart1=1.0.0"})
+			assert.ErrorContains(t, err, "is not in <vendor>/<chart> format")
+			_, err = parsePins([]string{"vendor1/chart1=latest"})
+			assert.ErrorContains(t, err, "invalid version in pin")
+		})
+	})
+
+	t.Run("getAutoInstallVersions", func(t *testing.T) {
+		t.Run("should return versions referenced by auto-install annotations", func(t *testing.T) {
+			indexYaml := &repo.IndexFile{
+				Entries: map[string]repo.ChartVersions{
+					"app": {
+						{Metadata: &chart.Metadata{Name: "app", Version: "2.0.0", Annotations: map[string]string{annotationAutoInstall: "app-crds=match"}}},
+						{Metadata: &chart.Metadata{Name: "app", Version: "1.0.0", Annotations: map[string]string{annotationAutoInstall: "app-crds=0.9.0"}}},
+					},
+					"other": {
+						{Metadata: &chart.Metadata{Name: "other", Version: "1.0.0"}},
+					},
+				},
+			}
+			assert.Equal(t, map[string]map[string]bool{
+				"app-crds": {"2.0.0": true, "0.9.0": true},
+			}, getAutoInstallVersions(indexYaml))
+		})
+	})
 }
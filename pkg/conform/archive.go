@@ -0,0 +1,144 @@
+package conform
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+
+	"sigs.k8s.io/yaml"
+)
+
+// archiveModTime is the modification time of every file in archives
+// written by SaveChart.
+var archiveModTime = time.Unix(0, 0).UTC()
+
+// archiveEntry is a single file in a chart archive.
+type archiveEntry struct {
+	Name string
+	Data []byte
+}
+
+// SaveChart is like helm's chartutil.Save, but it produces the same bytes
+// every time it is called on the same chart. Archive entries are sorted
+// by name, and have a fixed modification time, mode and owner. This keeps
+// the digest of a chart from changing when the chart is written again
+// without any changes to its contents. It returns the path to the
+// written archive.
+func SaveChart(helmChart *chart.Chart, outDir string) (tgzPath string, err error) {
+	if err := helmChart.Validate(); err != nil {
+		return "", fmt.Errorf("chart validation: %w", err)
+	}
+
+	entries, err := getArchiveEntries(helmChart, "")
+	if err != nil {
+		return "", err
+	}
+	slices.SortFunc(entries, func(a, b archiveEntry) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	buffer := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(buffer)
+	gzipWriter.Header.Comment = "Helm"
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, entry := range entries {
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     entry.Name,
+			Mode:     0o644,
+			Size:     int64(len(entry.Data)),
+			ModTime:  archiveModTime,
+			Format:   tar.FormatPAX,
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return "", fmt.Errorf("failed to write header for %s: %w", entry.Name, err)
+		}
+		if _, err := tarWriter.Write(entry.Data); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", entry.Name, err)
+		}
+	}
+	if err := errors.Join(tarWriter.Close(), gzipWriter.Close()); err != nil {
+		return "", fmt.Errorf("failed to finish archive: %w", err)
+	}
+
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", outDir, err)
+	}
+	tgzPath = path.Join(outDir, fmt.Sprintf("%s-%s.tgz", helmChart.Name(), helmChart.Metadata.Version))
+	if err := os.WriteFile(tgzPath, buffer.Bytes(), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", tgzPath, err)
+	}
+
+	return tgzPath, nil
+}
+
+// getArchiveEntries returns the files that make up helmChart and its
+// subcharts, with names relative to the root of the archive. It writes
+// the same files as chartutil.Save.
+func getArchiveEntries(helmChart *chart.Chart, prefix string) ([]archiveEntry, error) {
+	if name := helmChart.Name(); name == "" || path.Base(name) != name {
+		return nil, fmt.Errorf("invalid chart name %q", name)
+	}
+	base := path.Join(prefix, helmChart.Name())
+	entries := make([]archiveEntry, 0, len(helmChart.Templates)+len(helmChart.Files)+3)
+
+	// The dependencies of v1 charts are stored in requirements.yaml,
+	// which is one of helmChart.Files, so they must not be in Chart.yaml.
+	metadata := *helmChart.Metadata
+	if metadata.APIVersion == chart.APIVersionV1 {
+		metadata.Dependencies = nil
+	}
+	chartYaml, err := yaml.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Chart.yaml: %w", err)
+	}
+	entries = append(entries, archiveEntry{Name: path.Join(base, chartutil.ChartfileName), Data: chartYaml})
+
+	if metadata.APIVersion == chart.APIVersionV2 && helmChart.Lock != nil {
+		chartLock, err := yaml.Marshal(helmChart.Lock)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal Chart.lock: %w", err)
+		}
+		entries = append(entries, archiveEntry{Name: path.Join(base, "Chart.lock"), Data: chartLock})
+	}
+
+	for _, file := range helmChart.Raw {
+		if file.Name == chartutil.ValuesfileName {
+			entries = append(entries, archiveEntry{Name: path.Join(base, chartutil.ValuesfileName), Data: file.Data})
+		}
+	}
+
+	if helmChart.Schema != nil {
+		if !json.Valid(helmChart.Schema) {
+			return nil, fmt.Errorf("invalid JSON in %s", chartutil.SchemafileName)
+		}
+		entries = append(entries, archiveEntry{Name: path.Join(base, chartutil.SchemafileName), Data: helmChart.Schema})
+	}
+
+	for _, files := range [][]*chart.File{helmChart.Templates, helmChart.Files} {
+		for _, file := range files {
+			entries = append(entries, archiveEntry{Name: path.Join(base, file.Name), Data: file.Data})
+		}
+	}
+
+	for _, dependency := range helmChart.Dependencies() {
+		dependencyEntries, err := getArchiveEntries(dependency, path.Join(base, chartutil.ChartsDir))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get archive entries for subchart %s: %w", dependency.Name(), err)
+		}
+		entries = append(entries, dependencyEntries...)
+	}
+
+	return entries, nil
+}
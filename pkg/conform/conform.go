@@ -3,6 +3,9 @@ package conform
 import (
 	"fmt"
 	"math"
+	"reflect"
+	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/Masterminds/semver/v3"
@@ -16,7 +19,91 @@ var (
 	MaxPatchNum        = PatchNumMultiplier - 1
 )
 
-func OverlayChartMetadata(helmChart *chart.Chart, overlay chart.Metadata) {
+// lowerBoundRegexp matches a lower bound in a semver constraint. The
+// last group captures anything that directly follows the version, such
+// as a prerelease, build metadata or wildcard.
+var lowerBoundRegexp = regexp.MustCompile(`(>=|=>|~>|~|\^)(\s*)(v?\d+(?:\.\d+){0,2})([^\s,|]*)`)
+
+// NormalizeKubeVersion appends a "-0" prerelease suffix to each lower bound
+// (>=, ~ or ^) in the kubeVersion constraint that does not already have
+// a prerelease, so that ">=1.21" becomes ">=1.21-0". Without it, the
+// constraint does not match Kubernetes versions such as "v1.21.3-gke.100"
+// that many providers use, and Rancher does not show the chart. Lower
+// bounds that use wildcards are left unchanged.
+func NormalizeKubeVersion(kubeVersion string) string {
+	return lowerBoundRegexp.ReplaceAllStringFunc(kubeVersion, func(lowerBound string) string {
+		parts := lowerBoundRegexp.FindStringSubmatch(lowerBound)
+		operator, space, version, suffix := parts[1], parts[2], parts[3], parts[4]
+		switch {
+		case suffix == "":
+			return operator + space + version + "-0"
+		case strings.HasPrefix(suffix, "+"):
+			return operator + space + version + "-0" + suffix
+		default:
+			return lowerBound
+		}
+	})
+}
+
+// ListMergeStrategy determines how a list field of a chart metadata
+// overlay is combined with the same field of the chart's metadata.
+type ListMergeStrategy string
+
+const (
+	// ListMergeAppend appends the overlay's entries to the chart's entries.
+	ListMergeAppend ListMergeStrategy = "append"
+	// ListMergeAppendUnique appends the overlay's entries that the chart
+	// does not already have.
+	ListMergeAppendUnique ListMergeStrategy = "append-unique"
+	// ListMergeReplace replaces the chart's entries with the overlay's.
+	ListMergeReplace ListMergeStrategy = "replace"
+)
+
+// ListFields are the names of the list fields of chart metadata whose
+// ListMergeStrategy may be set.
+var ListFields = []string{"dependencies", "keywords", "maintainers", "sources"}
+
+// UnsettableFields are the names of the scalar fields of chart metadata
+// that may be unset.
+var UnsettableFields = []string{"appVersion", "condition", "deprecated", "description", "home", "icon", "kubeVersion", "tags"}
+
+// OverlayOptions controls how OverlayChartMetadata applies an overlay.
+type OverlayOptions struct {
+	// Merge maps names of fields in ListFields to the strategy used to
+	// merge them. Fields that are not present are appended.
+	Merge map[string]ListMergeStrategy
+	// Unset contains names of fields in UnsettableFields that are
+	// cleared in the chart's metadata.
+	Unset []string
+}
+
+// Validate checks that options only refer to known fields and strategies,
+// and that it does not unset any field that overlay sets.
+func (options OverlayOptions) Validate(overlay chart.Metadata) error {
+	for field, strategy := range options.Merge {
+		if !slices.Contains(ListFields, field) {
+			return fmt.Errorf("cannot set merge strategy of %q; must be one of %s", field, strings.Join(ListFields, ", "))
+		}
+		switch strategy {
+		case ListMergeAppend, ListMergeAppendUnique, ListMergeReplace:
+		default:
+			return fmt.Errorf("invalid merge strategy %q for %q; must be one of %s, %s, %s", strategy, field, ListMergeAppend, ListMergeAppendUnique, ListMergeReplace)
+		}
+	}
+	for _, field := range options.Unset {
+		if !slices.Contains(UnsettableFields, field) {
+			return fmt.Errorf("cannot unset %q; must be one of %s", field, strings.Join(UnsettableFields, ", "))
+		}
+		overlayCopy := overlay
+		unsetField(&overlayCopy, field)
+		if !reflect.DeepEqual(overlayCopy, overlay) {
+			return fmt.Errorf("%q is both set and unset", field)
+		}
+	}
+	return nil
+}
+
+func OverlayChartMetadata(helmChart *chart.Chart, overlay chart.Metadata, options OverlayOptions) {
 	if overlay.Name != "" {
 		helmChart.Metadata.Name = overlay.Name
 	}
@@ -24,7 +111,9 @@ func OverlayChartMetadata(helmChart *chart.Chart, overlay chart.Metadata) {
 		helmChart.Metadata.Home = overlay.Home
 	}
 	if overlay.Sources != nil {
-		helmChart.Metadata.Sources = append(helmChart.Metadata.Sources, overlay.Sources...)
+		helmChart.Metadata.Sources = mergeList(helmChart.Metadata.Sources, overlay.Sources, options.Merge["sources"], func(a, b string) bool {
+			return a == b
+		})
 	}
 	if overlay.Version != "" {
 		helmChart.Metadata.Version = overlay.Version
@@ -33,10 +122,14 @@ func OverlayChartMetadata(helmChart *chart.Chart, overlay chart.Metadata) {
 		helmChart.Metadata.Description = overlay.Description
 	}
 	if overlay.Keywords != nil {
-		helmChart.Metadata.Keywords = append(helmChart.Metadata.Keywords, overlay.Keywords...)
+		helmChart.Metadata.Keywords = mergeList(helmChart.Metadata.Keywords, overlay.Keywords, options.Merge["keywords"], func(a, b string) bool {
+			return a == b
+		})
 	}
 	if overlay.Maintainers != nil {
-		helmChart.Metadata.Maintainers = append(helmChart.Metadata.Maintainers, overlay.Maintainers...)
+		helmChart.Metadata.Maintainers = mergeList(helmChart.Metadata.Maintainers, overlay.Maintainers, options.Merge["maintainers"], func(a, b *chart.Maintainer) bool {
+			return a.Name == b.Name && a.Email == b.Email && a.URL == b.URL
+		})
 	}
 	if overlay.Icon != "" {
 		helmChart.Metadata.Icon = overlay.Icon
@@ -65,11 +158,59 @@ func OverlayChartMetadata(helmChart *chart.Chart, overlay chart.Metadata) {
 		helmChart.Metadata.KubeVersion = overlay.KubeVersion
 	}
 	if overlay.Dependencies != nil {
-		helmChart.Metadata.Dependencies = append(helmChart.Metadata.Dependencies, overlay.Dependencies...)
+		helmChart.Metadata.Dependencies = mergeList(helmChart.Metadata.Dependencies, overlay.Dependencies, options.Merge["dependencies"], func(a, b *chart.Dependency) bool {
+			return a.Name == b.Name && a.Alias == b.Alias
+		})
 	}
 	if overlay.Type != "" {
 		helmChart.Metadata.Type = overlay.Type
 	}
+	for _, field := range options.Unset {
+		unsetField(helmChart.Metadata, field)
+	}
+}
+
+// mergeList merges overlay into current according to strategy. An empty
+// strategy is treated as ListMergeAppend. equal reports whether two
+// entries are the same for the purposes of ListMergeAppendUnique.
+func mergeList[T any](current, overlay []T, strategy ListMergeStrategy, equal func(a, b T) bool) []T {
+	switch strategy {
+	case ListMergeReplace:
+		return append([]T{}, overlay...)
+	case ListMergeAppendUnique:
+		merged := append([]T{}, current...)
+		for _, overlayEntry := range overlay {
+			if !slices.ContainsFunc(merged, func(entry T) bool { return equal(entry, overlayEntry) }) {
+				merged = append(merged, overlayEntry)
+			}
+		}
+		return merged
+	default:
+		return append(current, overlay...)
+	}
+}
+
+// unsetField sets the field of metadata with the name field to its zero
+// value. field must be one of UnsettableFields.
+func unsetField(metadata *chart.Metadata, field string) {
+	switch field {
+	case "appVersion":
+		metadata.AppVersion = ""
+	case "condition":
+		metadata.Condition = ""
+	case "deprecated":
+		metadata.Deprecated = false
+	case "description":
+		metadata.Description = ""
+	case "home":
+		metadata.Home = ""
+	case "icon":
+		metadata.Icon = ""
+	case "kubeVersion":
+		metadata.KubeVersion = ""
+	case "tags":
+		metadata.Tags = ""
+	}
 }
 
 func AnnotateChart(helmChart *chart.Chart, annotation, value string, override bool) bool {
@@ -1,11 +1,20 @@
 package conform
 
 import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -114,6 +123,31 @@ func TestMain(t *testing.T) {
 		})
 	})
 
+	t.Run("NormalizeKubeVersion", func(t *testing.T) {
+		testCases := []struct {
+			KubeVersion string
+			Expected    string
+		}{
+			{KubeVersion: "", Expected: ""},
+			{KubeVersion: ">=1.21", Expected: ">=1.21-0"},
+			{KubeVersion: ">= 1.21.0", Expected: ">= 1.21.0-0"},
+			{KubeVersion: ">=1.21-0", Expected: ">=1.21-0"},
+			{KubeVersion: ">=1.21.0-rc.1", Expected: ">=1.21.0-rc.1"},
+			{KubeVersion: ">=v1.21+k3s1", Expected: ">=v1.21-0+k3s1"},
+			{KubeVersion: ">=1.21 <1.30", Expected: ">=1.21-0 <1.30"},
+			{KubeVersion: ">=1.21, <1.30", Expected: ">=1.21-0, <1.30"},
+			{KubeVersion: "~1.21 || ^1.25", Expected: "~1.21-0 || ^1.25-0"},
+			{KubeVersion: ">=1.21.x", Expected: ">=1.21.x"},
+			{KubeVersion: "<1.30", Expected: "<1.30"},
+			{KubeVersion: ">1.21", Expected: ">1.21"},
+		}
+		for _, testCase := range testCases {
+			t.Run(fmt.Sprintf("should normalize %q to %q", testCase.KubeVersion, testCase.Expected), func(t *testing.T) {
+				assert.Equal(t, testCase.Expected, NormalizeKubeVersion(testCase.KubeVersion))
+			})
+		}
+	})
+
 	t.Run("OverlayChartMetadata", func(t *testing.T) {
 		stringFieldNames := []string{"Name", "Home", "Version", "Description", "Icon", "APIVersion", "Condition", "Tags", "AppVersion", "KubeVersion", "Type"}
 		for _, stringFieldName := range stringFieldNames {
@@ -126,7 +160,7 @@ func TestMain(t *testing.T) {
 				}
 				overlay := chart.Metadata{}
 				reflect.ValueOf(&overlay).Elem().FieldByName(stringFieldName).SetString(expectedNewValue)
-				OverlayChartMetadata(helmChart, overlay)
+				OverlayChartMetadata(helmChart, overlay, OverlayOptions{})
 				actualNewValue := reflect.ValueOf(helmChart.Metadata).Elem().FieldByName(stringFieldName).String()
 				assert.Equal(t, expectedNewValue, actualNewValue)
 			})
@@ -141,7 +175,7 @@ func TestMain(t *testing.T) {
 			overlay := chart.Metadata{
 				Deprecated: true,
 			}
-			OverlayChartMetadata(helmChart, overlay)
+			OverlayChartMetadata(helmChart, overlay, OverlayOptions{})
 			assert.True(t, helmChart.Metadata.Deprecated)
 		})
 
@@ -155,7 +189,7 @@ func TestMain(t *testing.T) {
 			overlay := chart.Metadata{
 				Sources: newSources,
 			}
-			OverlayChartMetadata(helmChart, overlay)
+			OverlayChartMetadata(helmChart, overlay, OverlayOptions{})
 			expectedSources := []string{"value1", "value2", "value3"}
 			assert.Equal(t, expectedSources, helmChart.Metadata.Sources)
 		})
@@ -170,7 +204,7 @@ func TestMain(t *testing.T) {
 			overlay := chart.Metadata{
 				Keywords: newKeywords,
 			}
-			OverlayChartMetadata(helmChart, overlay)
+			OverlayChartMetadata(helmChart, overlay, OverlayOptions{})
 			expectedKeywords := []string{"value1", "value2", "value3"}
 			assert.Equal(t, expectedKeywords, helmChart.Metadata.Keywords)
 		})
@@ -198,7 +232,7 @@ func TestMain(t *testing.T) {
 			expectedMaintainers := make([]*chart.Maintainer, 0, len(helmChart.Metadata.Maintainers)+len(overlay.Maintainers))
 			expectedMaintainers = append(expectedMaintainers, helmChart.Metadata.Maintainers...)
 			expectedMaintainers = append(expectedMaintainers, overlay.Maintainers...)
-			OverlayChartMetadata(helmChart, overlay)
+			OverlayChartMetadata(helmChart, overlay, OverlayOptions{})
 			assert.Equal(t, expectedMaintainers, helmChart.Metadata.Maintainers)
 		})
 
@@ -231,7 +265,7 @@ func TestMain(t *testing.T) {
 			expectedDependencies := make([]*chart.Dependency, 0, len(helmChart.Metadata.Dependencies)+len(overlay.Dependencies))
 			expectedDependencies = append(expectedDependencies, helmChart.Metadata.Dependencies...)
 			expectedDependencies = append(expectedDependencies, overlay.Dependencies...)
-			OverlayChartMetadata(helmChart, overlay)
+			OverlayChartMetadata(helmChart, overlay, OverlayOptions{})
 			assert.Equal(t, expectedDependencies, helmChart.Metadata.Dependencies)
 		})
 
@@ -249,9 +283,476 @@ func TestMain(t *testing.T) {
 					"annotation1": "newValue1",
 				},
 			}
-			OverlayChartMetadata(helmChart, overlay)
+			OverlayChartMetadata(helmChart, overlay, OverlayOptions{})
 			assert.Equal(t, "newValue1", helmChart.Metadata.Annotations["annotation1"])
 			assert.Equal(t, "oldValue2", helmChart.Metadata.Annotations["annotation2"])
 		})
+
+		t.Run("should merge list fields according to merge strategy", func(t *testing.T) {
+			testCases := []struct {
+				Strategy ListMergeStrategy
+				Expected []string
+			}{
+				{Strategy: "", Expected: []string{"value1", "value2", "value2", "value3"}},
+				{Strategy: ListMergeAppend, Expected: []string{"value1", "value2", "value2", "value3"}},
+				{Strategy: ListMergeAppendUnique, Expected: []string{"value1", "value2", "value3"}},
+				{Strategy: ListMergeReplace, Expected: []string{"value2", "value3"}},
+			}
+			for _, testCase := range testCases {
+				helmChart := &chart.Chart{
+					Metadata: &chart.Metadata{
+						Keywords: []string{"value1", "value2"},
+					},
+				}
+				overlay := chart.Metadata{
+					Keywords: []string{"value2", "value3"},
+				}
+				options := OverlayOptions{
+					Merge: map[string]ListMergeStrategy{
+						"keywords": testCase.Strategy,
+					},
+				}
+				OverlayChartMetadata(helmChart, overlay, options)
+				assert.Equal(t, testCase.Expected, helmChart.Metadata.Keywords, "strategy %q", testCase.Strategy)
+			}
+		})
+
+		t.Run("should not duplicate maintainers when merge strategy is append-unique", func(t *testing.T) {
+			helmChart := &chart.Chart{
+				Metadata: &chart.Metadata{
+					Maintainers: []*chart.Maintainer{
+						{
+							Name:  "maintainer1",
+							Email: "maintainer1@example.com",
+						},
+					},
+				},
+			}
+			overlay := chart.Metadata{
+				Maintainers: []*chart.Maintainer{
+					{
+						Name:  "maintainer1",
+						Email: "maintainer1@example.com",
+					},
+					{
+						Name: "maintainer2",
+					},
+				},
+			}
+			options := OverlayOptions{
+				Merge: map[string]ListMergeStrategy{
+					"maintainers": ListMergeAppendUnique,
+				},
+			}
+			OverlayChartMetadata(helmChart, overlay, options)
+			assert.Len(t, helmChart.Metadata.Maintainers, 2)
+			assert.Equal(t, "maintainer2", helmChart.Metadata.Maintainers[1].Name)
+		})
+
+		t.Run("should unset fields", func(t *testing.T) {
+			helmChart := &chart.Chart{
+				Metadata: &chart.Metadata{
+					Home:       "https://example.com",
+					Deprecated: true,
+					Icon:       "https://example.com/icon.png",
+				},
+			}
+			options := OverlayOptions{
+				Unset: []string{"home", "deprecated"},
+			}
+			OverlayChartMetadata(helmChart, chart.Metadata{}, options)
+			assert.Equal(t, "", helmChart.Metadata.Home)
+			assert.False(t, helmChart.Metadata.Deprecated)
+			assert.Equal(t, "https://example.com/icon.png", helmChart.Metadata.Icon)
+		})
+	})
+
+	t.Run("OverlayOptions", func(t *testing.T) {
+		t.Run("Validate", func(t *testing.T) {
+			t.Run("should accept valid options", func(t *testing.T) {
+				options := OverlayOptions{
+					Merge: map[string]ListMergeStrategy{
+						"sources":     ListMergeReplace,
+						"maintainers": ListMergeAppendUnique,
+					},
+					Unset: []string{"home"},
+				}
+				assert.Nil(t, options.Validate(chart.Metadata{}))
+			})
+
+			t.Run("should reject unknown list field", func(t *testing.T) {
+				options := OverlayOptions{
+					Merge: map[string]ListMergeStrategy{
+						"home": ListMergeReplace,
+					},
+				}
+				assert.ErrorContains(t, options.Validate(chart.Metadata{}), `cannot set merge strategy of "home"`)
+			})
+
+			t.Run("should reject unknown merge strategy", func(t *testing.T) {
+				options := OverlayOptions{
+					Merge: map[string]ListMergeStrategy{
+						"sources": "prepend",
+					},
+				}
+				assert.ErrorContains(t, options.Validate(chart.Metadata{}), `invalid merge strategy "prepend"`)
+			})
+
+			t.Run("should reject unknown unset field", func(t *testing.T) {
+				options := OverlayOptions{
+					Unset: []string{"version"},
+				}
+				assert.ErrorContains(t, options.Validate(chart.Metadata{}), `cannot unset "version"`)
+			})
+
+			t.Run("should reject field that is both set and unset", func(t *testing.T) {
+				options := OverlayOptions{
+					Unset: []string{"home"},
+				}
+				overlay := chart.Metadata{
+					Home: "https://example.com",
+				}
+				assert.ErrorContains(t, options.Validate(overlay), `"home" is both set and unset`)
+			})
+		})
+	})
+
+	t.Run("SaveChart", func(t *testing.T) {
+		newChart := func() *chart.Chart {
+			helmChart := &chart.Chart{
+				Metadata: &chart.Metadata{
+					APIVersion: "v2",
+					Name:       "testchart",
+					Version:    "1.2.3",
+					Annotations: map[string]string{
+						"catalog.cattle.io/hidden":       "true",
+						"catalog.cattle.io/display-name": "Test Chart",
+					},
+				},
+				Raw: []*chart.File{
+					{Name: "values.yaml", Data: []byte("replicas: 1\n")},
+				},
+				Values: map[string]any{"replicas": 1},
+				Templates: []*chart.File{
+					{Name: "templates/service.yaml", Data: []byte("kind: Service\n")},
+					{Name: "templates/deployment.yaml", Data: []byte("kind: Deployment\n")},
+				},
+				Files: []*chart.File{
+					{Name: "README.md", Data: []byte("# Test Chart\n")},
+				},
+			}
+			helmChart.AddDependency(&chart.Chart{
+				Metadata: &chart.Metadata{
+					APIVersion: "v2",
+					Name:       "subchart",
+					Version:    "0.1.0",
+				},
+			})
+			return helmChart
+		}
+
+		t.Run("should produce identical bytes when saving the same chart twice", func(t *testing.T) {
+			firstPath, err := SaveChart(newChart(), t.TempDir())
+			if !assert.Nil(t, err) {
+				return
+			}
+			secondPath, err := SaveChart(newChart(), t.TempDir())
+			if !assert.Nil(t, err) {
+				return
+			}
+			firstContents, err := os.ReadFile(firstPath)
+			assert.Nil(t, err)
+			secondContents, err := os.ReadFile(secondPath)
+			assert.Nil(t, err)
+			assert.Equal(t, sha256.Sum256(firstContents), sha256.Sum256(secondContents))
+		})
+
+		t.Run("should write sorted entries with normalized headers", func(t *testing.T) {
+			tgzPath, err := SaveChart(newChart(), t.TempDir())
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, "testchart-1.2.3.tgz", filepath.Base(tgzPath))
+
+			tgzFile, err := os.Open(tgzPath)
+			if !assert.Nil(t, err) {
+				return
+			}
+			defer tgzFile.Close()
+			gzipReader, err := gzip.NewReader(tgzFile)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.True(t, gzipReader.Header.ModTime.IsZero())
+			tarReader := tar.NewReader(gzipReader)
+			names := []string{}
+			for {
+				header, err := tarReader.Next()
+				if err == io.EOF {
+					break
+				}
+				if !assert.Nil(t, err) {
+					return
+				}
+				names = append(names, header.Name)
+				assert.Equal(t, archiveModTime, header.ModTime.UTC())
+				assert.Equal(t, int64(0o644), header.Mode)
+				assert.Equal(t, 0, header.Uid)
+				assert.Equal(t, 0, header.Gid)
+				assert.Empty(t, header.Uname)
+				assert.Empty(t, header.Gname)
+			}
+			assert.Equal(t, []string{
+				"testchart/Chart.yaml",
+				"testchart/README.md",
+				"testchart/charts/subchart/Chart.yaml",
+				"testchart/templates/deployment.yaml",
+				"testchart/templates/service.yaml",
+				"testchart/values.yaml",
+			}, names)
+		})
+
+		t.Run("should write a chart that helm can load", func(t *testing.T) {
+			tgzPath, err := SaveChart(newChart(), t.TempDir())
+			if !assert.Nil(t, err) {
+				return
+			}
+			helmChart, err := loader.LoadFile(tgzPath)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, newChart().Metadata, helmChart.Metadata)
+			assert.Len(t, helmChart.Templates, 2)
+			assert.Len(t, helmChart.Dependencies(), 1)
+			assert.Equal(t, map[string]any{"replicas": float64(1)}, helmChart.Values)
+		})
+
+		t.Run("should return error for invalid chart", func(t *testing.T) {
+			helmChart := newChart()
+			helmChart.Metadata.Version = ""
+			_, err := SaveChart(helmChart, t.TempDir())
+			assert.ErrorContains(t, err, "chart validation")
+		})
+	})
+
+	t.Run("Gunzip", func(t *testing.T) {
+		type testEntry struct {
+			Header *tar.Header
+			Data   string
+		}
+		writeArchive := func(t *testing.T, entries []testEntry) string {
+			t.Helper()
+			tgzPath := filepath.Join(t.TempDir(), "testchart-1.2.3.tgz")
+			tgzFile, err := os.Create(tgzPath)
+			if err != nil {
+				t.Fatalf("failed to create %s: %s", tgzPath, err)
+			}
+			defer tgzFile.Close()
+			gzipWriter := gzip.NewWriter(tgzFile)
+			tarWriter := tar.NewWriter(gzipWriter)
+			for _, entry := range entries {
+				if entry.Header.Typeflag == tar.TypeReg {
+					entry.Header.Size = int64(len(entry.Data))
+				}
+				if entry.Header.Mode == 0 {
+					entry.Header.Mode = 0o644
+				}
+				if err := tarWriter.WriteHeader(entry.Header); err != nil {
+					t.Fatalf("failed to write header: %s", err)
+				}
+				if _, err := tarWriter.Write([]byte(entry.Data)); err != nil {
+					t.Fatalf("failed to write data: %s", err)
+				}
+			}
+			if err := errors.Join(tarWriter.Close(), gzipWriter.Close()); err != nil {
+				t.Fatalf("failed to close archive: %s", err)
+			}
+			return tgzPath
+		}
+		regularFile := func(name, data string) testEntry {
+			return testEntry{Header: &tar.Header{Typeflag: tar.TypeReg, Name: name}, Data: data}
+		}
+
+		t.Run("should extract files without the top-level directory", func(t *testing.T) {
+			tgzPath := writeArchive(t, []testEntry{
+				{Header: &tar.Header{Typeflag: tar.TypeDir, Name: "testchart/", Mode: 0o755}},
+				regularFile("testchart/Chart.yaml", "name: testchart\n"),
+				regularFile("testchart/templates/service.yaml", "kind: Service\n"),
+			})
+			outPath := filepath.Join(t.TempDir(), "out")
+			if !assert.Nil(t, Gunzip(tgzPath, outPath)) {
+				return
+			}
+			contents, err := os.ReadFile(filepath.Join(outPath, "templates", "service.yaml"))
+			assert.Nil(t, err)
+			assert.Equal(t, "kind: Service\n", string(contents))
+		})
+
+		t.Run("should strip setuid and other special mode bits", func(t *testing.T) {
+			tgzPath := writeArchive(t, []testEntry{
+				{Header: &tar.Header{Typeflag: tar.TypeReg, Name: "testchart/run.sh", Mode: 0o4755}, Data: "#!/bin/sh\n"},
+			})
+			outPath := filepath.Join(t.TempDir(), "out")
+			if !assert.Nil(t, Gunzip(tgzPath, outPath)) {
+				return
+			}
+			info, err := os.Stat(filepath.Join(outPath, "run.sh"))
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, os.FileMode(0o755), info.Mode())
+		})
+
+		t.Run("should return error for path traversal", func(t *testing.T) {
+			for _, name := range []string{
+				"testchart/../../evil.txt",
+				"testchart/templates/../../../evil.txt",
+				"../evil.txt",
+			} {
+				parentDir := t.TempDir()
+				outPath := filepath.Join(parentDir, "out")
+				tgzPath := writeArchive(t, []testEntry{regularFile(name, "evil")})
+				assert.ErrorContains(t, Gunzip(tgzPath, outPath), "outside of the chart directory", name)
+				_, err := os.Stat(filepath.Join(parentDir, "evil.txt"))
+				assert.True(t, os.IsNotExist(err), name)
+			}
+		})
+
+		t.Run("should return error for absolute paths", func(t *testing.T) {
+			tgzPath := writeArchive(t, []testEntry{regularFile("/testchart/../../tmp/evil.txt", "evil")})
+			assert.ErrorContains(t, Gunzip(tgzPath, t.TempDir()), "outside of the chart directory")
+		})
+
+		t.Run("should return error for files outside of the top-level directory", func(t *testing.T) {
+			tgzPath := writeArchive(t, []testEntry{regularFile("Chart.yaml", "name: testchart\n")})
+			assert.ErrorContains(t, Gunzip(tgzPath, t.TempDir()), "outside of the chart directory")
+		})
+
+		t.Run("should return error for symlinks", func(t *testing.T) {
+			outPath := filepath.Join(t.TempDir(), "out")
+			tgzPath := writeArchive(t, []testEntry{
+				{Header: &tar.Header{Typeflag: tar.TypeSymlink, Name: "testchart/templates", Linkname: "/etc"}},
+				regularFile("testchart/templates/passwd", "evil"),
+			})
+			assert.ErrorContains(t, Gunzip(tgzPath, outPath), "links are not allowed")
+			_, err := os.Lstat(filepath.Join(outPath, "templates"))
+			assert.True(t, os.IsNotExist(err))
+		})
+
+		t.Run("should return error for hard links", func(t *testing.T) {
+			tgzPath := writeArchive(t, []testEntry{
+				{Header: &tar.Header{Typeflag: tar.TypeLink, Name: "testchart/passwd", Linkname: "/etc/passwd"}},
+			})
+			assert.ErrorContains(t, Gunzip(tgzPath, t.TempDir()), "links are not allowed")
+		})
+
+		t.Run("should return error for other special files", func(t *testing.T) {
+			tgzPath := writeArchive(t, []testEntry{
+				{Header: &tar.Header{Typeflag: tar.TypeFifo, Name: "testchart/fifo"}},
+			})
+			assert.ErrorContains(t, Gunzip(tgzPath, t.TempDir()), "unknown file type")
+		})
+
+		t.Run("should return error for files larger than the maximum file size", func(t *testing.T) {
+			defer func(original int64) { maxGunzipFileSize = original }(maxGunzipFileSize)
+			maxGunzipFileSize = 10
+			tgzPath := writeArchive(t, []testEntry{
+				regularFile("testchart/values.yaml", strings.Repeat("a", 11)),
+			})
+			assert.ErrorContains(t, Gunzip(tgzPath, t.TempDir()), "exceeds the maximum file size of 10")
+		})
+
+		t.Run("should return error for archives larger than the maximum archive size", func(t *testing.T) {
+			defer func(original int64) { maxGunzipArchiveSize = original }(maxGunzipArchiveSize)
+			maxGunzipArchiveSize = 25
+			tgzPath := writeArchive(t, []testEntry{
+				regularFile("testchart/a.yaml", strings.Repeat("a", 10)),
+				regularFile("testchart/b.yaml", strings.Repeat("b", 10)),
+				regularFile("testchart/c.yaml", strings.Repeat("c", 10)),
+			})
+			assert.ErrorContains(t, Gunzip(tgzPath, t.TempDir()), "would exceed the maximum archive size of 25")
+		})
+
+		t.Run("should extract an archive written by SaveChart", func(t *testing.T) {
+			helmChart := &chart.Chart{
+				Metadata: &chart.Metadata{APIVersion: "v2", Name: "testchart", Version: "1.2.3"},
+				Templates: []*chart.File{
+					{Name: "templates/service.yaml", Data: []byte("kind: Service\n")},
+				},
+			}
+			tgzPath, err := SaveChart(helmChart, t.TempDir())
+			if !assert.Nil(t, err) {
+				return
+			}
+			outPath := filepath.Join(t.TempDir(), "out")
+			if !assert.Nil(t, Gunzip(tgzPath, outPath)) {
+				return
+			}
+			extracted, err := loader.LoadDir(outPath)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, helmChart.Metadata, extracted.Metadata)
+		})
+	})
+
+	t.Run("ChartDirectoryMatchesArchive", func(t *testing.T) {
+		saveAndExtract := func(t *testing.T) (string, string) {
+			t.Helper()
+			helmChart := &chart.Chart{
+				Metadata: &chart.Metadata{APIVersion: "v2", Name: "testchart", Version: "1.2.3"},
+				Templates: []*chart.File{
+					{Name: "templates/service.yaml", Data: []byte("kind: Service\n")},
+				},
+			}
+			tgzPath, err := SaveChart(helmChart, t.TempDir())
+			if err != nil {
+				t.Fatalf("failed to save chart: %s", err)
+			}
+			chartDir := filepath.Join(t.TempDir(), "testchart")
+			if err := Gunzip(tgzPath, chartDir); err != nil {
+				t.Fatalf("failed to extract chart: %s", err)
+			}
+			return tgzPath, chartDir
+		}
+
+		t.Run("should return true for a directory extracted from the archive", func(t *testing.T) {
+			tgzPath, chartDir := saveAndExtract(t)
+			match, err := ChartDirectoryMatchesArchive(tgzPath, chartDir)
+			assert.Nil(t, err)
+			assert.True(t, match)
+		})
+
+		t.Run("should return false when a file has been modified", func(t *testing.T) {
+			tgzPath, chartDir := saveAndExtract(t)
+			filePath := filepath.Join(chartDir, "templates", "service.yaml")
+			if err := os.WriteFile(filePath, []byte("kind: Deployment\n"), 0o644); err != nil {
+				t.Fatalf("failed to write %s: %s", filePath, err)
+			}
+			match, err := ChartDirectoryMatchesArchive(tgzPath, chartDir)
+			assert.Nil(t, err)
+			assert.False(t, match)
+		})
+
+		t.Run("should return false when there is an extra file", func(t *testing.T) {
+			tgzPath, chartDir := saveAndExtract(t)
+			filePath := filepath.Join(chartDir, "extra.yaml")
+			if err := os.WriteFile(filePath, []byte{}, 0o644); err != nil {
+				t.Fatalf("failed to write %s: %s", filePath, err)
+			}
+			match, err := ChartDirectoryMatchesArchive(tgzPath, chartDir)
+			assert.Nil(t, err)
+			assert.False(t, match)
+		})
+
+		t.Run("should return false when a file is missing", func(t *testing.T) {
+			tgzPath, chartDir := saveAndExtract(t)
+			if err := os.Remove(filepath.Join(chartDir, "templates", "service.yaml")); err != nil {
+				t.Fatalf("failed to remove file: %s", err)
+			}
+			match, err := ChartDirectoryMatchesArchive(tgzPath, chartDir)
+			assert.Nil(t, err)
+			assert.False(t, match)
+		})
 	})
 }
@@ -2,16 +2,18 @@ package conform
 
 import (
 	"archive/tar"
+	"bytes"
 	"compress/gzip"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"helm.sh/helm/v3/pkg/chart"
-	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/chart/loader"
 )
 
 func ExportChartDirectory(chart *chart.Chart, targetPath string) (err error) {
@@ -30,7 +32,7 @@ func ExportChartDirectory(chart *chart.Chart, targetPath string) (err error) {
 		}
 	}()
 
-	tgzPath, err := chartutil.Save(chart, tempDir)
+	tgzPath, err := SaveChart(chart, tempDir)
 	if err != nil {
 		return fmt.Errorf("failed to save chart archive to %s", tempDir)
 	}
@@ -54,6 +56,74 @@ func ExportChartDirectory(chart *chart.Chart, targetPath string) (err error) {
 	return err
 }
 
+// ChartDirectoryMatchesArchive returns whether chartDir contains exactly
+// the files that Gunzip would extract from the chart archive at tgzPath.
+// File modes are not compared.
+func ChartDirectoryMatchesArchive(tgzPath, chartDir string) (match bool, err error) {
+	tempDir, err := os.MkdirTemp("", "partner-charts-ci-compare-")
+	if err != nil {
+		return false, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer func() {
+		if removeErr := os.RemoveAll(tempDir); removeErr != nil {
+			err = errors.Join(err, removeErr)
+		}
+	}()
+
+	extractedDir := filepath.Join(tempDir, "chart")
+	if err := Gunzip(tgzPath, extractedDir); err != nil {
+		return false, fmt.Errorf("failed to unpack %s: %w", tgzPath, err)
+	}
+
+	extractedFiles, err := readDirectoryFiles(extractedDir)
+	if err != nil {
+		return false, err
+	}
+	chartFiles, err := readDirectoryFiles(chartDir)
+	if err != nil {
+		return false, err
+	}
+	if len(extractedFiles) != len(chartFiles) {
+		return false, nil
+	}
+	for relativePath, contents := range extractedFiles {
+		chartContents, ok := chartFiles[relativePath]
+		if !ok || !bytes.Equal(contents, chartContents) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// readDirectoryFiles returns the contents of each regular file under
+// dirPath, keyed by path relative to dirPath.
+func readDirectoryFiles(dirPath string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	err := filepath.WalkDir(dirPath, func(filePath string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if dirEntry.IsDir() {
+			return nil
+		}
+		relativePath, err := filepath.Rel(dirPath, filePath)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path of %s: %w", filePath, err)
+		}
+		contents, err := os.ReadFile(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", filePath, err)
+		}
+		files[relativePath] = contents
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read files in %s: %w", dirPath, err)
+	}
+	return files, nil
+}
+
 func stripRootPath(path string) string {
 	newPath := filepath.ToSlash(path)
 	rootPath := strings.Split(newPath, "/")[0]
@@ -64,8 +134,21 @@ func stripRootPath(path string) string {
 	return filepath.FromSlash(newPath)
 }
 
+// maxGunzipFileSize and maxGunzipArchiveSize limit the number of bytes
+// Gunzip extracts from a single file and from a whole archive. They match
+// the limits helm uses when loading chart archives.
+var (
+	maxGunzipFileSize    = loader.MaxDecompressedFileSize
+	maxGunzipArchiveSize = loader.MaxDecompressedChartSize
+)
+
+// Gunzip extracts the chart archive at path to outPath, removing the
+// top-level directory from the paths of its entries. Since chart archives
+// come from untrusted sources, it refuses to write anything outside of
+// outPath, enforces maxGunzipFileSize and maxGunzipArchiveSize, and returns
+// an error for archives that contain links or other special files.
 func Gunzip(path string, outPath string) (err error) {
-	if !strings.HasSuffix(path, ".tgz") && !strings.HasPrefix(path, ".gz") {
+	if !strings.HasSuffix(path, ".tgz") && !strings.HasSuffix(path, ".gz") {
 		return fmt.Errorf("expecting file of type .gz or .tgz")
 	}
 
@@ -90,7 +173,7 @@ func Gunzip(path string, outPath string) (err error) {
 	}()
 
 	tarReader := tar.NewReader(gzipReader)
-
+	remainingSize := maxGunzipArchiveSize
 	for {
 		h, err := tarReader.Next()
 		if err == io.EOF {
@@ -100,39 +183,73 @@ func Gunzip(path string, outPath string) (err error) {
 			return err
 		}
 
-		filePath := filepath.Join(outPath, stripRootPath(h.Name))
-		parentPath := filepath.Dir(filePath)
-		if err := os.MkdirAll(parentPath, 0o755); err != nil {
-			return fmt.Errorf("failed to mkdir %q: %w", parentPath, err)
+		switch h.Typeflag {
+		case tar.TypeXGlobalHeader, tar.TypeXHeader:
+			continue
+		case tar.TypeSymlink, tar.TypeLink:
+			return fmt.Errorf("%s: links are not allowed in chart archives", h.Name)
+		case tar.TypeDir, tar.TypeReg:
+		default:
+			return fmt.Errorf("unknown file type for %s", h.Name)
+		}
+
+		if !filepath.IsLocal(h.Name) {
+			return fmt.Errorf("%s: path is outside of the chart directory", h.Name)
+		}
+		relativePath := stripRootPath(h.Name)
+		if relativePath == "" && h.Typeflag == tar.TypeDir {
+			continue
+		}
+		if !filepath.IsLocal(relativePath) {
+			return fmt.Errorf("%s: path is outside of the chart directory", h.Name)
 		}
+		filePath := filepath.Join(outPath, relativePath)
 
 		if h.Typeflag == tar.TypeDir {
-			if err = os.MkdirAll(filePath, os.FileMode(h.Mode)); err != nil {
-				return err
-			}
-		} else if h.Typeflag == tar.TypeReg {
-			f, err := os.Create(filePath)
-			if err != nil {
-				return err
-			}
-			defer func() {
-				if closeErr := f.Close(); closeErr != nil {
-					err = errors.Join(err, closeErr)
-				}
-			}()
-
-			if _, err = io.Copy(f, tarReader); err != nil {
-				return err
+			if err := os.MkdirAll(filePath, 0o755); err != nil {
+				return fmt.Errorf("failed to mkdir %q: %w", filePath, err)
 			}
+			continue
+		}
 
-			if err = os.Chmod(filePath, os.FileMode(h.Mode)); err != nil {
-				return err
-			}
-		} else if h.Name != "pax_global_header" {
-			return fmt.Errorf("unknown file type for %s", h.Name)
+		if h.Size > maxGunzipFileSize {
+			return fmt.Errorf("%s: size %d exceeds the maximum file size of %d", h.Name, h.Size, maxGunzipFileSize)
+		}
+		if h.Size > remainingSize {
+			return fmt.Errorf("%s: extracting would exceed the maximum archive size of %d", h.Name, maxGunzipArchiveSize)
 		}
+		written, err := extractFile(tarReader, filePath, os.FileMode(h.Mode).Perm())
+		if err != nil {
+			return fmt.Errorf("failed to extract %s: %w", h.Name, err)
+		}
+		remainingSize -= written
+	}
+
+	return nil
+}
 
+// extractFile writes the contents of reader to filePath, closing the
+// file before it returns.
+func extractFile(reader io.Reader, filePath string, mode os.FileMode) (written int64, err error) {
+	parentPath := filepath.Dir(filePath)
+	if err := os.MkdirAll(parentPath, 0o755); err != nil {
+		return 0, fmt.Errorf("failed to mkdir %q: %w", parentPath, err)
 	}
 
-	return err
+	f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil {
+			err = errors.Join(err, closeErr)
+		}
+	}()
+
+	// tar.Reader never returns more than the size in the header
+	if written, err = io.Copy(f, reader); err != nil {
+		return written, err
+	}
+
+	return written, f.Chmod(mode)
 }
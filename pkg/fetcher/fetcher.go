@@ -1,6 +1,7 @@
 package fetcher
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -12,6 +13,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/google/go-github/v84/github"
@@ -20,6 +22,7 @@ import (
 
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/registry"
 	"helm.sh/helm/v3/pkg/repo"
 
 	"sigs.k8s.io/yaml"
@@ -27,6 +30,7 @@ import (
 
 const (
 	artifactHubAPI = "https://artifacthub.io/api/v1/packages/helm"
+	ociPrefix      = registry.OCIScheme + "://"
 )
 
 type ArtifactHubAPIHelmRepo struct {
@@ -51,6 +55,9 @@ type ChartSourceMetadata struct {
 // Constructs Chart Metadata for latest version published to Helm Repository
 func fetchUpstreamHelmrepo(upstreamYaml upstreamyaml.UpstreamYaml) (ChartSourceMetadata, error) {
 	upstreamYaml.HelmRepo = strings.TrimSuffix(upstreamYaml.HelmRepo, "/")
+	if strings.HasPrefix(upstreamYaml.HelmRepo, ociPrefix) {
+		return fetchUpstreamOCI(upstreamYaml)
+	}
 	url := fmt.Sprintf("%s/index.yaml", upstreamYaml.HelmRepo)
 
 	indexYaml := repo.NewIndexFile()
@@ -241,6 +248,57 @@ func gitCheckoutCommit(path, commit string) error {
 	return nil
 }
 
+// Constructs Chart Metadata for the versions of a chart published to an
+// OCI registry. HelmRepo is the registry and path that the chart is under,
+// such as oci://ghcr.io/example/charts. The registry does not provide chart
+// metadata other than the version, so versions only have Name and Version.
+func fetchUpstreamOCI(upstreamYaml upstreamyaml.UpstreamYaml) (ChartSourceMetadata, error) {
+	chartRef := fmt.Sprintf("%s/%s", upstreamYaml.HelmRepo, upstreamYaml.HelmChart)
+	registryClient, err := registry.NewClient()
+	if err != nil {
+		return ChartSourceMetadata{}, fmt.Errorf("failed to create registry client: %w", err)
+	}
+
+	// Tags returns only semver tags, sorted newest first
+	tags, err := registryClient.Tags(strings.TrimPrefix(chartRef, ociPrefix))
+	if err != nil {
+		return ChartSourceMetadata{}, fmt.Errorf("failed to list tags of %s: %w", chartRef, err)
+	}
+	if len(tags) == 0 {
+		return ChartSourceMetadata{}, fmt.Errorf("helm chart: %s not found", chartRef)
+	}
+
+	versions := make(repo.ChartVersions, 0, len(tags))
+	for _, tag := range tags {
+		versions = append(versions, &repo.ChartVersion{
+			Metadata: &chart.Metadata{
+				Name:    upstreamYaml.HelmChart,
+				Version: tag,
+			},
+			URLs: []string{fmt.Sprintf("%s:%s", chartRef, tag)},
+		})
+	}
+
+	return ChartSourceMetadata{
+		Source:   "OCI",
+		Versions: versions,
+	}, nil
+}
+
+// loadChartFromOCI pulls the chart at ref, which is of the form
+// oci://<registry>/<path>/<chart>:<version>.
+func loadChartFromOCI(ref string) (*chart.Chart, error) {
+	registryClient, err := registry.NewClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create registry client: %w", err)
+	}
+	result, err := registryClient.Pull(ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull %s: %w", ref, err)
+	}
+	return loader.LoadArchive(bytes.NewReader(result.Chart.Data))
+}
+
 // Constructs Chart Metadata for latest version published to Git Repository
 func fetchUpstreamGit(upstreamYaml upstreamyaml.UpstreamYaml) (ChartSourceMetadata, error) {
 	var upstreamCommit string
@@ -337,6 +395,9 @@ func FetchUpstream(upstreamYaml upstreamyaml.UpstreamYaml) (ChartSourceMetadata,
 
 func LoadChartFromURL(url string) (chart *chart.Chart, err error) {
 	logrus.Debugf("Loading chart from %s\n", url)
+	if strings.HasPrefix(url, ociPrefix) {
+		return loadChartFromOCI(url)
+	}
 	resp, err := http.Get(url)
 	if err != nil {
 		logrus.Errorf("Unable to fetch url %s", url)
@@ -387,3 +448,51 @@ func LoadChartFromGit(url, subDirectory, commit string) (*chart.Chart, error) {
 
 	return helmChart, err
 }
+
+// FetchDependency fetches the chart that satisfies dependency from the
+// helm repository or OCI registry that dependency refers to. The
+// repository must be an http, https or oci URL. If lockedVersion is set,
+// that exact version is fetched. Otherwise, the latest version that
+// satisfies the version constraint of dependency is fetched.
+func FetchDependency(dependency *chart.Dependency, lockedVersion string) (*chart.Chart, error) {
+	if !regexp.MustCompile("^(https?|oci)://").MatchString(dependency.Repository) {
+		return nil, fmt.Errorf("cannot fetch dependency %q from unsupported repository %q", dependency.Name, dependency.Repository)
+	}
+
+	versionConstraint := dependency.Version
+	if versionConstraint == "" {
+		versionConstraint = "*"
+	}
+	constraint, err := semver.NewConstraint(versionConstraint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse version constraint %q of dependency %q: %w", versionConstraint, dependency.Name, err)
+	}
+
+	upstreamYaml := upstreamyaml.UpstreamYaml{
+		HelmRepo:  dependency.Repository,
+		HelmChart: dependency.Name,
+	}
+	chartSourceMetadata, err := fetchUpstreamHelmrepo(upstreamYaml)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, version := range chartSourceMetadata.Versions {
+		if lockedVersion != "" {
+			if version.Version != lockedVersion {
+				continue
+			}
+		} else {
+			semVer, err := semver.NewVersion(version.Version)
+			if err != nil || !constraint.Check(semVer) {
+				continue
+			}
+		}
+		return LoadChartFromURL(version.URLs[0])
+	}
+
+	if lockedVersion != "" {
+		return nil, fmt.Errorf("version %s of dependency %q not found in %s", lockedVersion, dependency.Name, dependency.Repository)
+	}
+	return nil, fmt.Errorf("no version of dependency %q in %s satisfies %q", dependency.Name, dependency.Repository, versionConstraint)
+}
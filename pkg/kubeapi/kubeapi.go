@@ -0,0 +1,301 @@
+package kubeapi
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/releaseutil"
+
+	"sigs.k8s.io/yaml"
+)
+
+// latestKubernetesMinor is the newest Kubernetes 1.x minor version that
+// charts are checked against. Rendering for newer versions cannot find
+// anything more than rendering for the version after the last removal in
+// removedAPIs, so it follows that table as entries are added to it.
+var latestKubernetesMinor = slices.MaxFunc(removedAPIs, func(a, b RemovedAPI) int {
+	return a.RemovedIn - b.RemovedIn
+}).RemovedIn + 1
+
+// RemovedAPI is a Kubernetes API, i.e. a combination of apiVersion and
+// kind, that is no longer served by Kubernetes.
+type RemovedAPI struct {
+	APIVersion string
+	Kind       string
+	// The Kubernetes 1.x minor version in which the API was removed
+	RemovedIn int
+	// The apiVersion that replaces the removed one, if there is one
+	Replacement string
+}
+
+// removedAPIs is taken from the Kubernetes deprecated API migration guide:
+// https://kubernetes.io/docs/reference/using-api/deprecation-guide/
+var removedAPIs = []RemovedAPI{
+	{APIVersion: "extensions/v1beta1", Kind: "DaemonSet", RemovedIn: 16, Replacement: "apps/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "Deployment", RemovedIn: 16, Replacement: "apps/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "ReplicaSet", RemovedIn: 16, Replacement: "apps/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "NetworkPolicy", RemovedIn: 16, Replacement: "networking.k8s.io/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "PodSecurityPolicy", RemovedIn: 16, Replacement: "policy/v1beta1"},
+	{APIVersion: "apps/v1beta1", Kind: "Deployment", RemovedIn: 16, Replacement: "apps/v1"},
+	{APIVersion: "apps/v1beta1", Kind: "StatefulSet", RemovedIn: 16, Replacement: "apps/v1"},
+	{APIVersion: "apps/v1beta1", Kind: "ReplicaSet", RemovedIn: 16, Replacement: "apps/v1"},
+	{APIVersion: "apps/v1beta2", Kind: "DaemonSet", RemovedIn: 16, Replacement: "apps/v1"},
+	{APIVersion: "apps/v1beta2", Kind: "Deployment", RemovedIn: 16, Replacement: "apps/v1"},
+	{APIVersion: "apps/v1beta2", Kind: "StatefulSet", RemovedIn: 16, Replacement: "apps/v1"},
+	{APIVersion: "apps/v1beta2", Kind: "ReplicaSet", RemovedIn: 16, Replacement: "apps/v1"},
+
+	{APIVersion: "admissionregistration.k8s.io/v1beta1", Kind: "MutatingWebhookConfiguration", RemovedIn: 22, Replacement: "admissionregistration.k8s.io/v1"},
+	{APIVersion: "admissionregistration.k8s.io/v1beta1", Kind: "ValidatingWebhookConfiguration", RemovedIn: 22, Replacement: "admissionregistration.k8s.io/v1"},
+	{APIVersion: "apiextensions.k8s.io/v1beta1", Kind: "CustomResourceDefinition", RemovedIn: 22, Replacement: "apiextensions.k8s.io/v1"},
+	{APIVersion: "apiregistration.k8s.io/v1beta1", Kind: "APIService", RemovedIn: 22, Replacement: "apiregistration.k8s.io/v1"},
+	{APIVersion: "authentication.k8s.io/v1beta1", Kind: "TokenReview", RemovedIn: 22, Replacement: "authentication.k8s.io/v1"},
+	{APIVersion: "authorization.k8s.io/v1beta1", Kind: "LocalSubjectAccessReview", RemovedIn: 22, Replacement: "authorization.k8s.io/v1"},
+	{APIVersion: "authorization.k8s.io/v1beta1", Kind: "SelfSubjectAccessReview", RemovedIn: 22, Replacement: "authorization.k8s.io/v1"},
+	{APIVersion: "authorization.k8s.io/v1beta1", Kind: "SubjectAccessReview", RemovedIn: 22, Replacement: "authorization.k8s.io/v1"},
+	{APIVersion: "certificates.k8s.io/v1beta1", Kind: "CertificateSigningRequest", RemovedIn: 22, Replacement: "certificates.k8s.io/v1"},
+	{APIVersion: "coordination.k8s.io/v1beta1", Kind: "Lease", RemovedIn: 22, Replacement: "coordination.k8s.io/v1"},
+	{APIVersion: "extensions/v1beta1", Kind: "Ingress", RemovedIn: 22, Replacement: "networking.k8s.io/v1"},
+	{APIVersion: "networking.k8s.io/v1beta1", Kind: "Ingress", RemovedIn: 22, Replacement: "networking.k8s.io/v1"},
+	{APIVersion: "networking.k8s.io/v1beta1", Kind: "IngressClass", RemovedIn: 22, Replacement: "networking.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "ClusterRole", RemovedIn: 22, Replacement: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "ClusterRoleBinding", RemovedIn: 22, Replacement: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "Role", RemovedIn: 22, Replacement: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "RoleBinding", RemovedIn: 22, Replacement: "rbac.authorization.k8s.io/v1"},
+	{APIVersion: "scheduling.k8s.io/v1beta1", Kind: "PriorityClass", RemovedIn: 22, Replacement: "scheduling.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "CSIDriver", RemovedIn: 22, Replacement: "storage.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "CSINode", RemovedIn: 22, Replacement: "storage.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "StorageClass", RemovedIn: 22, Replacement: "storage.k8s.io/v1"},
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "VolumeAttachment", RemovedIn: 22, Replacement: "storage.k8s.io/v1"},
+
+	{APIVersion: "batch/v1beta1", Kind: "CronJob", RemovedIn: 25, Replacement: "batch/v1"},
+	{APIVersion: "discovery.k8s.io/v1beta1", Kind: "EndpointSlice", RemovedIn: 25, Replacement: "discovery.k8s.io/v1"},
+	{APIVersion: "events.k8s.io/v1beta1", Kind: "Event", RemovedIn: 25, Replacement: "events.k8s.io/v1"},
+	{APIVersion: "autoscaling/v2beta1", Kind: "HorizontalPodAutoscaler", RemovedIn: 25, Replacement: "autoscaling/v2"},
+	{APIVersion: "policy/v1beta1", Kind: "PodDisruptionBudget", RemovedIn: 25, Replacement: "policy/v1"},
+	{APIVersion: "policy/v1beta1", Kind: "PodSecurityPolicy", RemovedIn: 25},
+	{APIVersion: "node.k8s.io/v1beta1", Kind: "RuntimeClass", RemovedIn: 25, Replacement: "node.k8s.io/v1"},
+
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta1", Kind: "FlowSchema", RemovedIn: 26, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta1", Kind: "PriorityLevelConfiguration", RemovedIn: 26, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{APIVersion: "autoscaling/v2beta2", Kind: "HorizontalPodAutoscaler", RemovedIn: 26, Replacement: "autoscaling/v2"},
+
+	{APIVersion: "storage.k8s.io/v1beta1", Kind: "CSIStorageCapacity", RemovedIn: 27, Replacement: "storage.k8s.io/v1"},
+
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta2", Kind: "FlowSchema", RemovedIn: 29, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta2", Kind: "PriorityLevelConfiguration", RemovedIn: 29, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta3", Kind: "FlowSchema", RemovedIn: 32, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{APIVersion: "flowcontrol.apiserver.k8s.io/v1beta3", Kind: "PriorityLevelConfiguration", RemovedIn: 32, Replacement: "flowcontrol.apiserver.k8s.io/v1"},
+}
+
+// RemovedAPIUsage is a resource in a rendered chart that uses a
+// removed API.
+type RemovedAPIUsage struct {
+	RemovedAPI
+	// The file in the chart that the resource comes from
+	Template string
+	// The first Kubernetes version allowed by the chart's kubeVersion
+	// for which the chart renders the removed API
+	KubeVersion string
+}
+
+func (usage RemovedAPIUsage) String() string {
+	message := fmt.Sprintf("%s: %s %s is removed in Kubernetes 1.%d, but kubeVersion allows %s",
+		usage.Template, usage.APIVersion, usage.Kind, usage.RemovedIn, usage.KubeVersion)
+	if usage.Replacement != "" {
+		message += fmt.Sprintf("; use %s instead", usage.Replacement)
+	}
+	return message
+}
+
+// resource is the part of a Kubernetes manifest that identifies its API.
+type resource struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+}
+
+// FindRemovedAPIUsages renders helmChart once for each Kubernetes version
+// allowed by the chart's kubeVersion constraint and returns the resources
+// that use an API that has been removed in that version. A chart without
+// a kubeVersion constraint is considered to allow every Kubernetes version.
+func FindRemovedAPIUsages(helmChart *chart.Chart) ([]RemovedAPIUsage, error) {
+	var constraint *semver.Constraints
+	if kubeVersion := helmChart.Metadata.KubeVersion; kubeVersion != "" {
+		var err error
+		constraint, err = semver.NewConstraint(kubeVersion)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse kubeVersion %q: %w", kubeVersion, err)
+		}
+	}
+
+	// Processing dependencies modifies the chart, so we work on a copy
+	renderableChart, err := copyChart(helmChart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy chart: %w", err)
+	}
+	if err := chartutil.ProcessDependencies(renderableChart, renderableChart.Values); err != nil {
+		return nil, fmt.Errorf("failed to process dependencies: %w", err)
+	}
+
+	earliestRemoval := slices.MinFunc(removedAPIs, func(a, b RemovedAPI) int {
+		return a.RemovedIn - b.RemovedIn
+	}).RemovedIn
+
+	usages := make([]RemovedAPIUsage, 0)
+	found := map[string]bool{}
+	for minor := earliestRemoval; minor <= latestKubernetesMinor; minor++ {
+		kubeVersion := semver.New(1, uint64(minor), 0, "", "")
+		if constraint != nil && !constraint.Check(kubeVersion) {
+			continue
+		}
+		manifests, err := renderManifests(renderableChart, minor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render chart for Kubernetes %s: %w", kubeVersion, err)
+		}
+		templates := make([]string, 0, len(manifests))
+		for template := range manifests {
+			templates = append(templates, template)
+		}
+		slices.Sort(templates)
+		for _, template := range templates {
+			for _, res := range manifests[template] {
+				removedAPI, ok := lookupRemovedAPI(res, minor)
+				if !ok {
+					continue
+				}
+				key := template + "/" + res.APIVersion + "/" + res.Kind
+				if found[key] {
+					continue
+				}
+				found[key] = true
+				usages = append(usages, RemovedAPIUsage{
+					RemovedAPI:  removedAPI,
+					Template:    template,
+					KubeVersion: kubeVersion.String(),
+				})
+			}
+		}
+	}
+
+	return usages, nil
+}
+
+// lookupRemovedAPI returns the RemovedAPI that res uses, if res uses an
+// API that is removed in Kubernetes 1.<minor>.
+func lookupRemovedAPI(res resource, minor int) (RemovedAPI, bool) {
+	for _, removedAPI := range removedAPIs {
+		if removedAPI.APIVersion == res.APIVersion && removedAPI.Kind == res.Kind && removedAPI.RemovedIn <= minor {
+			return removedAPI, true
+		}
+	}
+	return RemovedAPI{}, false
+}
+
+// servedAPIVersions returns apiVersions without the APIs that are removed
+// in Kubernetes 1.<minor>, so that charts that check
+// .Capabilities.APIVersions.Has select the API that is still served. An
+// apiVersion such as batch/v1beta1 is removed once every kind of it in
+// removedAPIs is, and an entry such as batch/v1beta1/CronJob once that
+// kind is.
+func servedAPIVersions(apiVersions chartutil.VersionSet, minor int) chartutil.VersionSet {
+	removed := map[string]bool{}
+	served := map[string]bool{}
+	for _, removedAPI := range removedAPIs {
+		if removedAPI.RemovedIn <= minor {
+			removed[removedAPI.APIVersion+"/"+removedAPI.Kind] = true
+		} else {
+			served[removedAPI.APIVersion] = true
+		}
+	}
+	for _, removedAPI := range removedAPIs {
+		if !served[removedAPI.APIVersion] {
+			removed[removedAPI.APIVersion] = true
+		}
+	}
+	return slices.DeleteFunc(slices.Clone(apiVersions), func(apiVersion string) bool {
+		return removed[apiVersion]
+	})
+}
+
+// renderManifests renders helmChart as it would be installed on Kubernetes
+// 1.<minor>. It returns the resources from each template, plus those from
+// the chart's CRD files, keyed by the file they come from.
+func renderManifests(helmChart *chart.Chart, minor int) (map[string][]resource, error) {
+	capabilities := chartutil.DefaultCapabilities.Copy()
+	capabilities.KubeVersion = chartutil.KubeVersion{
+		Version: fmt.Sprintf("v1.%d.0", minor),
+		Major:   "1",
+		Minor:   strconv.Itoa(minor),
+	}
+	capabilities.APIVersions = servedAPIVersions(capabilities.APIVersions, minor)
+	releaseOptions := chartutil.ReleaseOptions{
+		Name:      helmChart.Name(),
+		Namespace: "default",
+		Revision:  1,
+		IsInstall: true,
+	}
+	values, err := chartutil.ToRenderValuesWithSchemaValidation(helmChart, helmChart.Values, releaseOptions, capabilities, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get render values: %w", err)
+	}
+
+	// LintMode keeps "required" from failing when a chart needs values
+	// that only the user can provide
+	rendered, err := engine.Engine{LintMode: true}.Render(helmChart, values)
+	if err != nil {
+		return nil, err
+	}
+	for _, crd := range helmChart.CRDObjects() {
+		rendered[crd.Filename] = string(crd.File.Data)
+	}
+
+	manifests := make(map[string][]resource, len(rendered))
+	for template, contents := range rendered {
+		if !strings.HasSuffix(template, ".yaml") && !strings.HasSuffix(template, ".yml") && !strings.HasSuffix(template, ".json") {
+			continue
+		}
+		for _, document := range releaseutil.SplitManifests(contents) {
+			res := resource{}
+			if err := yaml.Unmarshal([]byte(document), &res); err != nil {
+				return nil, fmt.Errorf("failed to parse manifest in %s: %w", template, err)
+			}
+			if res.APIVersion == "" || res.Kind == "" {
+				continue
+			}
+			manifests[template] = append(manifests[template], res)
+		}
+	}
+
+	return manifests, nil
+}
+
+// copyChart returns a deep copy of helmChart by round-tripping it through
+// a chart archive.
+func copyChart(helmChart *chart.Chart) (copied *chart.Chart, err error) {
+	tempDir, err := os.MkdirTemp("", "partner-charts-ci-kubeapi-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer func() {
+		if removeErr := os.RemoveAll(tempDir); removeErr != nil {
+			err = errors.Join(err, removeErr)
+		}
+	}()
+
+	tgzPath, err := chartutil.Save(helmChart, tempDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save chart: %w", err)
+	}
+
+	return loader.LoadFile(tgzPath)
+}
@@ -0,0 +1,141 @@
+package kubeapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+const cronJobTemplate = `apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: {{ .Release.Name }}
+`
+
+const capabilitiesCronJobTemplate = `{{- if semverCompare ">=1.21-0" .Capabilities.KubeVersion.Version }}
+apiVersion: batch/v1
+{{- else }}
+apiVersion: batch/v1beta1
+{{- end }}
+kind: CronJob
+metadata:
+  name: {{ .Release.Name }}
+`
+
+const apiVersionsCronJobTemplate = `{{- if .Capabilities.APIVersions.Has "batch/v1beta1" }}
+apiVersion: batch/v1beta1
+{{- else }}
+apiVersion: batch/v1
+{{- end }}
+kind: CronJob
+metadata:
+  name: {{ .Release.Name }}
+`
+
+func newTestChart(kubeVersion, template string) *chart.Chart {
+	return &chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion:  "v2",
+			Name:        "testchart",
+			Version:     "1.2.3",
+			KubeVersion: kubeVersion,
+		},
+		Templates: []*chart.File{
+			{
+				Name: "templates/cronjob.yaml",
+				Data: []byte(template),
+			},
+		},
+	}
+}
+
+func TestFindRemovedAPIUsages(t *testing.T) {
+	t.Run("should find removed API when kubeVersion allows Kubernetes version it was removed in", func(t *testing.T) {
+		helmChart := newTestChart(">=1.21-0", cronJobTemplate)
+		usages, err := FindRemovedAPIUsages(helmChart)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		assert.Len(t, usages, 1)
+		assert.Equal(t, "batch/v1beta1", usages[0].APIVersion)
+		assert.Equal(t, "CronJob", usages[0].Kind)
+		assert.Equal(t, "testchart/templates/cronjob.yaml", usages[0].Template)
+		assert.Equal(t, "1.25.0", usages[0].KubeVersion)
+		assert.Contains(t, usages[0].String(), "use batch/v1 instead")
+	})
+
+	t.Run("should find removed API when kubeVersion is not set", func(t *testing.T) {
+		helmChart := newTestChart("", cronJobTemplate)
+		usages, err := FindRemovedAPIUsages(helmChart)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		assert.Len(t, usages, 1)
+	})
+
+	t.Run("should not find removed API when kubeVersion excludes Kubernetes version it was removed in", func(t *testing.T) {
+		helmChart := newTestChart(">=1.21-0 <1.25.0-0", cronJobTemplate)
+		usages, err := FindRemovedAPIUsages(helmChart)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		assert.Len(t, usages, 0)
+	})
+
+	t.Run("should not find removed API when chart selects API version using capabilities", func(t *testing.T) {
+		helmChart := newTestChart("", capabilitiesCronJobTemplate)
+		usages, err := FindRemovedAPIUsages(helmChart)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		assert.Len(t, usages, 0)
+	})
+
+	t.Run("should not find removed API when chart selects API version using API versions", func(t *testing.T) {
+		helmChart := newTestChart("", apiVersionsCronJobTemplate)
+		usages, err := FindRemovedAPIUsages(helmChart)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		assert.Len(t, usages, 0)
+	})
+
+	t.Run("should find removed API in CRD files", func(t *testing.T) {
+		helmChart := newTestChart(">=1.16-0", "")
+		helmChart.Files = []*chart.File{
+			{
+				Name: "crds/crd.yaml",
+				Data: []byte("apiVersion: apiextensions.k8s.io/v1beta1\nkind: CustomResourceDefinition\n"),
+			},
+		}
+		usages, err := FindRemovedAPIUsages(helmChart)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		assert.Len(t, usages, 1)
+		assert.Equal(t, "CustomResourceDefinition", usages[0].Kind)
+		assert.Equal(t, "1.22.0", usages[0].KubeVersion)
+	})
+
+	t.Run("should return error for invalid kubeVersion", func(t *testing.T) {
+		helmChart := newTestChart("not a constraint", cronJobTemplate)
+		_, err := FindRemovedAPIUsages(helmChart)
+		assert.ErrorContains(t, err, "failed to parse kubeVersion")
+	})
+}
+
+func TestServedAPIVersions(t *testing.T) {
+	apiVersions := chartutil.VersionSet{"batch/v1", "batch/v1beta1", "batch/v1beta1/CronJob", "extensions/v1beta1"}
+
+	t.Run("should keep APIs that are still served", func(t *testing.T) {
+		assert.Equal(t, apiVersions, servedAPIVersions(apiVersions, 16))
+	})
+
+	t.Run("should remove API version once all of its kinds are removed", func(t *testing.T) {
+		assert.Equal(t, chartutil.VersionSet{"batch/v1", "batch/v1beta1", "batch/v1beta1/CronJob"}, servedAPIVersions(apiVersions, 22))
+		assert.Equal(t, chartutil.VersionSet{"batch/v1"}, servedAPIVersions(apiVersions, 25))
+	})
+}
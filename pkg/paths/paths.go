@@ -19,6 +19,7 @@ type Paths struct {
 	IndexYaml         string
 	Packages          string
 	RepoRoot          string
+	StaleYaml         string
 }
 
 func GetPaths() (Paths, error) {
@@ -44,6 +45,7 @@ func GetPaths() (Paths, error) {
 			IndexYaml:         "index.yaml",
 			Packages:          "packages",
 			RepoRoot:          repoRoot,
+			StaleYaml:         "stale.yaml",
 		}
 	}
 	return *paths, nil
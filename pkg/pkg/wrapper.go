@@ -150,7 +150,7 @@ func ListPackageWrappers(paths p.Paths, currentPackage string) ([]PackageWrapper
 		upstreamYamlPath := filepath.Join(packageWrapper.Path, "upstream.yaml")
 		upstreamYaml, err := upstreamyaml.Parse(upstreamYamlPath)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse upstream.yaml: %w", err)
+			return nil, fmt.Errorf("failed to parse %s: %w", upstreamYamlPath, err)
 		}
 		packageWrapper.UpstreamYaml = upstreamYaml
 
@@ -225,12 +225,12 @@ func collectNonStoredVersions(versions repo.ChartVersions, storedVersions repo.C
 				break
 			}
 		}
-		if stored && i == 0 && (strings.ToLower(fetch) == "" || strings.ToLower(fetch) == "latest") {
+		if stored && i == 0 && (fetch == "" || fetch == "latest") {
 			logrus.Debugf("Latest version already stored")
 			break
 		}
 		if !stored {
-			if fetch == strings.ToLower("newer") {
+			if fetch == "newer" {
 				var semVer *semver.Version
 				semVer, err := semver.NewVersion(version.Version)
 				if err != nil {
@@ -251,7 +251,7 @@ func collectNonStoredVersions(versions repo.ChartVersions, storedVersions repo.C
 				} else {
 					nonStoredVersions = append(nonStoredVersions, version)
 				}
-			} else if fetch == strings.ToLower("all") {
+			} else if fetch == "all" {
 				nonStoredVersions = append(nonStoredVersions, version)
 			} else {
 				nonStoredVersions = append(nonStoredVersions, version)
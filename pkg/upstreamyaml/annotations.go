@@ -0,0 +1,230 @@
+package upstreamyaml
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	AnnotationOS             = "catalog.cattle.io/os"
+	AnnotationPermitsOS      = "catalog.cattle.io/permits-os"
+	AnnotationProvidesGVR    = "catalog.cattle.io/provides-gvr"
+	AnnotationRancherVersion = "catalog.cattle.io/rancher-version"
+	AnnotationRequestsCPU    = "catalog.cattle.io/requests-cpu"
+	AnnotationRequestsMemory = "catalog.cattle.io/requests-memory"
+	AnnotationScope          = "catalog.cattle.io/scope"
+	AnnotationType           = "catalog.cattle.io/type"
+	AnnotationUIComponent    = "catalog.cattle.io/ui-component"
+)
+
+// OSValues are the values that OS and the items of PermitsOS may have.
+var OSValues = []string{"linux", "windows"}
+
+// ScopeValues are the values that Scope may have.
+var ScopeValues = []string{"management", "downstream"}
+
+// TypeValues are the values that Type may have.
+var TypeValues = []string{"app", "cluster-tool", "cluster-template"}
+
+// groupVersionResource matches a provides-gvr entry such as
+// monitoring.coreos.com.prometheuses/v1, or pods/v1 for the core group.
+var groupVersionResource = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?/v[0-9]+((alpha|beta)[0-9]+)?$`)
+
+// uiComponentName matches the name of a Rancher UI component.
+var uiComponentName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// A catalogAnnotation is a catalog.cattle.io annotation that is set from
+// a field of UpstreamYaml.
+type catalogAnnotation struct {
+	Field      string
+	Annotation string
+	// Value returns the value of the annotation, or an empty string if
+	// the field is not set.
+	Value func(upstreamYaml *UpstreamYaml) string
+	// Validate returns an error if value, the value of the field or
+	// annotation called name, is invalid. Lists are joined with commas,
+	// as in the annotation. It is only called if value is not empty.
+	Validate func(name, value string) error
+}
+
+var catalogAnnotations = []catalogAnnotation{
+	{
+		Field:      "OS",
+		Annotation: AnnotationOS,
+		Value:      func(u *UpstreamYaml) string { return u.OS },
+		Validate: func(name, value string) error {
+			return checkOneOf(name, value, OSValues)
+		},
+	},
+	{
+		Field:      "PermitsOS",
+		Annotation: AnnotationPermitsOS,
+		Value:      func(u *UpstreamYaml) string { return strings.Join(u.PermitsOS, ",") },
+		Validate: func(name, value string) error {
+			permitsOS := strings.Split(value, ",")
+			for _, os := range permitsOS {
+				if err := checkOneOf(name, os, OSValues); err != nil {
+					return err
+				}
+			}
+			return checkUnique(name, permitsOS)
+		},
+	},
+	{
+		Field:      "ProvidesGVR",
+		Annotation: AnnotationProvidesGVR,
+		Value:      func(u *UpstreamYaml) string { return strings.Join(u.ProvidesGVR, ",") },
+		Validate: func(name, value string) error {
+			providesGVR := strings.Split(value, ",")
+			for _, gvr := range providesGVR {
+				if !groupVersionResource.MatchString(gvr) {
+					return fmt.Errorf("%s contains %q, which is not in <group>.<resource>/<version> format", name, gvr)
+				}
+			}
+			return checkUnique(name, providesGVR)
+		},
+	},
+	{
+		Field:      "RancherVersion",
+		Annotation: AnnotationRancherVersion,
+		Value:      func(u *UpstreamYaml) string { return u.RancherVersion },
+		Validate: func(name, value string) error {
+			if _, err := semver.NewConstraint(value); err != nil {
+				return fmt.Errorf("%s %q is not a valid version range: %w", name, value, err)
+			}
+			return nil
+		},
+	},
+	{
+		Field:      "RequestsCPU",
+		Annotation: AnnotationRequestsCPU,
+		Value:      func(u *UpstreamYaml) string { return u.RequestsCPU },
+		Validate:   checkQuantity,
+	},
+	{
+		Field:      "RequestsMemory",
+		Annotation: AnnotationRequestsMemory,
+		Value:      func(u *UpstreamYaml) string { return u.RequestsMemory },
+		Validate:   checkQuantity,
+	},
+	{
+		Field:      "Scope",
+		Annotation: AnnotationScope,
+		Value:      func(u *UpstreamYaml) string { return u.Scope },
+		Validate: func(name, value string) error {
+			return checkOneOf(name, value, ScopeValues)
+		},
+	},
+	{
+		Field:      "Type",
+		Annotation: AnnotationType,
+		Value:      func(u *UpstreamYaml) string { return u.Type },
+		Validate: func(name, value string) error {
+			return checkOneOf(name, value, TypeValues)
+		},
+	},
+	{
+		Field:      "UIComponent",
+		Annotation: AnnotationUIComponent,
+		Value:      func(u *UpstreamYaml) string { return u.UIComponent },
+		Validate: func(name, value string) error {
+			if !uiComponentName.MatchString(value) {
+				return fmt.Errorf("%s %q must consist of lowercase letters, digits and dashes", name, value)
+			}
+			return nil
+		},
+	},
+}
+
+// CatalogAnnotations returns the catalog.cattle.io annotations that are
+// set by the fields of upstreamYaml, such as RancherVersion. Fields that
+// are not set are omitted.
+func (upstreamYaml *UpstreamYaml) CatalogAnnotations() map[string]string {
+	annotations := map[string]string{}
+	for _, catalogAnnotation := range catalogAnnotations {
+		if value := catalogAnnotation.Value(upstreamYaml); value != "" {
+			annotations[catalogAnnotation.Annotation] = value
+		}
+	}
+	return annotations
+}
+
+// CatalogAnnotationField returns the upstream.yaml field that sets
+// annotation, or an empty string if no field sets it.
+func CatalogAnnotationField(annotation string) string {
+	for _, catalogAnnotation := range catalogAnnotations {
+		if catalogAnnotation.Annotation == annotation {
+			return catalogAnnotation.Field
+		}
+	}
+	return ""
+}
+
+// ValidateCatalogAnnotation returns an error if value is not a valid
+// value of annotation. It applies the same rules as upstream.yaml does to
+// the field that sets annotation. Annotations that no field sets are not
+// checked.
+func ValidateCatalogAnnotation(annotation, value string) error {
+	for _, catalogAnnotation := range catalogAnnotations {
+		if catalogAnnotation.Annotation != annotation {
+			continue
+		}
+		if value == "" {
+			return fmt.Errorf("%s must not be empty", annotation)
+		}
+		return catalogAnnotation.Validate(annotation, value)
+	}
+	return nil
+}
+
+// validateCatalogAnnotations validates the fields that set
+// catalog.cattle.io annotations. A field may not be set together with the
+// same annotation in ChartMetadata.Annotations.
+func (upstreamYaml *UpstreamYaml) validateCatalogAnnotations() error {
+	for _, catalogAnnotation := range catalogAnnotations {
+		value := catalogAnnotation.Value(upstreamYaml)
+		if value == "" {
+			continue
+		}
+		if err := catalogAnnotation.Validate(catalogAnnotation.Field, value); err != nil {
+			return &FieldError{Field: catalogAnnotation.Field, Err: err}
+		}
+		if _, ok := upstreamYaml.ChartMetadata.Annotations[catalogAnnotation.Annotation]; ok {
+			return fieldErrorf(catalogAnnotation.Field, "%s is set but ChartMetadata.Annotations also sets %s", catalogAnnotation.Field, catalogAnnotation.Annotation)
+		}
+	}
+	return nil
+}
+
+func checkOneOf(name, value string, values []string) error {
+	if !slices.Contains(values, value) {
+		return fmt.Errorf("%s must be one of %s but is %q", name, quoteList(values), value)
+	}
+	return nil
+}
+
+func checkUnique(name string, values []string) error {
+	for i, value := range values {
+		if slices.Contains(values[:i], value) {
+			return fmt.Errorf("%s contains %q more than once", name, value)
+		}
+	}
+	return nil
+}
+
+func checkQuantity(name, value string) error {
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return fmt.Errorf("%s %q is not a valid quantity: %w", name, value, err)
+	}
+	if quantity.Sign() <= 0 {
+		return fmt.Errorf("%s must be positive but is %q", name, value)
+	}
+	return nil
+}
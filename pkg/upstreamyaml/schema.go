@@ -0,0 +1,163 @@
+package upstreamyaml
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/rancher/partner-charts-ci/pkg/conform"
+)
+
+// schemaFile is the name of the JSON Schema for upstream.yaml that is
+// checked into this directory for editors to use.
+const schemaFile = "upstream.schema.json"
+
+// fieldDescriptions describes the top-level fields of upstream.yaml in
+// the schema.
+var fieldDescriptions = map[string]string{
+	"ArtifactHubPackage":  "The package to pull from ArtifactHubRepo",
+	"ArtifactHubRepo":     "The repo to access on Artifact Hub",
+	"AutoInstall":         "A chart to install before this chart, such as a dedicated CRDs chart",
+	"ChartMetadata":       "Chart.yaml fields to set in upstream charts",
+	"ChartMetadataMerge":  "How the list fields of ChartMetadata are combined with those of upstream charts",
+	"ChartMetadataUnset":  "Chart.yaml fields to remove from upstream charts",
+	"Deprecated":          "Whether the package is deprecated. Use partner-charts-ci deprecate instead of setting it",
+	"DisplayName":         "The name of the chart in the Rancher UI",
+	"Experimental":        "Adds the experimental annotation to charts",
+	"Fetch":               "Which upstream chart versions to integrate",
+	"GitBranch":           "The branch of GitRepo to pull",
+	"GitHubRelease":       "Pull the latest GitHub release of GitRepo",
+	"GitRepo":             "The git repo to pull the chart from",
+	"GitSubdirectory":     "The subdirectory of GitRepo that contains the chart",
+	"HelmChart":           "The chart to pull from HelmRepo",
+	"HelmRepo":            "The Helm repo to pull HelmChart from. May be an oci:// URL",
+	"Hidden":              "Adds the hidden annotation to charts. Use partner-charts-ci hide instead of setting it",
+	"Namespace":           "The namespace that the chart is installed into",
+	"OS":                  "The operating system that the chart can be installed on. Sets the catalog.cattle.io/os annotation",
+	"PackageVersion":      "Unused",
+	"PermitsOS":           "The operating systems whose nodes the chart can run on. Sets the catalog.cattle.io/permits-os annotation",
+	"PreserveKubeVersion": "Do not add -0 to the lower bounds of kubeVersion",
+	"ProvidesGVR":         "The resources that the chart provides, such as monitoring.coreos.com.prometheuses/v1. Sets the catalog.cattle.io/provides-gvr annotation",
+	"RancherVersion":      "The range of Rancher versions that the chart supports. Sets the catalog.cattle.io/rancher-version annotation",
+	"ReleaseName":         "The release name that the chart is installed with",
+	"RequestsCPU":         "The CPU that the chart needs, such as 500m. Sets the catalog.cattle.io/requests-cpu annotation",
+	"RequestsMemory":      "The memory that the chart needs, such as 256Mi. Sets the catalog.cattle.io/requests-memory annotation",
+	"Retention":           "Which chart versions partner-charts-ci cull keeps",
+	"Scope":               "The clusters that the chart is meant for. Sets the catalog.cattle.io/scope annotation",
+	"Type":                "The kind of chart. Sets the catalog.cattle.io/type annotation",
+	"UIComponent":         "The Rancher UI component that the chart uses. Sets the catalog.cattle.io/ui-component annotation",
+	"Vendor":              "The name of the vendor in the Rancher UI",
+}
+
+// Schema returns a JSON Schema for upstream.yaml. It is generated from
+// UpstreamYaml so that it accepts the same fields as Parse.
+func Schema() ([]byte, error) {
+	schema := getTypeSchema(reflect.TypeOf(UpstreamYaml{}))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "upstream.yaml"
+
+	properties := schema["properties"].(map[string]any)
+	for name, property := range properties {
+		description, ok := fieldDescriptions[name]
+		if !ok {
+			return nil, fmt.Errorf("field %s has no description", name)
+		}
+		property.(map[string]any)["description"] = description
+	}
+	// Fetch is accepted in any case, so the schema uses a pattern
+	// rather than an enum
+	properties["Fetch"].(map[string]any)["pattern"] = getCaseInsensitivePattern(FetchValues)
+	properties["Fetch"].(map[string]any)["default"] = "latest"
+	properties["ChartMetadataMerge"].(map[string]any)["propertyNames"] = map[string]any{
+		"enum": conform.ListFields,
+	}
+	properties["ChartMetadataMerge"].(map[string]any)["additionalProperties"] = map[string]any{
+		"enum": []conform.ListMergeStrategy{conform.ListMergeAppend, conform.ListMergeAppendUnique, conform.ListMergeReplace},
+	}
+	properties["ChartMetadataUnset"].(map[string]any)["items"] = map[string]any{
+		"enum": conform.UnsettableFields,
+	}
+	properties["HelmRepo"].(map[string]any)["format"] = "uri"
+	properties["OS"].(map[string]any)["enum"] = OSValues
+	properties["PermitsOS"].(map[string]any)["items"] = map[string]any{
+		"enum": OSValues,
+	}
+	properties["PermitsOS"].(map[string]any)["uniqueItems"] = true
+	properties["ProvidesGVR"].(map[string]any)["items"] = map[string]any{
+		"type":    "string",
+		"pattern": groupVersionResource.String(),
+	}
+	properties["ProvidesGVR"].(map[string]any)["uniqueItems"] = true
+	properties["Scope"].(map[string]any)["enum"] = ScopeValues
+	properties["Type"].(map[string]any)["enum"] = TypeValues
+	properties["UIComponent"].(map[string]any)["pattern"] = uiComponentName.String()
+	properties["PackageVersion"].(map[string]any)["deprecated"] = true
+
+	contents, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal schema: %w", err)
+	}
+	return append(contents, '\n'), nil
+}
+
+// getCaseInsensitivePattern returns a regular expression that matches
+// any of values in any case. It does not use the (?i) flag, which the
+// ECMA-262 regular expressions of JSON Schema do not support.
+func getCaseInsensitivePattern(values []string) string {
+	alternatives := make([]string, 0, len(values))
+	for _, value := range values {
+		var alternative strings.Builder
+		for _, r := range value {
+			lower, upper := unicode.ToLower(r), unicode.ToUpper(r)
+			if lower == upper {
+				alternative.WriteString(regexp.QuoteMeta(string(r)))
+			} else {
+				fmt.Fprintf(&alternative, "[%c%c]", upper, lower)
+			}
+		}
+		alternatives = append(alternatives, alternative.String())
+	}
+	return "^(" + strings.Join(alternatives, "|") + ")$"
+}
+
+func getTypeSchema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		fields := getStructFields(t)
+		properties := make(map[string]any, len(fields))
+		for name, fieldType := range fields {
+			properties[name] = getTypeSchema(fieldType)
+		}
+		return map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+	case reflect.Map:
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": getTypeSchema(t.Elem()),
+		}
+	case reflect.Slice:
+		return map[string]any{
+			"type":  "array",
+			"items": getTypeSchema(t.Elem()),
+		}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	}
+	return map[string]any{}
+}
@@ -0,0 +1,257 @@
+package upstreamyaml
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// A FieldError is a problem with a field of upstream.yaml. Field is the
+// path to the field, such as "Retention.Pinned". Line is the line of
+// upstream.yaml that the field is on, or 0 if it is not known.
+type FieldError struct {
+	Field string
+	Line  int
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+	}
+	return e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+func fieldErrorf(field string, format string, args ...any) error {
+	return &FieldError{Field: field, Err: fmt.Errorf(format, args...)}
+}
+
+// parseDocument parses contents into a YAML node tree, which keeps the
+// line numbers that unmarshalling discards.
+func parseDocument(contents []byte) (*yaml.Node, error) {
+	document := &yaml.Node{}
+	if err := yaml.Unmarshal(contents, document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// checkFields returns an error for each field in document that
+// UpstreamYaml does not have, or whose value has the wrong type. This
+// catches misspelled fields, which unmarshalling would silently ignore.
+func checkFields(document *yaml.Node) error {
+	if len(document.Content) == 0 {
+		return nil
+	}
+	errs := checkNode(document.Content[0], reflect.TypeOf(UpstreamYaml{}), "")
+	return errors.Join(errs...)
+}
+
+func checkNode(node *yaml.Node, t reflect.Type, path string) []error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return []error{typeError(node, path, "a mapping")}
+		}
+		fields := getStructFields(t)
+		return checkMapping(node, path, func(key *yaml.Node, fieldPath string) (reflect.Type, error) {
+			fieldType, ok := fields[key.Value]
+			if !ok {
+				return nil, unknownFieldError(key, fieldPath, fields)
+			}
+			return fieldType, nil
+		})
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return []error{typeError(node, path, "a mapping")}
+		}
+		return checkMapping(node, path, func(*yaml.Node, string) (reflect.Type, error) {
+			return t.Elem(), nil
+		})
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return []error{typeError(node, path, "a list")}
+		}
+		errs := make([]error, 0)
+		for index, item := range node.Content {
+			errs = append(errs, checkNode(item, t.Elem(), fmt.Sprintf("%s[%d]", path, index))...)
+		}
+		return errs
+	case reflect.Bool:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!bool" {
+			return []error{typeError(node, path, "true or false")}
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!int" {
+			return []error{typeError(node, path, "an integer")}
+		}
+	case reflect.Float32, reflect.Float64:
+		if node.Kind != yaml.ScalarNode || (node.Tag != "!!int" && node.Tag != "!!float") {
+			return []error{typeError(node, path, "a number")}
+		}
+	case reflect.String:
+		// numbers and booleans are converted to strings when unmarshalling
+		if node.Kind != yaml.ScalarNode {
+			return []error{typeError(node, path, "a string")}
+		}
+	}
+	return nil
+}
+
+// checkMapping checks each value of mapping against the type that
+// getType returns for its key.
+func checkMapping(mapping *yaml.Node, path string, getType func(key *yaml.Node, fieldPath string) (reflect.Type, error)) []error {
+	errs := make([]error, 0)
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i], mapping.Content[i+1]
+		fieldPath := joinFieldPath(path, key.Value)
+		if seen[key.Value] {
+			errs = append(errs, &FieldError{Field: fieldPath, Line: key.Line, Err: fmt.Errorf("%s is set more than once", fieldPath)})
+			continue
+		}
+		seen[key.Value] = true
+		valueType, err := getType(key, fieldPath)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs, checkNode(value, valueType, fieldPath)...)
+	}
+	return errs
+}
+
+func typeError(node *yaml.Node, path, expected string) error {
+	return &FieldError{Field: path, Line: node.Line, Err: fmt.Errorf("%s must be %s", path, expected)}
+}
+
+func unknownFieldError(key *yaml.Node, fieldPath string, fields map[string]reflect.Type) error {
+	err := fmt.Errorf("unknown field %s", fieldPath)
+	if suggestion := suggestField(key.Value, fields); suggestion != "" {
+		err = fmt.Errorf("unknown field %s; did you mean %s?", fieldPath, suggestion)
+	}
+	return &FieldError{Field: fieldPath, Line: key.Line, Err: err}
+}
+
+// suggestField returns the name in fields that name is most likely a
+// misspelling of, or an empty string if there is none.
+func suggestField(name string, fields map[string]reflect.Type) string {
+	suggestion := ""
+	bestDistance := 3
+	for fieldName := range fields {
+		if strings.EqualFold(name, fieldName) {
+			return fieldName
+		}
+		distance := getEditDistance(strings.ToLower(name), strings.ToLower(fieldName))
+		if distance < bestDistance || (distance == bestDistance && fieldName < suggestion) {
+			suggestion = fieldName
+			bestDistance = distance
+		}
+	}
+	return suggestion
+}
+
+// getEditDistance returns the Levenshtein distance between a and b.
+func getEditDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			substitutionCost := 1
+			if a[i-1] == b[j-1] {
+				substitutionCost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+substitutionCost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// getStructFields returns the types of the fields of t, keyed by the
+// names they have in upstream.yaml.
+func getStructFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+func joinFieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// findLine returns the line that the field at fieldPath is on in
+// document, or 0 if document does not contain it. List items are not
+// supported.
+func findLine(document *yaml.Node, fieldPath string) int {
+	if document == nil || len(document.Content) == 0 {
+		return 0
+	}
+	node := document.Content[0]
+	line := 0
+	for _, key := range strings.Split(fieldPath, ".") {
+		if node.Kind != yaml.MappingNode {
+			return 0
+		}
+		found := false
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				line = node.Content[i].Line
+				node = node.Content[i+1]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0
+		}
+	}
+	return line
+}
+
+// quoteList returns values as a quoted, comma-separated list.
+func quoteList(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, strconv.Quote(value))
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -3,8 +3,14 @@ package upstreamyaml
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"regexp"
+	"slices"
+	"strings"
 
+	"github.com/Masterminds/semver/v3"
+	"github.com/rancher/partner-charts-ci/pkg/conform"
 	"github.com/sirupsen/logrus"
 
 	"helm.sh/helm/v3/pkg/chart"
@@ -16,70 +22,139 @@ const (
 	UpstreamOptionsFile = "upstream.yaml"
 )
 
+// FetchValues are the values that Fetch may have.
+var FetchValues = []string{"latest", "newer", "all"}
+
+// scpLikeGitURL matches git URLs in the scp-like [user@]host:path syntax,
+// such as git@github.com:example/example.git.
+var scpLikeGitURL = regexp.MustCompile(`^([\w.-]+@)?[\w.-]+:[^/]`)
+
 type UpstreamYaml struct {
 	ArtifactHubPackage string         `json:"ArtifactHubPackage,omitempty"`
 	ArtifactHubRepo    string         `json:"ArtifactHubRepo,omitempty"`
 	AutoInstall        string         `json:"AutoInstall,omitempty"`
 	ChartMetadata      chart.Metadata `json:"ChartMetadata"`
-	Deprecated         bool           `json:"Deprecated,omitempty"`
-	DisplayName        string         `json:"DisplayName,omitempty"`
-	Experimental       bool           `json:"Experimental,omitempty"`
-	Fetch              string         `json:"Fetch,omitempty"`
-	GitBranch          string         `json:"GitBranch,omitempty"`
-	GitHubRelease      bool           `json:"GitHubRelease,omitempty"`
-	GitRepo            string         `json:"GitRepo,omitempty"`
-	GitSubdirectory    string         `json:"GitSubdirectory,omitempty"`
-	HelmChart          string         `json:"HelmChart,omitempty"`
-	HelmRepo           string         `json:"HelmRepo,omitempty"`
-	Hidden             bool           `json:"Hidden,omitempty"`
-	Namespace          string         `json:"Namespace,omitempty"`
+	// ChartMetadataMerge sets how list fields of ChartMetadata are
+	// combined with those of the upstream chart. The keys are Chart.yaml
+	// field names, such as "maintainers".
+	ChartMetadataMerge map[string]conform.ListMergeStrategy `json:"ChartMetadataMerge,omitempty"`
+	// ChartMetadataUnset lists Chart.yaml fields, such as "home", that are
+	// removed from the upstream chart.
+	ChartMetadataUnset []string `json:"ChartMetadataUnset,omitempty"`
+	Deprecated         bool     `json:"Deprecated,omitempty"`
+	DisplayName        string   `json:"DisplayName,omitempty"`
+	Experimental       bool     `json:"Experimental,omitempty"`
+	Fetch              string   `json:"Fetch,omitempty"`
+	GitBranch          string   `json:"GitBranch,omitempty"`
+	GitHubRelease      bool     `json:"GitHubRelease,omitempty"`
+	GitRepo            string   `json:"GitRepo,omitempty"`
+	GitSubdirectory    string   `json:"GitSubdirectory,omitempty"`
+	HelmChart          string   `json:"HelmChart,omitempty"`
+	HelmRepo           string   `json:"HelmRepo,omitempty"`
+	Hidden             bool     `json:"Hidden,omitempty"`
+	Namespace          string   `json:"Namespace,omitempty"`
+	// OS is the operating system that the chart can be installed on. It
+	// sets the catalog.cattle.io/os annotation.
+	OS string `json:"OS,omitempty"`
 	// Deprecated: rancher/partner-charts updates are now automated, and this
 	// automation does not combine well with PackageVersion. Additionally,
 	// PackageVersion was never actually used. PackageVersion should not
 	// be added to any existing packages, nor should it be set in any new
 	// packages. For more information please see
 	// https://jira.suse.com/browse/SURE-9320.
-	PackageVersion int    `json:"PackageVersion,omitempty"`
+	PackageVersion int `json:"PackageVersion,omitempty"`
+	// PermitsOS lists the operating systems whose nodes the chart can run
+	// on. It sets the catalog.cattle.io/permits-os annotation.
+	PermitsOS []string `json:"PermitsOS,omitempty"`
+	// PreserveKubeVersion disables the normalization of kubeVersion
+	// lower bounds (i.e. >=1.21 becoming >=1.21-0) during integration.
+	PreserveKubeVersion bool `json:"PreserveKubeVersion,omitempty"`
+	// ProvidesGVR lists the resources that the chart provides, in
+	// <group>.<resource>/<version> format. It sets the
+	// catalog.cattle.io/provides-gvr annotation.
+	ProvidesGVR []string `json:"ProvidesGVR,omitempty"`
+	// RancherVersion is the range of Rancher versions that the chart
+	// supports. It sets the catalog.cattle.io/rancher-version annotation.
+	RancherVersion string `json:"RancherVersion,omitempty"`
 	ReleaseName    string `json:"ReleaseName,omitempty"`
-	Vendor         string `json:"Vendor,omitempty"`
+	// RequestsCPU and RequestsMemory are the resources that the chart
+	// needs to be installed. They set the catalog.cattle.io/requests-cpu
+	// and catalog.cattle.io/requests-memory annotations.
+	RequestsCPU    string `json:"RequestsCPU,omitempty"`
+	RequestsMemory string `json:"RequestsMemory,omitempty"`
+	// Retention sets which chart versions partner-charts-ci cull keeps.
+	Retention *Retention `json:"Retention,omitempty"`
+	// Scope and Type set the catalog.cattle.io/scope and
+	// catalog.cattle.io/type annotations.
+	Scope string `json:"Scope,omitempty"`
+	Type  string `json:"Type,omitempty"`
+	// UIComponent is the Rancher UI component that the chart uses. It sets
+	// the catalog.cattle.io/ui-component annotation.
+	UIComponent string `json:"UIComponent,omitempty"`
+	Vendor      string `json:"Vendor,omitempty"`
+}
+
+// Retention is a set of rules for which chart versions of a package are
+// kept when culling. A chart version is kept if any of the rules keeps it.
+type Retention struct {
+	// KeepNewest is the number of newest chart versions to keep.
+	KeepNewest int `json:"KeepNewest,omitempty"`
+	// KeepLatestPatches keeps the latest patch version of each minor version.
+	KeepLatestPatches bool `json:"KeepLatestPatches,omitempty"`
+	// Pinned is a list of chart versions that are always kept.
+	Pinned []string `json:"Pinned,omitempty"`
+}
+
+// OverlayOptions returns the options used to apply ChartMetadata to
+// upstream charts.
+func (upstreamYaml *UpstreamYaml) OverlayOptions() conform.OverlayOptions {
+	return conform.OverlayOptions{
+		Merge: upstreamYaml.ChartMetadataMerge,
+		Unset: upstreamYaml.ChartMetadataUnset,
+	}
 }
 
 func (upstreamYaml *UpstreamYaml) setDefaults() {
+	// Fetch was historically matched case-insensitively
+	upstreamYaml.Fetch = strings.ToLower(upstreamYaml.Fetch)
 	if upstreamYaml.Fetch == "" {
 		upstreamYaml.Fetch = "latest"
 	}
 }
 
 func (upstreamYaml *UpstreamYaml) validate() error {
+	if !slices.Contains(FetchValues, upstreamYaml.Fetch) {
+		return fieldErrorf("Fetch", "Fetch must be one of %s but is %q", quoteList(FetchValues), upstreamYaml.Fetch)
+	}
 	if upstreamYaml.Fetch != "latest" && upstreamYaml.HelmChart == "" {
-		return errors.New("fetch is latest but HelmChart is not set")
+		return fieldErrorf("Fetch", "Fetch is %s but HelmChart is not set", upstreamYaml.Fetch)
 	}
 	if upstreamYaml.Fetch != "latest" && upstreamYaml.HelmRepo == "" {
-		return errors.New("fetch is latest but HelmRepo is not set")
+		return fieldErrorf("Fetch", "Fetch is %s but HelmRepo is not set", upstreamYaml.Fetch)
 	}
 
 	if upstreamYaml.ArtifactHubPackage != "" && upstreamYaml.ArtifactHubRepo == "" {
-		return errors.New("ArtifactHubPackage is set but ArtifactHubRepo is not set")
+		return fieldErrorf("ArtifactHubPackage", "ArtifactHubPackage is set but ArtifactHubRepo is not set")
 	}
 	if upstreamYaml.ArtifactHubRepo != "" && upstreamYaml.ArtifactHubPackage == "" {
-		return errors.New("ArtifactHubRepo is set but ArtifactHubPackage is not set")
+		return fieldErrorf("ArtifactHubRepo", "ArtifactHubRepo is set but ArtifactHubPackage is not set")
 	}
 
 	if upstreamYaml.GitBranch != "" && upstreamYaml.GitRepo == "" {
-		return errors.New("GitBranch is set but GitRepo is not set")
+		return fieldErrorf("GitBranch", "GitBranch is set but GitRepo is not set")
 	}
 	if upstreamYaml.GitHubRelease && upstreamYaml.GitRepo == "" {
-		return errors.New("GitHubRelease is set but GitRepo is not set")
+		return fieldErrorf("GitHubRelease", "GitHubRelease is set but GitRepo is not set")
 	}
 	if upstreamYaml.GitSubdirectory != "" && upstreamYaml.GitRepo == "" {
-		return errors.New("GitSubdirectory is set but GitRepo is not set")
+		return fieldErrorf("GitSubdirectory", "GitSubdirectory is set but GitRepo is not set")
 	}
 
 	if upstreamYaml.HelmChart != "" && upstreamYaml.HelmRepo == "" {
-		return errors.New("HelmChart is set but HelmRepo is not set")
+		return fieldErrorf("HelmChart", "HelmChart is set but HelmRepo is not set")
 	}
 	if upstreamYaml.HelmRepo != "" && upstreamYaml.HelmChart == "" {
-		return errors.New("HelmRepo is set but HelmChart is not set")
+		return fieldErrorf("HelmRepo", "HelmRepo is set but HelmChart is not set")
 	}
 
 	if (upstreamYaml.ArtifactHubPackage == "" || upstreamYaml.ArtifactHubRepo == "") &&
@@ -88,9 +163,61 @@ func (upstreamYaml *UpstreamYaml) validate() error {
 		return errors.New("must define upstream")
 	}
 
+	if upstreamYaml.HelmRepo != "" {
+		if err := checkURL(upstreamYaml.HelmRepo, "http", "https", "oci"); err != nil {
+			return fieldErrorf("HelmRepo", "HelmRepo %q is not a valid URL: %w", upstreamYaml.HelmRepo, err)
+		}
+	}
+	if upstreamYaml.GitRepo != "" && !scpLikeGitURL.MatchString(upstreamYaml.GitRepo) {
+		if err := checkURL(upstreamYaml.GitRepo, "http", "https", "ssh", "git"); err != nil {
+			return fieldErrorf("GitRepo", "GitRepo %q is not a valid URL: %w", upstreamYaml.GitRepo, err)
+		}
+	}
+	if upstreamYaml.GitHubRelease && !strings.HasPrefix(upstreamYaml.GitRepo, "https://github.com/") {
+		return fieldErrorf("GitHubRelease", "GitHubRelease is set but GitRepo %q is not a https://github.com/ URL", upstreamYaml.GitRepo)
+	}
+
+	if retention := upstreamYaml.Retention; retention != nil {
+		if retention.KeepNewest < 0 {
+			return fieldErrorf("Retention.KeepNewest", "Retention.KeepNewest must not be negative")
+		}
+		for _, pinned := range retention.Pinned {
+			if _, err := semver.NewVersion(pinned); err != nil {
+				return fieldErrorf("Retention.Pinned", "Retention.Pinned contains invalid version %q: %w", pinned, err)
+			}
+		}
+	}
+
+	if err := upstreamYaml.validateCatalogAnnotations(); err != nil {
+		return err
+	}
+
+	if err := upstreamYaml.OverlayOptions().Validate(upstreamYaml.ChartMetadata); err != nil {
+		return fmt.Errorf("invalid ChartMetadataMerge or ChartMetadataUnset: %w", err)
+	}
+
 	return nil
 }
 
+// checkURL returns an error if rawURL is not an absolute URL with one of
+// schemes and a host.
+func checkURL(rawURL string, schemes ...string) error {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(schemes, parsedURL.Scheme) {
+		return fmt.Errorf("scheme must be one of %s", strings.Join(schemes, ", "))
+	}
+	if parsedURL.Host == "" {
+		return errors.New("host is missing")
+	}
+	return nil
+}
+
+// Parse reads the upstream.yaml at upstreamYamlPath. Fields that
+// UpstreamYaml does not have are errors, and errors about specific
+// fields give the line that the field is on.
 func Parse(upstreamYamlPath string) (*UpstreamYaml, error) {
 	logrus.Debugf("Attempting to parse %s", upstreamYamlPath)
 	contents, err := os.ReadFile(upstreamYamlPath)
@@ -98,20 +225,43 @@ func Parse(upstreamYamlPath string) (*UpstreamYaml, error) {
 		return nil, fmt.Errorf("failed to read: %w", err)
 	}
 
+	document, err := parseDocument(contents)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse as YAML: %w", err)
+	}
+	if err := checkFields(document); err != nil {
+		return nil, fmt.Errorf("invalid upstream.yaml: %w", err)
+	}
+
 	upstreamYaml := &UpstreamYaml{}
-	if err := yaml.Unmarshal(contents, &upstreamYaml); err != nil {
+	if err := yaml.UnmarshalStrict(contents, &upstreamYaml); err != nil {
 		return nil, fmt.Errorf("failed to parse as YAML: %w", err)
 	}
 
 	upstreamYaml.setDefaults()
 
 	if err := upstreamYaml.validate(); err != nil {
+		var fieldError *FieldError
+		if errors.As(err, &fieldError) && fieldError.Line == 0 {
+			fieldError.Line = findLine(document, fieldError.Field)
+		}
 		return nil, fmt.Errorf("invalid upstream.yaml: %w", err)
 	}
 
 	return upstreamYaml, nil
 }
 
+// New returns a copy of upstreamYaml that has the same defaults as
+// an upstream.yaml read by Parse. It returns an error if the copy is not
+// a valid upstream.yaml.
+func New(upstreamYaml UpstreamYaml) (*UpstreamYaml, error) {
+	upstreamYaml.setDefaults()
+	if err := upstreamYaml.validate(); err != nil {
+		return nil, fmt.Errorf("invalid upstream.yaml: %w", err)
+	}
+	return &upstreamYaml, nil
+}
+
 func Write(upstreamYamlPath string, upstreamYaml *UpstreamYaml) error {
 	logrus.Debugf("Attempting to write %s", upstreamYamlPath)
 	contents, err := yaml.Marshal(upstreamYaml)
@@ -1,9 +1,16 @@
 package upstreamyaml
 
 import (
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
 	"testing"
 
+	"github.com/rancher/partner-charts-ci/pkg/conform"
 	"github.com/stretchr/testify/assert"
+
+	"helm.sh/helm/v3/pkg/chart"
 )
 
 func TestMain(t *testing.T) {
@@ -15,6 +22,14 @@ func TestMain(t *testing.T) {
 				assert.Equal(t, "latest", upstreamYaml.Fetch)
 			})
 
+			t.Run("should lowercase Fetch field", func(t *testing.T) {
+				upstreamYaml := &UpstreamYaml{
+					Fetch: "Newer",
+				}
+				upstreamYaml.setDefaults()
+				assert.Equal(t, "newer", upstreamYaml.Fetch)
+			})
+
 			t.Run("should not change Fetch field if set", func(t *testing.T) {
 				fetchValue := "newer"
 				upstreamYaml := &UpstreamYaml{
@@ -44,22 +59,32 @@ func TestMain(t *testing.T) {
 				assert.ErrorContains(t, err, "ArtifactHubRepo is set but ArtifactHubPackage is not set")
 			})
 
+			t.Run("Fetch must be latest, newer or all", func(t *testing.T) {
+				upstreamYaml := UpstreamYaml{
+					Fetch:     "notlatest",
+					HelmRepo:  "https://example.com",
+					HelmChart: "test-chart",
+				}
+				err := upstreamYaml.validate()
+				assert.ErrorContains(t, err, `Fetch must be one of "latest", "newer", "all" but is "notlatest"`)
+			})
+
 			t.Run("if Fetch is not latest, HelmChart must be set", func(t *testing.T) {
 				upstreamYaml := UpstreamYaml{
-					Fetch:    "notlatest",
+					Fetch:    "newer",
 					HelmRepo: "https://example.com",
 				}
 				err := upstreamYaml.validate()
-				assert.ErrorContains(t, err, "fetch is latest but HelmChart is not set")
+				assert.ErrorContains(t, err, "Fetch is newer but HelmChart is not set")
 			})
 
 			t.Run("if Fetch is not latest, HelmRepo must be set", func(t *testing.T) {
 				upstreamYaml := UpstreamYaml{
-					Fetch:     "notlatest",
+					Fetch:     "all",
 					HelmChart: "test-chart",
 				}
 				err := upstreamYaml.validate()
-				assert.ErrorContains(t, err, "fetch is latest but HelmRepo is not set")
+				assert.ErrorContains(t, err, "Fetch is all but HelmRepo is not set")
 			})
 
 			t.Run("if GitBranch is set, GitRepo must be set", func(t *testing.T) {
@@ -114,6 +139,381 @@ func TestMain(t *testing.T) {
 				err := upstreamYaml.validate()
 				assert.ErrorContains(t, err, "must define upstream")
 			})
+
+			t.Run("URLs", func(t *testing.T) {
+				testCases := []struct {
+					Description   string
+					UpstreamYaml  UpstreamYaml
+					ExpectedError string
+				}{
+					{
+						Description:  "should accept https HelmRepo",
+						UpstreamYaml: UpstreamYaml{HelmRepo: "https://charts.example.com", HelmChart: "test-chart"},
+					},
+					{
+						Description:  "should accept oci HelmRepo",
+						UpstreamYaml: UpstreamYaml{HelmRepo: "oci://ghcr.io/example/charts", HelmChart: "test-chart"},
+					},
+					{
+						Description:   "should reject HelmRepo without scheme",
+						UpstreamYaml:  UpstreamYaml{HelmRepo: "charts.example.com", HelmChart: "test-chart"},
+						ExpectedError: `HelmRepo "charts.example.com" is not a valid URL: scheme must be one of http, https, oci`,
+					},
+					{
+						Description:   "should reject HelmRepo without host",
+						UpstreamYaml:  UpstreamYaml{HelmRepo: "https:///charts", HelmChart: "test-chart"},
+						ExpectedError: "host is missing",
+					},
+					{
+						Description:  "should accept scp-like GitRepo",
+						UpstreamYaml: UpstreamYaml{GitRepo: "git@github.com:example/example.git"},
+					},
+					{
+						Description:   "should reject GitRepo with unsupported scheme",
+						UpstreamYaml:  UpstreamYaml{GitRepo: "ftp://example.com/example.git"},
+						ExpectedError: `GitRepo "ftp://example.com/example.git" is not a valid URL`,
+					},
+					{
+						Description:   "should reject GitHubRelease with GitRepo that is not on GitHub",
+						UpstreamYaml:  UpstreamYaml{GitRepo: "https://gitlab.com/example/example.git", GitHubRelease: true},
+						ExpectedError: "GitHubRelease is set but GitRepo",
+					},
+				}
+				for _, testCase := range testCases {
+					t.Run(testCase.Description, func(t *testing.T) {
+						testCase.UpstreamYaml.Fetch = "latest"
+						err := testCase.UpstreamYaml.validate()
+						if testCase.ExpectedError == "" {
+							assert.Nil(t, err)
+						} else {
+							assert.ErrorContains(t, err, testCase.ExpectedError)
+						}
+					})
+				}
+			})
+
+			t.Run("ChartMetadataMerge must refer to list fields", func(t *testing.T) {
+				upstreamYaml := UpstreamYaml{
+					Fetch:   "latest",
+					GitRepo: "https://github.com/example/example.git",
+					ChartMetadataMerge: map[string]conform.ListMergeStrategy{
+						"home": conform.ListMergeReplace,
+					},
+				}
+				err := upstreamYaml.validate()
+				assert.ErrorContains(t, err, "invalid ChartMetadataMerge or ChartMetadataUnset")
+			})
+
+			t.Run("Retention.KeepNewest must not be negative", func(t *testing.T) {
+				upstreamYaml := UpstreamYaml{
+					Fetch:     "latest",
+					GitRepo:   "https://github.com/example/example.git",
+					Retention: &Retention{KeepNewest: -1},
+				}
+				err := upstreamYaml.validate()
+				assert.ErrorContains(t, err, "Retention.KeepNewest must not be negative")
+			})
+
+			t.Run("Retention.Pinned must contain valid versions", func(t *testing.T) {
+				upstreamYaml := UpstreamYaml{
+					Fetch:     "latest",
+					GitRepo:   "https://github.com/example/example.git",
+					Retention: &Retention{Pinned: []string{"1.2.3", "latest"}},
+				}
+				err := upstreamYaml.validate()
+				assert.ErrorContains(t, err, `Retention.Pinned contains invalid version "latest"`)
+			})
+
+			t.Run("catalog annotation fields", func(t *testing.T) {
+				testCases := []struct {
+					Description   string
+					UpstreamYaml  UpstreamYaml
+					ExpectedError string
+				}{
+					{
+						Description: "should accept valid values",
+						UpstreamYaml: UpstreamYaml{
+							OS:             "linux",
+							PermitsOS:      []string{"linux", "windows"},
+							ProvidesGVR:    []string{"monitoring.coreos.com.prometheuses/v1", "pods/v1"},
+							RancherVersion: ">= 2.9.0-0 < 2.12.0-0",
+							RequestsCPU:    "500m",
+							RequestsMemory: "256Mi",
+							Scope:          "management",
+							Type:           "cluster-tool",
+							UIComponent:    "monitoring",
+						},
+					},
+					{
+						Description:   "RancherVersion must be a version range",
+						UpstreamYaml:  UpstreamYaml{RancherVersion: ">= two"},
+						ExpectedError: `RancherVersion ">= two" is not a valid version range`,
+					},
+					{
+						Description:   "PermitsOS must contain linux or windows",
+						UpstreamYaml:  UpstreamYaml{PermitsOS: []string{"linux", "darwin"}},
+						ExpectedError: `PermitsOS must be one of "linux", "windows" but is "darwin"`,
+					},
+					{
+						Description:   "PermitsOS must not contain duplicates",
+						UpstreamYaml:  UpstreamYaml{PermitsOS: []string{"linux", "linux"}},
+						ExpectedError: `PermitsOS contains "linux" more than once`,
+					},
+					{
+						Description:   "OS must be linux or windows",
+						UpstreamYaml:  UpstreamYaml{OS: "Linux"},
+						ExpectedError: `OS must be one of "linux", "windows" but is "Linux"`,
+					},
+					{
+						Description:   "Scope must be management or downstream",
+						UpstreamYaml:  UpstreamYaml{Scope: "cluster"},
+						ExpectedError: `Scope must be one of "management", "downstream" but is "cluster"`,
+					},
+					{
+						Description:   "Type must be a known type",
+						UpstreamYaml:  UpstreamYaml{Type: "tool"},
+						ExpectedError: `Type must be one of "app", "cluster-tool", "cluster-template" but is "tool"`,
+					},
+					{
+						Description:   "ProvidesGVR must contain group version resources",
+						UpstreamYaml:  UpstreamYaml{ProvidesGVR: []string{"Prometheus"}},
+						ExpectedError: `ProvidesGVR contains "Prometheus", which is not in <group>.<resource>/<version> format`,
+					},
+					{
+						Description:   "RequestsCPU must be a quantity",
+						UpstreamYaml:  UpstreamYaml{RequestsCPU: "half a core"},
+						ExpectedError: `RequestsCPU "half a core" is not a valid quantity`,
+					},
+					{
+						Description:   "RequestsMemory must be positive",
+						UpstreamYaml:  UpstreamYaml{RequestsMemory: "0"},
+						ExpectedError: `RequestsMemory must be positive but is "0"`,
+					},
+					{
+						Description:   "UIComponent must be a name",
+						UpstreamYaml:  UpstreamYaml{UIComponent: "My Component"},
+						ExpectedError: `UIComponent "My Component" must consist of lowercase letters, digits and dashes`,
+					},
+					{
+						Description: "must not be set in ChartMetadata.Annotations as well",
+						UpstreamYaml: UpstreamYaml{
+							RancherVersion: ">= 2.9.0-0",
+							ChartMetadata: chart.Metadata{
+								Annotations: map[string]string{AnnotationRancherVersion: ">= 2.8.0-0"},
+							},
+						},
+						ExpectedError: "RancherVersion is set but ChartMetadata.Annotations also sets catalog.cattle.io/rancher-version",
+					},
+				}
+				for _, testCase := range testCases {
+					t.Run(testCase.Description, func(t *testing.T) {
+						upstreamYaml := testCase.UpstreamYaml
+						upstreamYaml.Fetch = "latest"
+						upstreamYaml.GitRepo = "https://github.com/example/example.git"
+						err := upstreamYaml.validate()
+						if testCase.ExpectedError == "" {
+							assert.NoError(t, err)
+						} else {
+							assert.ErrorContains(t, err, testCase.ExpectedError)
+						}
+					})
+				}
+			})
+		})
+
+		t.Run("CatalogAnnotations", func(t *testing.T) {
+			t.Run("should return annotations for set fields", func(t *testing.T) {
+				upstreamYaml := UpstreamYaml{
+					PermitsOS:      []string{"linux", "windows"},
+					RancherVersion: ">= 2.9.0-0",
+					Scope:          "management",
+				}
+				assert.Equal(t, map[string]string{
+					AnnotationPermitsOS:      "linux,windows",
+					AnnotationRancherVersion: ">= 2.9.0-0",
+					AnnotationScope:          "management",
+				}, upstreamYaml.CatalogAnnotations())
+			})
+
+			t.Run("should return no annotations if no fields are set", func(t *testing.T) {
+				upstreamYaml := UpstreamYaml{}
+				assert.Empty(t, upstreamYaml.CatalogAnnotations())
+			})
+		})
+
+		t.Run("ValidateCatalogAnnotation", func(t *testing.T) {
+			testCases := []struct {
+				Description   string
+				Annotation    string
+				Value         string
+				ExpectedError string
+			}{
+				{
+					Description: "should accept valid list",
+					Annotation:  AnnotationPermitsOS,
+					Value:       "linux,windows",
+				},
+				{
+					Description:   "should reject invalid list item",
+					Annotation:    AnnotationPermitsOS,
+					Value:         "linux,darwin",
+					ExpectedError: `catalog.cattle.io/permits-os must be one of "linux", "windows" but is "darwin"`,
+				},
+				{
+					Description:   "should reject duplicate list item",
+					Annotation:    AnnotationProvidesGVR,
+					Value:         "pods/v1,pods/v1",
+					ExpectedError: `catalog.cattle.io/provides-gvr contains "pods/v1" more than once`,
+				},
+				{
+					Description:   "should reject invalid value",
+					Annotation:    AnnotationUIComponent,
+					Value:         "My Component",
+					ExpectedError: `catalog.cattle.io/ui-component "My Component" must consist of lowercase letters, digits and dashes`,
+				},
+				{
+					Description:   "should reject empty value",
+					Annotation:    AnnotationRequestsCPU,
+					ExpectedError: "catalog.cattle.io/requests-cpu must not be empty",
+				},
+				{
+					Description: "should not check annotation that no field sets",
+					Annotation:  "catalog.cattle.io/hidden",
+					Value:       "maybe",
+				},
+			}
+			for _, testCase := range testCases {
+				t.Run(testCase.Description, func(t *testing.T) {
+					err := ValidateCatalogAnnotation(testCase.Annotation, testCase.Value)
+					if testCase.ExpectedError == "" {
+						assert.Nil(t, err)
+					} else {
+						assert.ErrorContains(t, err, testCase.ExpectedError)
+					}
+				})
+			}
+		})
+	})
+
+	t.Run("New", func(t *testing.T) {
+		t.Run("should set defaults", func(t *testing.T) {
+			upstreamYaml, err := New(UpstreamYaml{
+				HelmRepo:  "https://charts.example.com",
+				HelmChart: "testchart",
+			})
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, "latest", upstreamYaml.Fetch)
+		})
+
+		t.Run("should return error for invalid upstream.yaml", func(t *testing.T) {
+			_, err := New(UpstreamYaml{HelmChart: "testchart"})
+			assert.ErrorContains(t, err, "HelmChart is set but HelmRepo is not set")
 		})
 	})
 }
+
+func writeUpstreamYaml(t *testing.T, contents string) string {
+	t.Helper()
+	upstreamYamlPath := filepath.Join(t.TempDir(), UpstreamOptionsFile)
+	if err := os.WriteFile(upstreamYamlPath, []byte(contents), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %s", upstreamYamlPath, err)
+	}
+	return upstreamYamlPath
+}
+
+func TestParse(t *testing.T) {
+	t.Run("should parse valid upstream.yaml", func(t *testing.T) {
+		upstreamYamlPath := writeUpstreamYaml(t, "HelmRepo: https://charts.example.com\nHelmChart: test-chart\nChartMetadata:\n  appVersion: 2\n  maintainers:\n  - name: test\n")
+		upstreamYaml, err := Parse(upstreamYamlPath)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, "latest", upstreamYaml.Fetch)
+		assert.Equal(t, "2", upstreamYaml.ChartMetadata.AppVersion)
+	})
+
+	testCases := []struct {
+		Description   string
+		Contents      string
+		ExpectedError string
+	}{
+		{
+			Description:   "should report misspelled field with line and suggestion",
+			Contents:      "HelmRepo: https://charts.example.com\nHelmCart: test-chart\n",
+			ExpectedError: "line 2: unknown field HelmCart; did you mean HelmChart?",
+		},
+		{
+			Description:   "should suggest field that differs in case",
+			Contents:      "GitRepo: https://github.com/example/example\nGitbranch: main\n",
+			ExpectedError: "line 2: unknown field Gitbranch; did you mean GitBranch?",
+		},
+		{
+			Description:   "should report unknown field in nested struct",
+			Contents:      "GitRepo: https://github.com/example/example\nChartMetadata:\n  maintainers:\n  - nmae: test\n",
+			ExpectedError: "line 4: unknown field ChartMetadata.maintainers[0].nmae; did you mean name?",
+		},
+		{
+			Description:   "should report field with wrong type",
+			Contents:      "GitRepo: https://github.com/example/example\nHidden: sometimes\n",
+			ExpectedError: "line 2: Hidden must be true or false",
+		},
+		{
+			Description:   "should report field that is set more than once",
+			Contents:      "GitRepo: https://github.com/example/example\nGitRepo: https://github.com/example/other\n",
+			ExpectedError: "line 2: GitRepo is set more than once",
+		},
+		{
+			Description:   "should report line of field that fails validation",
+			Contents:      "HelmRepo: https://charts.example.com\nHelmChart: test-chart\nFetch: sometimes\n",
+			ExpectedError: `line 3: Fetch must be one of "latest", "newer", "all" but is "sometimes"`,
+		},
+		{
+			Description:   "should report line of nested field that fails validation",
+			Contents:      "GitRepo: https://github.com/example/example\nRetention:\n  KeepNewest: -1\n",
+			ExpectedError: "line 3: Retention.KeepNewest must not be negative",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.Description, func(t *testing.T) {
+			_, err := Parse(writeUpstreamYaml(t, testCase.Contents))
+			assert.ErrorContains(t, err, testCase.ExpectedError)
+		})
+	}
+
+	t.Run("should report every unknown field", func(t *testing.T) {
+		_, err := Parse(writeUpstreamYaml(t, "HelmRepo: https://charts.example.com\nHelmCart: test-chart\nFecth: all\n"))
+		assert.ErrorContains(t, err, "line 2: unknown field HelmCart")
+		assert.ErrorContains(t, err, "line 3: unknown field Fecth; did you mean Fetch?")
+	})
+}
+
+var updateSchema = flag.Bool("update-schema", false, "write the generated schema to "+schemaFile)
+
+func TestSchema(t *testing.T) {
+	schema, err := Schema()
+	if !assert.Nil(t, err) {
+		return
+	}
+	if *updateSchema {
+		if err := os.WriteFile(schemaFile, schema, 0o644); err != nil {
+			t.Fatalf("failed to write %s: %s", schemaFile, err)
+		}
+	}
+	committedSchema, err := os.ReadFile(schemaFile)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, string(committedSchema), string(schema), "%s is out of date; run go test ./pkg/upstreamyaml -update-schema", schemaFile)
+}
+
+func TestGetCaseInsensitivePattern(t *testing.T) {
+	pattern := regexp.MustCompile(getCaseInsensitivePattern(FetchValues))
+	for _, value := range []string{"latest", "Newer", "ALL"} {
+		assert.True(t, pattern.MatchString(value), value)
+	}
+	for _, value := range []string{"oldest", "latestish", ""} {
+		assert.False(t, pattern.MatchString(value), value)
+	}
+}
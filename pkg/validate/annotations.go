@@ -0,0 +1,158 @@
+package validate
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+
+	"github.com/Masterminds/semver/v3"
+	p "github.com/rancher/partner-charts-ci/pkg/paths"
+	"github.com/rancher/partner-charts-ci/pkg/pkg"
+
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+const (
+	annotationDisplayName = "catalog.cattle.io/display-name"
+	annotationHidden      = "catalog.cattle.io/hidden"
+	annotationNamespace   = "catalog.cattle.io/namespace"
+	annotationReleaseName = "catalog.cattle.io/release-name"
+
+	// DeprecatedField is the Key of an AnnotationMismatch that refers
+	// to the deprecated field of Chart.yaml rather than an annotation.
+	DeprecatedField = "deprecated"
+)
+
+// An AnnotationMismatch is a released chart version whose Chart.yaml does
+// not reflect the upstream.yaml of its package.
+type AnnotationMismatch struct {
+	Vendor  string
+	Chart   string
+	Version string
+	// Key is the annotation that does not match, or DeprecatedField.
+	Key string
+	// Expected is the value that upstream.yaml calls for. Actual is the
+	// value in the chart version, and is empty if the annotation is not
+	// present.
+	Expected string
+	Actual   string
+	// LatestOnly is true if upstream.yaml only applies to the latest
+	// chart version of the package.
+	LatestOnly bool
+}
+
+func (m AnnotationMismatch) Error() string {
+	if m.Key == DeprecatedField {
+		return fmt.Sprintf("%s/%s version %s is not deprecated but upstream.yaml has Deprecated: true", m.Vendor, m.Chart, m.Version)
+	}
+	if m.Actual == "" {
+		return fmt.Sprintf("%s/%s version %s does not have annotation %s but upstream.yaml requires %q", m.Vendor, m.Chart, m.Version, m.Key, m.Expected)
+	}
+	return fmt.Sprintf("%s/%s version %s has annotation %s=%q but upstream.yaml requires %q", m.Vendor, m.Chart, m.Version, m.Key, m.Actual, m.Expected)
+}
+
+// validateAnnotationState checks that the released chart versions of each
+// package reflect its upstream.yaml. See GetAnnotationMismatches.
+func validateAnnotationState(paths p.Paths, configYaml ConfigurationYaml) []error {
+	mismatches, err := GetAnnotationMismatches(paths, configYaml.Package)
+	if err != nil {
+		return []error{err}
+	}
+	errs := make([]error, 0, len(mismatches))
+	for _, mismatch := range mismatches {
+		packageName := mismatch.Vendor + "/" + mismatch.Chart
+		chartYamlPath := filepath.Join(paths.Charts, mismatch.Vendor, mismatch.Chart, mismatch.Version, "Chart.yaml")
+		errs = append(errs, withLocation(mismatch, packageName, chartYamlPath))
+	}
+	return errs
+}
+
+// GetAnnotationMismatches returns the ways in which the chart versions in
+// index.yaml do not reflect the upstream.yaml of their package. If
+// Hidden or Deprecated is set, every chart version must be hidden or
+// deprecated. If DisplayName, ReleaseName, Namespace or a field that sets
+// a catalog annotation, such as RancherVersion, is set, the latest chart
+// version must have the corresponding annotation. If
+// currentPackage is not empty, only that package is checked.
+func GetAnnotationMismatches(paths p.Paths, currentPackage string) ([]AnnotationMismatch, error) {
+	indexYaml, err := repo.LoadIndexFile(paths.IndexYaml)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load index.yaml: %w", err)
+	}
+	packageWrappers, err := pkg.ListPackageWrappers(paths, currentPackage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list package wrappers: %w", err)
+	}
+	return findAnnotationMismatches(indexYaml, packageWrappers), nil
+}
+
+func findAnnotationMismatches(indexYaml *repo.IndexFile, packageWrappers []pkg.PackageWrapper) []AnnotationMismatch {
+	mismatches := make([]AnnotationMismatch, 0)
+	for _, packageWrapper := range packageWrappers {
+		upstreamYaml := packageWrapper.UpstreamYaml
+		chartVersions := slices.Clone(indexYaml.Entries[packageWrapper.Name])
+		if upstreamYaml == nil || len(chartVersions) == 0 {
+			continue
+		}
+		slices.SortStableFunc(chartVersions, func(a, b *repo.ChartVersion) int {
+			aVersion, aErr := semver.NewVersion(a.Version)
+			bVersion, bErr := semver.NewVersion(b.Version)
+			if aErr != nil || bErr != nil {
+				return 0
+			}
+			return bVersion.Compare(aVersion)
+		})
+
+		newMismatch := func(chartVersion *repo.ChartVersion, key, expected string, latestOnly bool) AnnotationMismatch {
+			return AnnotationMismatch{
+				Vendor:     packageWrapper.Vendor,
+				Chart:      packageWrapper.Name,
+				Version:    chartVersion.Version,
+				Key:        key,
+				Expected:   expected,
+				Actual:     chartVersion.Annotations[key],
+				LatestOnly: latestOnly,
+			}
+		}
+
+		for _, chartVersion := range chartVersions {
+			if upstreamYaml.Hidden && chartVersion.Annotations[annotationHidden] != "true" {
+				mismatches = append(mismatches, newMismatch(chartVersion, annotationHidden, "true", false))
+			}
+			if upstreamYaml.Deprecated && !chartVersion.Deprecated {
+				mismatches = append(mismatches, AnnotationMismatch{
+					Vendor:   packageWrapper.Vendor,
+					Chart:    packageWrapper.Name,
+					Version:  chartVersion.Version,
+					Key:      DeprecatedField,
+					Expected: "true",
+					Actual:   "false",
+				})
+			}
+		}
+
+		latestChartVersion := chartVersions[0]
+		latestAnnotations := []struct {
+			Key   string
+			Value string
+		}{
+			{Key: annotationDisplayName, Value: upstreamYaml.DisplayName},
+			{Key: annotationReleaseName, Value: upstreamYaml.ReleaseName},
+			{Key: annotationNamespace, Value: upstreamYaml.Namespace},
+		}
+		catalogAnnotations := upstreamYaml.CatalogAnnotations()
+		for _, key := range slices.Sorted(maps.Keys(catalogAnnotations)) {
+			latestAnnotations = append(latestAnnotations, struct {
+				Key   string
+				Value string
+			}{Key: key, Value: catalogAnnotations[key]})
+		}
+		for _, annotation := range latestAnnotations {
+			if annotation.Value != "" && latestChartVersion.Annotations[annotation.Key] != annotation.Value {
+				mismatches = append(mismatches, newMismatch(latestChartVersion, annotation.Key, annotation.Value, true))
+			}
+		}
+	}
+	return mismatches
+}
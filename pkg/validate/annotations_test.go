@@ -0,0 +1,135 @@
+package validate
+
+import (
+	"testing"
+
+	"github.com/rancher/partner-charts-ci/pkg/pkg"
+	"github.com/rancher/partner-charts-ci/pkg/upstreamyaml"
+	"github.com/stretchr/testify/assert"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+func TestFindAnnotationMismatches(t *testing.T) {
+	newIndexYaml := func(chartVersions ...*repo.ChartVersion) *repo.IndexFile {
+		return &repo.IndexFile{
+			Entries: map[string]repo.ChartVersions{
+				"testchart": chartVersions,
+			},
+		}
+	}
+	newChartVersion := func(version string, deprecated bool, annotations map[string]string) *repo.ChartVersion {
+		return &repo.ChartVersion{
+			Metadata: &chart.Metadata{
+				Name:        "testchart",
+				Version:     version,
+				Deprecated:  deprecated,
+				Annotations: annotations,
+			},
+		}
+	}
+	newPackageWrappers := func(upstreamYaml upstreamyaml.UpstreamYaml) []pkg.PackageWrapper {
+		return []pkg.PackageWrapper{
+			{Name: "testchart", Vendor: "vendor", UpstreamYaml: &upstreamYaml},
+		}
+	}
+
+	t.Run("should return no mismatches when chart versions reflect upstream.yaml", func(t *testing.T) {
+		indexYaml := newIndexYaml(
+			newChartVersion("1.0.0", true, map[string]string{annotationHidden: "true"}),
+			newChartVersion("2.0.0", true, map[string]string{
+				annotationHidden:      "true",
+				annotationDisplayName: "Test Chart",
+				annotationReleaseName: "test-chart",
+				annotationNamespace:   "test-namespace",
+			}),
+		)
+		packageWrappers := newPackageWrappers(upstreamyaml.UpstreamYaml{
+			Hidden:      true,
+			Deprecated:  true,
+			DisplayName: "Test Chart",
+			ReleaseName: "test-chart",
+			Namespace:   "test-namespace",
+		})
+		assert.Len(t, findAnnotationMismatches(indexYaml, packageWrappers), 0)
+	})
+
+	t.Run("should return mismatches for catalog annotations of the latest chart version", func(t *testing.T) {
+		indexYaml := newIndexYaml(
+			newChartVersion("1.0.0", false, nil),
+			newChartVersion("2.0.0", false, map[string]string{
+				upstreamyaml.AnnotationRancherVersion: ">= 2.8.0-0",
+			}),
+		)
+		packageWrappers := newPackageWrappers(upstreamyaml.UpstreamYaml{
+			PermitsOS:      []string{"linux"},
+			RancherVersion: ">= 2.9.0-0",
+		})
+		mismatches := findAnnotationMismatches(indexYaml, packageWrappers)
+		if assert.Len(t, mismatches, 2) {
+			assert.Equal(t, upstreamyaml.AnnotationPermitsOS, mismatches[0].Key)
+			assert.Equal(t, "linux", mismatches[0].Expected)
+			assert.Equal(t, upstreamyaml.AnnotationRancherVersion, mismatches[1].Key)
+			assert.Equal(t, "2.0.0", mismatches[1].Version)
+			assert.Equal(t, ">= 2.8.0-0", mismatches[1].Actual)
+			assert.True(t, mismatches[1].LatestOnly)
+		}
+	})
+
+	t.Run("should return mismatch for each chart version that is not hidden", func(t *testing.T) {
+		indexYaml := newIndexYaml(
+			newChartVersion("1.0.0", false, nil),
+			newChartVersion("2.0.0", false, map[string]string{annotationHidden: "true"}),
+		)
+		packageWrappers := newPackageWrappers(upstreamyaml.UpstreamYaml{Hidden: true})
+		mismatches := findAnnotationMismatches(indexYaml, packageWrappers)
+		if assert.Len(t, mismatches, 1) {
+			assert.Equal(t, "1.0.0", mismatches[0].Version)
+			assert.Equal(t, annotationHidden, mismatches[0].Key)
+			assert.False(t, mismatches[0].LatestOnly)
+			assert.ErrorContains(t, mismatches[0], "vendor/testchart version 1.0.0 does not have annotation catalog.cattle.io/hidden")
+		}
+	})
+
+	t.Run("should return mismatch for each chart version that is not deprecated", func(t *testing.T) {
+		indexYaml := newIndexYaml(
+			newChartVersion("1.0.0", false, nil),
+			newChartVersion("2.0.0", false, nil),
+		)
+		packageWrappers := newPackageWrappers(upstreamyaml.UpstreamYaml{Deprecated: true})
+		mismatches := findAnnotationMismatches(indexYaml, packageWrappers)
+		if assert.Len(t, mismatches, 2) {
+			assert.Equal(t, DeprecatedField, mismatches[0].Key)
+			assert.ErrorContains(t, mismatches[0], "is not deprecated but upstream.yaml has Deprecated: true")
+		}
+	})
+
+	t.Run("should only check display name, release name and namespace on the latest version", func(t *testing.T) {
+		indexYaml := newIndexYaml(
+			newChartVersion("1.10.0", false, map[string]string{annotationDisplayName: "Old Name"}),
+			newChartVersion("1.9.0", false, map[string]string{annotationDisplayName: "Older Name"}),
+		)
+		packageWrappers := newPackageWrappers(upstreamyaml.UpstreamYaml{
+			DisplayName: "Test Chart",
+			ReleaseName: "test-chart",
+		})
+		mismatches := findAnnotationMismatches(indexYaml, packageWrappers)
+		if assert.Len(t, mismatches, 2) {
+			for _, mismatch := range mismatches {
+				assert.Equal(t, "1.10.0", mismatch.Version)
+				assert.True(t, mismatch.LatestOnly)
+			}
+			assert.ErrorContains(t, mismatches[0], `has annotation catalog.cattle.io/display-name="Old Name" but upstream.yaml requires "Test Chart"`)
+			assert.Equal(t, annotationReleaseName, mismatches[1].Key)
+		}
+	})
+
+	t.Run("should not check annotations that are not set in upstream.yaml", func(t *testing.T) {
+		indexYaml := newIndexYaml(
+			newChartVersion("1.0.0", false, map[string]string{annotationReleaseName: "upstream-release-name"}),
+		)
+		packageWrappers := newPackageWrappers(upstreamyaml.UpstreamYaml{})
+		assert.Len(t, findAnnotationMismatches(indexYaml, packageWrappers), 0)
+	})
+}
@@ -0,0 +1,302 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	p "github.com/rancher/partner-charts-ci/pkg/paths"
+	"github.com/sirupsen/logrus"
+)
+
+// A releasedBase is a version of the repository that contains released
+// chart versions, such as the main branch. Validations compare the
+// assets directory against it to find out which chart versions have
+// been released.
+type releasedBase interface {
+	// String describes the base in messages.
+	String() string
+	// compareAssets compares assetsPath to the assets directory of the
+	// base. The icons directory is not compared.
+	compareAssets(assetsPath string) (DirectoryComparison, error)
+	// hasAsset returns whether the assets directory of the base contains
+	// the file at relativePath.
+	hasAsset(relativePath string) (bool, error)
+	// remove cleans up anything that was created for the base.
+	remove() error
+}
+
+// releasedBases are the bases that have been set up by getReleasedBases.
+// Several validations compare against them, so they are set up at most
+// once per run.
+var releasedBases []releasedBase
+
+// getReleasedBases returns the bases to compare against. If
+// configYaml.LocalBase is set, it refers to a revision in the local git
+// repository, which is read without using the network. Otherwise, or if
+// the revision cannot be read, each validation upstream in configYaml is
+// cloned.
+func getReleasedBases(paths p.Paths, configYaml ConfigurationYaml) ([]releasedBase, error) {
+	if releasedBases != nil {
+		return releasedBases, nil
+	}
+
+	if configYaml.LocalBase != "" {
+		base, err := newGitTreeBase(paths, configYaml.LocalBase)
+		if err == nil {
+			releasedBases = []releasedBase{base}
+			return releasedBases, nil
+		}
+		if len(configYaml.ValidateUpstreams) == 0 {
+			return nil, err
+		}
+		logrus.Warnf("%s; cloning validation upstreams instead", err)
+	}
+
+	if len(configYaml.ValidateUpstreams) == 0 {
+		return nil, errors.New("no validation upstream configured")
+	}
+	bases := make([]releasedBase, 0, len(configYaml.ValidateUpstreams))
+	for _, upstream := range configYaml.ValidateUpstreams {
+		base, err := newCloneBase(upstream)
+		if err != nil {
+			for _, base := range bases {
+				err = errors.Join(err, base.remove())
+			}
+			return nil, err
+		}
+		bases = append(bases, base)
+	}
+	releasedBases = bases
+	return releasedBases, nil
+}
+
+// removeReleasedBases removes the bases set up by getReleasedBases, if
+// there are any.
+func removeReleasedBases() error {
+	errs := make([]error, 0, len(releasedBases))
+	for _, base := range releasedBases {
+		errs = append(errs, base.remove())
+	}
+	releasedBases = nil
+	return errors.Join(errs...)
+}
+
+// cloneBase is a releasedBase that is a clone of a validation upstream.
+type cloneBase struct {
+	upstream validateUpstream
+	cloneDir string
+}
+
+func newCloneBase(upstream validateUpstream) (*cloneBase, error) {
+	cloneDir, err := os.MkdirTemp("", "gitRepo")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary clone directory: %w", err)
+	}
+	if err := cloneRepo(upstream.URL, upstream.Branch, cloneDir); err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to clone %s: %w", upstream.URL, err), os.RemoveAll(cloneDir))
+	}
+	return &cloneBase{upstream: upstream, cloneDir: cloneDir}, nil
+}
+
+func (base *cloneBase) String() string {
+	return fmt.Sprintf("%s branch %s", base.upstream.URL, base.upstream.Branch)
+}
+
+func (base *cloneBase) compareAssets(assetsPath string) (DirectoryComparison, error) {
+	updatePath, err := filepath.Abs(assetsPath)
+	if err != nil {
+		return DirectoryComparison{}, fmt.Errorf("failed to get absolute path to assets dir: %w", err)
+	}
+	return compareDirectories(filepath.Join(base.cloneDir, "assets"), updatePath, []string{"icons"})
+}
+
+func (base *cloneBase) hasAsset(relativePath string) (bool, error) {
+	_, err := os.Stat(filepath.Join(base.cloneDir, "assets", relativePath))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to stat upstream copy of %s: %w", relativePath, err)
+	}
+	return true, nil
+}
+
+func (base *cloneBase) remove() error {
+	if err := os.RemoveAll(base.cloneDir); err != nil {
+		return fmt.Errorf("failed to remove temporary clone directory: %w", err)
+	}
+	return nil
+}
+
+// gitTreeBase is a releasedBase that is a revision in the local git
+// repository. Files are read straight from the git object store, so no
+// network access is needed.
+type gitTreeBase struct {
+	revision string
+	// assetsTree is the tree of the assets directory at revision. It is
+	// nil if there is no assets directory at revision.
+	assetsTree *object.Tree
+}
+
+func newGitTreeBase(paths p.Paths, revision string) (*gitTreeBase, error) {
+	repo, err := git.PlainOpen(paths.RepoRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open git repository at %s: %w", paths.RepoRoot, err)
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve revision %s: %w", revision, err)
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit %s: %w", hash, err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree of commit %s: %w", hash, err)
+	}
+
+	assetsPath := paths.Assets
+	if filepath.IsAbs(assetsPath) {
+		if assetsPath, err = filepath.Rel(paths.RepoRoot, assetsPath); err != nil {
+			return nil, fmt.Errorf("failed to get path of assets dir relative to repository root: %w", err)
+		}
+	}
+	assetsTree, err := tree.Tree(filepath.ToSlash(assetsPath))
+	if errors.Is(err, object.ErrDirectoryNotFound) {
+		assetsTree = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get tree of %s at %s: %w", assetsPath, revision, err)
+	}
+
+	return &gitTreeBase{revision: revision, assetsTree: assetsTree}, nil
+}
+
+func (base *gitTreeBase) String() string {
+	return "revision " + base.revision
+}
+
+// compareAssets reads each file in the assets directory of the base from
+// the object store only if its hash differs from the hash of the local
+// copy, which makes comparing mostly unchanged assets directories fast.
+func (base *gitTreeBase) compareAssets(assetsPath string) (DirectoryComparison, error) {
+	directoryComparison := DirectoryComparison{}
+	checkedSet := map[string]bool{}
+	skipDirs := []string{"icons"}
+
+	if base.assetsTree != nil {
+		err := base.assetsTree.Files().ForEach(func(file *object.File) error {
+			if slices.Contains(skipDirs, strings.Split(file.Name, "/")[0]) {
+				return nil
+			}
+			checkedSet[file.Name] = true
+
+			updateFilePath := filepath.Join(assetsPath, filepath.FromSlash(file.Name))
+			contents, err := os.ReadFile(updateFilePath)
+			if errors.Is(err, os.ErrNotExist) {
+				directoryComparison.Removed = append(directoryComparison.Removed, updateFilePath)
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("failed to read %s: %w", updateFilePath, err)
+			}
+			if plumbing.ComputeHash(plumbing.BlobObject, contents) == file.Hash {
+				directoryComparison.Unchanged = append(directoryComparison.Unchanged, updateFilePath)
+				return nil
+			}
+			if path.Ext(file.Name) != ".tgz" {
+				directoryComparison.Modified = append(directoryComparison.Modified, updateFilePath)
+				return nil
+			}
+
+			chartDiff, err := diffGitFileToHelmChart(file, updateFilePath)
+			if err != nil {
+				logrus.Debug(err)
+				directoryComparison.Modified = append(directoryComparison.Modified, updateFilePath)
+			} else if chartDiff.Match() {
+				directoryComparison.Unchanged = append(directoryComparison.Unchanged, updateFilePath)
+			} else {
+				directoryComparison.addChartDiff(updateFilePath, chartDiff)
+			}
+			return nil
+		})
+		if err != nil {
+			return DirectoryComparison{}, fmt.Errorf("failed to compare %s to %s: %w", assetsPath, base, err)
+		}
+	}
+
+	err := filepath.WalkDir(assetsPath, func(updateFilePath string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(assetsPath, updateFilePath)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path of %s: %w", updateFilePath, err)
+		}
+		if dirEntry.IsDir() {
+			if slices.Contains(skipDirs, relativePath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !checkedSet[filepath.ToSlash(relativePath)] {
+			directoryComparison.Added = append(directoryComparison.Added, updateFilePath)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return DirectoryComparison{}, fmt.Errorf("failed to search %q for added files: %w", assetsPath, err)
+	}
+
+	return directoryComparison, nil
+}
+
+// diffGitFileToHelmChart writes file to a temporary file so that it can
+// be compared to the chart archive at updateFilePath with DiffHelmCharts.
+func diffGitFileToHelmChart(file *object.File, updateFilePath string) (chartDiff ChartDiff, err error) {
+	tempFile, err := os.CreateTemp("", "partner-charts-ci-base-*.tgz")
+	if err != nil {
+		return ChartDiff{}, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer func() {
+		if removeErr := os.Remove(tempFile.Name()); removeErr != nil {
+			err = errors.Join(err, removeErr)
+		}
+	}()
+
+	reader, err := file.Reader()
+	if err != nil {
+		return ChartDiff{}, errors.Join(fmt.Errorf("failed to read %s: %w", file.Name, err), tempFile.Close())
+	}
+	_, err = io.Copy(tempFile, reader)
+	if err := errors.Join(err, reader.Close(), tempFile.Close()); err != nil {
+		return ChartDiff{}, fmt.Errorf("failed to write %s to %s: %w", file.Name, tempFile.Name(), err)
+	}
+
+	return DiffHelmCharts(tempFile.Name(), updateFilePath)
+}
+
+func (base *gitTreeBase) hasAsset(relativePath string) (bool, error) {
+	if base.assetsTree == nil {
+		return false, nil
+	}
+	_, err := base.assetsTree.File(filepath.ToSlash(relativePath))
+	if errors.Is(err, object.ErrFileNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to look up %s at %s: %w", relativePath, base, err)
+	}
+	return true, nil
+}
+
+func (base *gitTreeBase) remove() error {
+	return nil
+}
@@ -0,0 +1,153 @@
+package validate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	p "github.com/rancher/partner-charts-ci/pkg/paths"
+	"github.com/stretchr/testify/assert"
+)
+
+func copyTestdataFile(t *testing.T, name, targetPath string) {
+	t.Helper()
+	contents, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read %s: %s", name, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0o755); err != nil {
+		t.Fatalf("failed to create parent of %s: %s", targetPath, err)
+	}
+	if err := os.WriteFile(targetPath, contents, 0o644); err != nil {
+		t.Fatalf("failed to write %s: %s", targetPath, err)
+	}
+}
+
+// setUpGitTreeBaseRepo creates a git repository with one commit that
+// contains a released chart archive and an icon.
+func setUpGitTreeBaseRepo(t *testing.T) p.Paths {
+	t.Helper()
+	repoRoot := t.TempDir()
+	paths := p.Paths{
+		Assets:   filepath.Join(repoRoot, "assets"),
+		Icons:    filepath.Join(repoRoot, "assets", "icons"),
+		RepoRoot: repoRoot,
+	}
+	repo, err := git.PlainInit(repoRoot, false)
+	if err != nil {
+		t.Fatalf("failed to init repository: %s", err)
+	}
+	copyTestdataFile(t, "testchart-base.tgz", filepath.Join(paths.Assets, "vendor", "testchart-1.0.0.tgz"))
+	copyTestdataFile(t, "testchart-base.tgz", filepath.Join(paths.Icons, "testchart.png"))
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("failed to get worktree: %s", err)
+	}
+	if err := worktree.AddGlob("assets"); err != nil {
+		t.Fatalf("failed to add assets: %s", err)
+	}
+	_, err = worktree.Commit("release", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatalf("failed to commit: %s", err)
+	}
+	return paths
+}
+
+func TestGitTreeBase(t *testing.T) {
+	t.Run("should return error for revision that does not exist", func(t *testing.T) {
+		paths := setUpGitTreeBaseRepo(t)
+		_, err := newGitTreeBase(paths, "does-not-exist")
+		assert.ErrorContains(t, err, "failed to resolve revision does-not-exist")
+	})
+
+	t.Run("hasAsset", func(t *testing.T) {
+		paths := setUpGitTreeBaseRepo(t)
+		base, err := newGitTreeBase(paths, "HEAD")
+		if !assert.Nil(t, err) {
+			return
+		}
+		released, err := base.hasAsset(filepath.Join("vendor", "testchart-1.0.0.tgz"))
+		assert.Nil(t, err)
+		assert.True(t, released)
+		released, err = base.hasAsset(filepath.Join("vendor", "testchart-2.0.0.tgz"))
+		assert.Nil(t, err)
+		assert.False(t, released)
+	})
+
+	t.Run("compareAssets", func(t *testing.T) {
+		testCases := []struct {
+			Description       string
+			UpdateChart       string
+			ExpectedUnchanged int
+			ExpectedModified  int
+		}{
+			{
+				Description:       "should not report a modification if chart archive has not changed",
+				UpdateChart:       "testchart-base.tgz",
+				ExpectedUnchanged: 1,
+			},
+			{
+				Description:      "should report a modification if chart archive has changed",
+				UpdateChart:      "testchart-modified.tgz",
+				ExpectedModified: 1,
+			},
+			{
+				Description:       "should not report a modification if chart archive only differs in catalog.cattle.io-prefixed annotations",
+				UpdateChart:       "testchart-annotation-added.tgz",
+				ExpectedUnchanged: 1,
+			},
+		}
+		for _, testCase := range testCases {
+			t.Run(testCase.Description, func(t *testing.T) {
+				paths := setUpGitTreeBaseRepo(t)
+				base, err := newGitTreeBase(paths, "HEAD")
+				if !assert.Nil(t, err) {
+					return
+				}
+				copyTestdataFile(t, testCase.UpdateChart, filepath.Join(paths.Assets, "vendor", "testchart-1.0.0.tgz"))
+				directoryComparison, err := base.compareAssets(paths.Assets)
+				if !assert.Nil(t, err) {
+					return
+				}
+				assert.Len(t, directoryComparison.Unchanged, testCase.ExpectedUnchanged)
+				assert.Len(t, directoryComparison.Modified, testCase.ExpectedModified)
+			})
+		}
+
+		t.Run("should report added and removed files and ignore icons", func(t *testing.T) {
+			paths := setUpGitTreeBaseRepo(t)
+			base, err := newGitTreeBase(paths, "HEAD")
+			if !assert.Nil(t, err) {
+				return
+			}
+			removedPath := filepath.Join(paths.Assets, "vendor", "testchart-1.0.0.tgz")
+			if err := os.Remove(removedPath); err != nil {
+				t.Fatalf("failed to remove %s: %s", removedPath, err)
+			}
+			addedPath := filepath.Join(paths.Assets, "vendor", "testchart-2.0.0.tgz")
+			copyTestdataFile(t, "testchart-base.tgz", addedPath)
+			copyTestdataFile(t, "testchart-modified.tgz", filepath.Join(paths.Icons, "testchart.png"))
+
+			directoryComparison, err := base.compareAssets(paths.Assets)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, []string{removedPath}, directoryComparison.Removed)
+			assert.Equal(t, []string{addedPath}, directoryComparison.Added)
+			assert.Len(t, directoryComparison.Modified, 0)
+		})
+	})
+
+	t.Run("getReleasedBases should return error when local base cannot be read and there is no upstream", func(t *testing.T) {
+		paths := setUpGitTreeBaseRepo(t)
+		_, err := getReleasedBases(paths, ConfigurationYaml{LocalBase: "does-not-exist"})
+		assert.ErrorContains(t, err, "failed to resolve revision does-not-exist")
+		assert.Nil(t, removeReleasedBases())
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 
 	"github.com/sirupsen/logrus"
 
@@ -12,6 +13,15 @@ import (
 
 type ConfigurationYaml struct {
 	ValidateUpstreams []validateUpstream `json:"validate"`
+	// Validations configures individual validations by name.
+	Validations map[string]ValidationConfig `json:"validations,omitempty"`
+	// LocalBase is a revision in the local git repository to compare
+	// against instead of cloning ValidateUpstreams. It is set from
+	// Options.Base rather than configuration.yaml.
+	LocalBase string `json:"-"`
+	// Package limits the validations that check each package on their
+	// own to one package. It is set from Options.Package.
+	Package string `json:"-"`
 }
 
 type validateUpstream struct {
@@ -19,29 +29,62 @@ type validateUpstream struct {
 	Branch string
 }
 
+// ValidationConfig configures a single validation. Validations are
+// enabled and have SeverityError by default.
+type ValidationConfig struct {
+	Enabled  *bool    `json:"enabled,omitempty"`
+	Severity Severity `json:"severity,omitempty"`
+}
+
+// The validation upstreams are only needed by some validations, so they
+// are optional; Run returns an error if a validation that needs them is
+// run without them or a local base.
 func (configYaml ConfigurationYaml) Validate() error {
-	if len(configYaml.ValidateUpstreams) == 0 {
-		return errors.New("must provide validation configuration")
+	for _, upstream := range configYaml.ValidateUpstreams {
+		if upstream.Branch == "" {
+			return errors.New("must provide branch in validation configuration")
+		}
+		if upstream.URL == "" {
+			return errors.New("must provide URL in validation configuration")
+		}
 	}
-	if configYaml.ValidateUpstreams[0].Branch == "" {
-		return errors.New("must provide branch in validation configuration")
-	}
-	if configYaml.ValidateUpstreams[0].URL == "" {
-		return errors.New("must provide URL in validation configuration")
+	for name, validationConfig := range configYaml.Validations {
+		if _, ok := getValidation(name); !ok {
+			return fmt.Errorf("unknown validation %q", name)
+		}
+		if severity := validationConfig.Severity; severity != "" && !slices.Contains([]Severity{SeverityError, SeverityWarning}, severity) {
+			return fmt.Errorf("invalid severity %q for validation %s", severity, name)
+		}
 	}
 	return nil
 }
 
+// IsEnabled returns whether the validation with the passed name is
+// enabled.
+func (configYaml ConfigurationYaml) IsEnabled(name string) bool {
+	enabled := configYaml.Validations[name].Enabled
+	return enabled == nil || *enabled
+}
+
+// GetSeverity returns the severity of the validation with the passed
+// name.
+func (configYaml ConfigurationYaml) GetSeverity(name string) Severity {
+	if severity := configYaml.Validations[name].Severity; severity != "" {
+		return severity
+	}
+	return SeverityError
+}
+
 func ReadConfig(configYamlPath string) (ConfigurationYaml, error) {
 	upstreamYamlFile, err := os.ReadFile(configYamlPath)
 	configYaml := ConfigurationYaml{}
 	if err != nil {
 		logrus.Debug(err)
-	} else {
-		err = yaml.Unmarshal(upstreamYamlFile, &configYaml)
+	} else if err := yaml.Unmarshal(upstreamYamlFile, &configYaml); err != nil {
+		return ConfigurationYaml{}, fmt.Errorf("failed to parse %s: %w", configYamlPath, err)
 	}
 	if err := configYaml.Validate(); err != nil {
 		return ConfigurationYaml{}, fmt.Errorf("invalid config: %w", err)
 	}
-	return configYaml, err
+	return configYaml, nil
 }
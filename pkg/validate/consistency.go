@@ -0,0 +1,242 @@
+package validate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rancher/partner-charts-ci/pkg/conform"
+	p "github.com/rancher/partner-charts-ci/pkg/paths"
+	"github.com/rancher/partner-charts-ci/pkg/pkg"
+
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// assetArchive is a chart archive in the assets directory.
+type assetArchive struct {
+	// Path is the path to the chart archive.
+	Path string
+	// Vendor is the name of the directory in assets/ that contains
+	// the chart archive.
+	Vendor string
+	// Name and Version come from the Chart.yaml of the chart archive.
+	Name    string
+	Version string
+}
+
+// listAssetArchivePaths returns the paths of the chart archives in
+// assets/<vendor>/, sorted.
+func listAssetArchivePaths(paths p.Paths) ([]string, error) {
+	tgzPaths, err := filepath.Glob(filepath.Join(paths.Assets, "*", "*.tgz"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chart archives: %w", err)
+	}
+	tgzPaths = slices.DeleteFunc(tgzPaths, func(tgzPath string) bool {
+		return filepath.Clean(filepath.Dir(tgzPath)) == filepath.Clean(paths.Icons)
+	})
+	slices.Sort(tgzPaths)
+	return tgzPaths, nil
+}
+
+// loadAssetArchives loads the chart archives in assets/<vendor>/. If
+// chartName is not empty, only the chart archives of that chart are
+// loaded. It returns an error for each chart archive that cannot be
+// loaded.
+func loadAssetArchives(paths p.Paths, chartName string) ([]assetArchive, []error) {
+	tgzPaths, err := listAssetArchivePaths(paths)
+	if err != nil {
+		return nil, []error{err}
+	}
+	archives := make([]assetArchive, 0, len(tgzPaths))
+	errs := make([]error, 0)
+	for _, tgzPath := range tgzPaths {
+		if chartName != "" && !strings.HasPrefix(filepath.Base(tgzPath), chartName+"-") {
+			continue
+		}
+		helmChart, err := loader.LoadFile(tgzPath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load %s: %w", tgzPath, err))
+			continue
+		}
+		if chartName != "" && helmChart.Name() != chartName {
+			continue
+		}
+		archives = append(archives, assetArchive{
+			Path:    tgzPath,
+			Vendor:  filepath.Base(filepath.Dir(tgzPath)),
+			Name:    helmChart.Name(),
+			Version: helmChart.Metadata.Version,
+		})
+	}
+	return archives, errs
+}
+
+// validateChartsMatchAssets checks that each chart archive in assets/
+// is unpacked to charts/<vendor>/<chart>/<version>/ with identical
+// contents, and that there is nothing in charts/ that does not come
+// from a chart archive.
+func validateChartsMatchAssets(paths p.Paths, configYaml ConfigurationYaml) []error {
+	chartName := getChartName(configYaml.Package)
+	archives, errs := loadAssetArchives(paths, chartName)
+	chartNamePattern := "*"
+	if chartName != "" {
+		chartNamePattern = chartName
+	}
+	chartDirs, err := filepath.Glob(filepath.Join(paths.Charts, "*", chartNamePattern, "*"))
+	if err != nil {
+		return append(errs, fmt.Errorf("failed to list chart directories: %w", err))
+	}
+	return append(errs, checkChartsMatchAssets(paths, archives, chartDirs)...)
+}
+
+func checkChartsMatchAssets(paths p.Paths, archives []assetArchive, chartDirs []string) []error {
+	errs := make([]error, 0)
+	expectedChartDirs := map[string]bool{}
+	for _, archive := range archives {
+		packageName := archive.Vendor + "/" + archive.Name
+		expectedFilename := fmt.Sprintf("%s-%s.tgz", archive.Name, archive.Version)
+		if filename := filepath.Base(archive.Path); filename != expectedFilename {
+			err := fmt.Errorf("%s contains %s version %s and should be named %s", archive.Path, archive.Name, archive.Version, expectedFilename)
+			errs = append(errs, withLocation(err, packageName, archive.Path))
+		}
+
+		chartDir := filepath.Join(paths.Charts, archive.Vendor, archive.Name, archive.Version)
+		expectedChartDirs[filepath.Clean(chartDir)] = true
+		if _, err := os.Stat(chartDir); os.IsNotExist(err) {
+			err := fmt.Errorf("%s has no unpacked chart directory %s", archive.Path, chartDir)
+			errs = append(errs, withLocation(err, packageName, archive.Path))
+			continue
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("failed to stat %s: %w", chartDir, err))
+			continue
+		}
+		match, err := conform.ChartDirectoryMatchesArchive(archive.Path, chartDir)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to compare %s to %s: %w", chartDir, archive.Path, err))
+		} else if !match {
+			err := fmt.Errorf("contents of %s differ from %s", chartDir, archive.Path)
+			errs = append(errs, withLocation(err, packageName, chartDir))
+		}
+	}
+
+	for _, chartDir := range chartDirs {
+		if !expectedChartDirs[filepath.Clean(chartDir)] {
+			err := fmt.Errorf("%s has no corresponding chart archive in %s", chartDir, paths.Assets)
+			errs = append(errs, withLocation(err, "", chartDir))
+		}
+	}
+
+	return errs
+}
+
+// validateIndexYamlMatchesAssets checks that every chart version in
+// index.yaml refers to a chart archive in assets/ that has the same
+// name, version and digest, and that every chart archive in assets/ is
+// in index.yaml.
+func validateIndexYamlMatchesAssets(paths p.Paths, _ ConfigurationYaml) []error {
+	indexYaml, err := repo.LoadIndexFile(paths.IndexYaml)
+	if err != nil {
+		return []error{fmt.Errorf("failed to load index.yaml: %w", err)}
+	}
+	// Chart archives that cannot be loaded are reported by
+	// validateChartsMatchAssets, so those errors are not returned here.
+	archives, _ := loadAssetArchives(paths, "")
+	digests := make(map[string]string, len(archives))
+	errs := make([]error, 0)
+	for _, archive := range archives {
+		digest, err := checksumFile(archive.Path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to get digest of %s: %w", archive.Path, err))
+			continue
+		}
+		digests[filepath.Clean(archive.Path)] = digest
+	}
+	return append(errs, checkIndexYamlMatchesAssets(indexYaml, paths.IndexYaml, archives, digests)...)
+}
+
+// checkIndexYamlMatchesAssets does the work of validateIndexYamlMatchesAssets.
+// URLs in indexYaml are relative to the directory of indexYamlPath, and
+// digests maps the path of each archive to the sha256 digest of its
+// contents.
+func checkIndexYamlMatchesAssets(indexYaml *repo.IndexFile, indexYamlPath string, archives []assetArchive, digests map[string]string) []error {
+	indexDir := filepath.Dir(indexYamlPath)
+	archivesByPath := make(map[string]assetArchive, len(archives))
+	for _, archive := range archives {
+		archivesByPath[filepath.Clean(archive.Path)] = archive
+	}
+
+	chartNames := make([]string, 0, len(indexYaml.Entries))
+	for chartName := range indexYaml.Entries {
+		chartNames = append(chartNames, chartName)
+	}
+	slices.Sort(chartNames)
+
+	errs := make([]error, 0)
+	referenced := map[string]bool{}
+	for _, chartName := range chartNames {
+		for _, chartVersion := range indexYaml.Entries[chartName] {
+			if len(chartVersion.URLs) == 0 {
+				errs = append(errs, fmt.Errorf("index.yaml entry for %s version %s has no URLs", chartName, chartVersion.Version))
+				continue
+			}
+			for _, url := range chartVersion.URLs {
+				archivePath := filepath.Clean(filepath.Join(indexDir, url))
+				referenced[archivePath] = true
+				archive, ok := archivesByPath[archivePath]
+				if !ok {
+					errs = append(errs, fmt.Errorf("index.yaml entry for %s version %s refers to %s, which does not exist", chartName, chartVersion.Version, url))
+					continue
+				}
+				if archive.Name != chartName || archive.Version != chartVersion.Version {
+					errs = append(errs, fmt.Errorf("index.yaml entry for %s version %s refers to %s, which contains %s version %s", chartName, chartVersion.Version, url, archive.Name, archive.Version))
+				}
+				if digest, ok := digests[archivePath]; ok && digest != chartVersion.Digest {
+					errs = append(errs, fmt.Errorf("index.yaml entry for %s version %s has digest %s but %s has digest %s", chartName, chartVersion.Version, chartVersion.Digest, url, digest))
+				}
+			}
+		}
+	}
+
+	for _, archive := range archives {
+		if !referenced[filepath.Clean(archive.Path)] {
+			err := fmt.Errorf("%s is not in index.yaml", archive.Path)
+			errs = append(errs, withLocation(err, archive.Vendor+"/"+archive.Name, archive.Path))
+		}
+	}
+
+	return locateErrors(errs, indexYamlPath)
+}
+
+// validateAssetVendors checks that each chart archive in assets/ is in
+// the directory of the vendor of its package.
+func validateAssetVendors(paths p.Paths, configYaml ConfigurationYaml) []error {
+	packageWrappers, err := pkg.ListPackageWrappers(paths, configYaml.Package)
+	if err != nil {
+		return []error{fmt.Errorf("failed to list package wrappers: %w", err)}
+	}
+	// Chart archives that cannot be loaded are reported by
+	// validateChartsMatchAssets, so those errors are not returned here.
+	archives, _ := loadAssetArchives(paths, getChartName(configYaml.Package))
+	return checkAssetVendors(archives, packageWrappers)
+}
+
+func checkAssetVendors(archives []assetArchive, packageWrappers []pkg.PackageWrapper) []error {
+	vendors := map[string][]string{}
+	for _, packageWrapper := range packageWrappers {
+		vendors[packageWrapper.Name] = append(vendors[packageWrapper.Name], packageWrapper.Vendor)
+	}
+
+	errs := make([]error, 0)
+	for _, archive := range archives {
+		packageVendors, ok := vendors[archive.Name]
+		if !ok || slices.Contains(packageVendors, archive.Vendor) {
+			continue
+		}
+		err := fmt.Errorf("%s is in vendor directory %q but package %s is in %v", archive.Path, archive.Vendor, archive.Name, packageVendors)
+		errs = append(errs, withLocation(err, packageVendors[0]+"/"+archive.Name, archive.Path))
+	}
+	return errs
+}
@@ -0,0 +1,191 @@
+package validate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rancher/partner-charts-ci/pkg/conform"
+	p "github.com/rancher/partner-charts-ci/pkg/paths"
+	"github.com/rancher/partner-charts-ci/pkg/pkg"
+	"github.com/stretchr/testify/assert"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+func getConsistencyTestPaths(t *testing.T) p.Paths {
+	t.Helper()
+	repoRoot := t.TempDir()
+	assets := filepath.Join(repoRoot, "assets")
+	return p.Paths{
+		Assets:    assets,
+		Charts:    filepath.Join(repoRoot, "charts"),
+		Icons:     filepath.Join(assets, "icons"),
+		IndexYaml: filepath.Join(repoRoot, "index.yaml"),
+		Packages:  filepath.Join(repoRoot, "packages"),
+		RepoRoot:  repoRoot,
+	}
+}
+
+// writeConsistentChart writes a chart archive to assets/<vendor>/ and
+// unpacks it to charts/<vendor>/<name>/<version>/.
+func writeConsistentChart(t *testing.T, paths p.Paths, vendor, name, version string) assetArchive {
+	t.Helper()
+	helmChart := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: "v2", Name: name, Version: version},
+	}
+	tgzPath, err := conform.SaveChart(helmChart, filepath.Join(paths.Assets, vendor))
+	if err != nil {
+		t.Fatalf("failed to save chart: %s", err)
+	}
+	if err := conform.Gunzip(tgzPath, filepath.Join(paths.Charts, vendor, name, version)); err != nil {
+		t.Fatalf("failed to unpack chart: %s", err)
+	}
+	return assetArchive{Path: tgzPath, Vendor: vendor, Name: name, Version: version}
+}
+
+func TestCheckChartsMatchAssets(t *testing.T) {
+	t.Run("should return no errors when charts/ matches assets/", func(t *testing.T) {
+		paths := getConsistencyTestPaths(t)
+		archive := writeConsistentChart(t, paths, "vendor", "testchart", "1.0.0")
+		chartDirs := []string{filepath.Join(paths.Charts, "vendor", "testchart", "1.0.0")}
+		assert.Len(t, checkChartsMatchAssets(paths, []assetArchive{archive}, chartDirs), 0)
+	})
+
+	t.Run("should return error for chart archive without chart directory", func(t *testing.T) {
+		paths := getConsistencyTestPaths(t)
+		archive := writeConsistentChart(t, paths, "vendor", "testchart", "1.0.0")
+		chartDir := filepath.Join(paths.Charts, "vendor", "testchart", "1.0.0")
+		if err := os.RemoveAll(chartDir); err != nil {
+			t.Fatalf("failed to remove %s: %s", chartDir, err)
+		}
+		errs := checkChartsMatchAssets(paths, []assetArchive{archive}, []string{})
+		if assert.Len(t, errs, 1) {
+			assert.ErrorContains(t, errs[0], "has no unpacked chart directory "+chartDir)
+		}
+	})
+
+	t.Run("should return error for chart directory without chart archive", func(t *testing.T) {
+		paths := getConsistencyTestPaths(t)
+		chartDir := filepath.Join(paths.Charts, "vendor", "testchart", "1.0.0")
+		errs := checkChartsMatchAssets(paths, []assetArchive{}, []string{chartDir})
+		if assert.Len(t, errs, 1) {
+			assert.ErrorContains(t, errs[0], chartDir+" has no corresponding chart archive")
+		}
+	})
+
+	t.Run("should return error when chart directory differs from chart archive", func(t *testing.T) {
+		paths := getConsistencyTestPaths(t)
+		archive := writeConsistentChart(t, paths, "vendor", "testchart", "1.0.0")
+		chartDir := filepath.Join(paths.Charts, "vendor", "testchart", "1.0.0")
+		if err := os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("key: value\n"), 0o644); err != nil {
+			t.Fatalf("failed to write values.yaml: %s", err)
+		}
+		errs := checkChartsMatchAssets(paths, []assetArchive{archive}, []string{chartDir})
+		if assert.Len(t, errs, 1) {
+			assert.ErrorContains(t, errs[0], "contents of "+chartDir+" differ from "+archive.Path)
+		}
+	})
+
+	t.Run("should return error for chart archive with wrong filename", func(t *testing.T) {
+		paths := getConsistencyTestPaths(t)
+		archive := writeConsistentChart(t, paths, "vendor", "testchart", "1.0.0")
+		renamedPath := filepath.Join(filepath.Dir(archive.Path), "testchart-2.0.0.tgz")
+		if err := os.Rename(archive.Path, renamedPath); err != nil {
+			t.Fatalf("failed to rename %s: %s", archive.Path, err)
+		}
+		archive.Path = renamedPath
+		chartDirs := []string{filepath.Join(paths.Charts, "vendor", "testchart", "1.0.0")}
+		errs := checkChartsMatchAssets(paths, []assetArchive{archive}, chartDirs)
+		if assert.Len(t, errs, 1) {
+			assert.ErrorContains(t, errs[0], "should be named testchart-1.0.0.tgz")
+		}
+	})
+}
+
+func TestValidateChartsMatchAssets(t *testing.T) {
+	t.Run("should only check chart archives of Package when it is set", func(t *testing.T) {
+		paths := getConsistencyTestPaths(t)
+		writeConsistentChart(t, paths, "vendor", "testchart", "1.0.0")
+		writeConsistentChart(t, paths, "vendor", "testchart-extra", "1.0.0")
+		if err := os.RemoveAll(filepath.Join(paths.Charts, "vendor", "testchart-extra")); err != nil {
+			t.Fatalf("failed to remove chart directory: %s", err)
+		}
+
+		assert.Len(t, validateChartsMatchAssets(paths, ConfigurationYaml{}), 1)
+		assert.Len(t, validateChartsMatchAssets(paths, ConfigurationYaml{Package: "vendor/testchart"}), 0)
+	})
+}
+
+func TestCheckIndexYamlMatchesAssets(t *testing.T) {
+	indexYamlPath := "/repo/index.yaml"
+	archive := assetArchive{Path: "/repo/assets/vendor/testchart-1.0.0.tgz", Vendor: "vendor", Name: "testchart", Version: "1.0.0"}
+	digests := map[string]string{archive.Path: "abc123"}
+	newIndexYaml := func(version, url, digest string) *repo.IndexFile {
+		return &repo.IndexFile{
+			Entries: map[string]repo.ChartVersions{
+				"testchart": {
+					{
+						Metadata: &chart.Metadata{Name: "testchart", Version: version},
+						URLs:     []string{url},
+						Digest:   digest,
+					},
+				},
+			},
+		}
+	}
+
+	t.Run("should return no errors when index.yaml matches assets/", func(t *testing.T) {
+		indexYaml := newIndexYaml("1.0.0", "assets/vendor/testchart-1.0.0.tgz", "abc123")
+		assert.Len(t, checkIndexYamlMatchesAssets(indexYaml, indexYamlPath, []assetArchive{archive}, digests), 0)
+	})
+
+	t.Run("should return error for stale digest", func(t *testing.T) {
+		indexYaml := newIndexYaml("1.0.0", "assets/vendor/testchart-1.0.0.tgz", "def456")
+		errs := checkIndexYamlMatchesAssets(indexYaml, indexYamlPath, []assetArchive{archive}, digests)
+		if assert.Len(t, errs, 1) {
+			assert.ErrorContains(t, errs[0], "has digest def456 but assets/vendor/testchart-1.0.0.tgz has digest abc123")
+		}
+	})
+
+	t.Run("should return errors for entry referring to missing chart archive", func(t *testing.T) {
+		indexYaml := newIndexYaml("1.0.0", "assets/other/testchart-1.0.0.tgz", "abc123")
+		errs := checkIndexYamlMatchesAssets(indexYaml, indexYamlPath, []assetArchive{archive}, digests)
+		if assert.Len(t, errs, 2) {
+			assert.ErrorContains(t, errs[0], "refers to assets/other/testchart-1.0.0.tgz, which does not exist")
+			assert.ErrorContains(t, errs[1], archive.Path+" is not in index.yaml")
+		}
+	})
+
+	t.Run("should return error for entry whose version differs from chart archive", func(t *testing.T) {
+		indexYaml := newIndexYaml("2.0.0", "assets/vendor/testchart-1.0.0.tgz", "abc123")
+		errs := checkIndexYamlMatchesAssets(indexYaml, indexYamlPath, []assetArchive{archive}, digests)
+		if assert.Len(t, errs, 1) {
+			assert.ErrorContains(t, errs[0], "which contains testchart version 1.0.0")
+		}
+	})
+}
+
+func TestCheckAssetVendors(t *testing.T) {
+	packageWrappers := []pkg.PackageWrapper{
+		{Name: "testchart", Vendor: "vendor"},
+	}
+
+	t.Run("should return no errors when chart archive is in vendor directory of package", func(t *testing.T) {
+		archives := []assetArchive{
+			{Path: "assets/vendor/testchart-1.0.0.tgz", Vendor: "vendor", Name: "testchart", Version: "1.0.0"},
+		}
+		assert.Len(t, checkAssetVendors(archives, packageWrappers), 0)
+	})
+
+	t.Run("should return error when chart archive is in wrong vendor directory", func(t *testing.T) {
+		archives := []assetArchive{
+			{Path: "assets/other/testchart-1.0.0.tgz", Vendor: "other", Name: "testchart", Version: "1.0.0"},
+		}
+		errs := checkAssetVendors(archives, packageWrappers)
+		if assert.Len(t, errs, 1) {
+			assert.ErrorContains(t, errs[0], `assets/other/testchart-1.0.0.tgz is in vendor directory "other"`)
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package validate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// A ChartDiff describes how two chart archives differ, after the changes
+// that validation ignores have been removed from both of them. Paths are
+// relative to the root of the chart.
+type ChartDiff struct {
+	Added    []string
+	Removed  []string
+	Modified []string
+	// Diff is a unified diff of the text files in Added, Removed and
+	// Modified.
+	Diff string
+}
+
+// Match returns whether the charts do not differ.
+func (chartDiff ChartDiff) Match() bool {
+	return len(chartDiff.Added)+len(chartDiff.Removed)+len(chartDiff.Modified) == 0
+}
+
+// String returns a report that lists the files that differ, followed by
+// the unified diff.
+func (chartDiff ChartDiff) String() string {
+	builder := &strings.Builder{}
+	for _, change := range []struct {
+		Kind  string
+		Files []string
+	}{
+		{Kind: "added", Files: chartDiff.Added},
+		{Kind: "removed", Files: chartDiff.Removed},
+		{Kind: "modified", Files: chartDiff.Modified},
+	} {
+		for _, file := range change.Files {
+			fmt.Fprintf(builder, "%s: %s\n", change.Kind, file)
+		}
+	}
+	builder.WriteString(chartDiff.Diff)
+	return builder.String()
+}
+
+// DiffHelmCharts compares the chart archives at upstreamPath and
+// updatePath, ignoring the changes that prepareTgzForComparison removes,
+// and returns how they differ.
+func DiffHelmCharts(upstreamPath, updatePath string) (chartDiff ChartDiff, err error) {
+	upstreamChartDirectory, err := prepareTgzForComparison(upstreamPath)
+	if err != nil {
+		return ChartDiff{}, fmt.Errorf("failed to prepare %s for comparison: %w", upstreamPath, err)
+	}
+	defer func() {
+		if closeErr := os.RemoveAll(upstreamChartDirectory); closeErr != nil {
+			err = errors.Join(err, closeErr)
+		}
+	}()
+
+	updateChartDirectory, err := prepareTgzForComparison(updatePath)
+	if err != nil {
+		return ChartDiff{}, fmt.Errorf("failed to prepare %s for comparison: %w", updatePath, err)
+	}
+	defer func() {
+		if closeErr := os.RemoveAll(updateChartDirectory); closeErr != nil {
+			err = errors.Join(err, closeErr)
+		}
+	}()
+
+	directoryComparison, err := compareDirectories(upstreamChartDirectory, updateChartDirectory, []string{})
+	if err != nil {
+		return ChartDiff{}, fmt.Errorf("failed to compare directories: %w", err)
+	}
+
+	// compareDirectories returns paths in updateChartDirectory
+	relativePaths := func(filePaths []string) ([]string, error) {
+		relativePaths := make([]string, 0, len(filePaths))
+		for _, filePath := range filePaths {
+			relativePath, err := filepath.Rel(updateChartDirectory, filePath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get relative path of %s: %w", filePath, err)
+			}
+			relativePaths = append(relativePaths, filepath.ToSlash(relativePath))
+		}
+		slices.Sort(relativePaths)
+		return relativePaths, nil
+	}
+	if chartDiff.Added, err = relativePaths(directoryComparison.Added); err != nil {
+		return ChartDiff{}, err
+	}
+	if chartDiff.Removed, err = relativePaths(directoryComparison.Removed); err != nil {
+		return ChartDiff{}, err
+	}
+	if chartDiff.Modified, err = relativePaths(directoryComparison.Modified); err != nil {
+		return ChartDiff{}, err
+	}
+
+	changedFiles := slices.Concat(chartDiff.Added, chartDiff.Removed, chartDiff.Modified)
+	slices.Sort(changedFiles)
+	diffBuilder := &strings.Builder{}
+	for _, changedFile := range changedFiles {
+		upstreamContents, err := readFileIfExists(filepath.Join(upstreamChartDirectory, filepath.FromSlash(changedFile)))
+		if err != nil {
+			return ChartDiff{}, err
+		}
+		updateContents, err := readFileIfExists(filepath.Join(updateChartDirectory, filepath.FromSlash(changedFile)))
+		if err != nil {
+			return ChartDiff{}, err
+		}
+		fileDiff, err := getUnifiedDiff(changedFile, upstreamContents, updateContents)
+		if err != nil {
+			return ChartDiff{}, fmt.Errorf("failed to diff %s: %w", changedFile, err)
+		}
+		diffBuilder.WriteString(fileDiff)
+	}
+	chartDiff.Diff = diffBuilder.String()
+
+	return chartDiff, nil
+}
+
+// readFileIfExists returns the contents of the file at filePath, or nil
+// if it does not exist.
+func readFileIfExists(filePath string) ([]byte, error) {
+	contents, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	return contents, nil
+}
+
+// getUnifiedDiff returns a unified diff of two versions of the file at
+// relativePath. A nil version means that the file does not exist.
+// Binary files are not diffed.
+func getUnifiedDiff(relativePath string, a, b []byte) (string, error) {
+	if isBinary(a) || isBinary(b) {
+		return fmt.Sprintf("Binary files a/%s and b/%s differ\n", relativePath, relativePath), nil
+	}
+	fromFile, toFile := "a/"+relativePath, "b/"+relativePath
+	if a == nil {
+		fromFile = "/dev/null"
+	}
+	if b == nil {
+		toFile = "/dev/null"
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(string(a)),
+		B:        splitLines(string(b)),
+		FromFile: fromFile,
+		ToFile:   toFile,
+		Context:  3,
+	})
+}
+
+// splitLines splits contents into lines that keep their line endings.
+// Unlike difflib.SplitLines, it does not add an empty line after a
+// trailing newline. A missing newline at the end of contents is added so
+// that the last line does not run into the next line of the diff.
+func splitLines(contents string) []string {
+	lines := strings.SplitAfter(contents, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+func isBinary(contents []byte) bool {
+	return bytes.IndexByte(contents, 0) != -1 || !utf8.Valid(contents)
+}
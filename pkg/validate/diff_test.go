@@ -0,0 +1,48 @@
+package validate
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffHelmCharts(t *testing.T) {
+	t.Run("should report modified files with a unified diff", func(t *testing.T) {
+		chartDiff, err := DiffHelmCharts(filepath.Join("testdata", "testchart-base.tgz"), filepath.Join("testdata", "testchart-modified.tgz"))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.False(t, chartDiff.Match())
+		assert.Equal(t, []string{"Chart.yaml"}, chartDiff.Modified)
+		assert.Len(t, chartDiff.Added, 0)
+		assert.Len(t, chartDiff.Removed, 0)
+		assert.Contains(t, chartDiff.Diff, "--- a/Chart.yaml\n+++ b/Chart.yaml\n")
+		assert.Contains(t, chartDiff.Diff, "-version: 0.31.0\n+version: 0.31.1\n")
+		assert.NotContains(t, chartDiff.Diff, "catalog.cattle.io/hidden")
+		assert.Contains(t, chartDiff.String(), "modified: Chart.yaml\n--- a/Chart.yaml")
+	})
+
+	t.Run("should match charts that only differ in catalog.cattle.io-prefixed annotations", func(t *testing.T) {
+		chartDiff, err := DiffHelmCharts(filepath.Join("testdata", "testchart-base.tgz"), filepath.Join("testdata", "testchart-annotation-added.tgz"))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.True(t, chartDiff.Match())
+		assert.Equal(t, "", chartDiff.String())
+	})
+}
+
+func TestGetUnifiedDiff(t *testing.T) {
+	t.Run("should diff added file against /dev/null", func(t *testing.T) {
+		diff, err := getUnifiedDiff("values.yaml", nil, []byte("a: b\n"))
+		assert.Nil(t, err)
+		assert.Equal(t, "--- /dev/null\n+++ b/values.yaml\n@@ -0,0 +1 @@\n+a: b\n", diff)
+	})
+
+	t.Run("should not diff binary files", func(t *testing.T) {
+		diff, err := getUnifiedDiff("logo.png", []byte{0x89, 0x00}, []byte{0x89, 0x01})
+		assert.Nil(t, err)
+		assert.Equal(t, "Binary files a/logo.png and b/logo.png differ\n", diff)
+	})
+}
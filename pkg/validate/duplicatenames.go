@@ -27,7 +27,7 @@ func findDuplicateNames(packageWrappers []pkg.PackageWrapper) []error {
 			packageNames[packageWrapper.Name] = packageWrapper.FullName()
 		} else {
 			error := fmt.Errorf("duplicate package names %s and %s", existingName, packageWrapper.FullName())
-			errors = append(errors, error)
+			errors = append(errors, withLocation(error, packageWrapper.FullName(), packageWrapper.Path))
 		}
 	}
 	return errors
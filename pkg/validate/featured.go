@@ -0,0 +1,63 @@
+package validate
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/Masterminds/semver/v3"
+	p "github.com/rancher/partner-charts-ci/pkg/paths"
+
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+const annotationFeatured = "catalog.cattle.io/featured"
+
+// validateFeaturedAnnotations checks that, for each chart in index.yaml,
+// the featured annotation is only present on the highest chart version.
+func validateFeaturedAnnotations(paths p.Paths, _ ConfigurationYaml) []error {
+	indexYaml, err := repo.LoadIndexFile(paths.IndexYaml)
+	if err != nil {
+		return []error{fmt.Errorf("failed to load index.yaml: %w", err)}
+	}
+	return locateErrors(checkFeaturedAnnotations(indexYaml), paths.IndexYaml)
+}
+
+func checkFeaturedAnnotations(indexYaml *repo.IndexFile) []error {
+	chartNames := make([]string, 0, len(indexYaml.Entries))
+	for chartName := range indexYaml.Entries {
+		chartNames = append(chartNames, chartName)
+	}
+	slices.Sort(chartNames)
+
+	errs := make([]error, 0)
+	for _, chartName := range chartNames {
+		chartVersions := indexYaml.Entries[chartName]
+		var latestVersion *semver.Version
+		for _, chartVersion := range chartVersions {
+			version, err := semver.NewVersion(chartVersion.Version)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: failed to parse version %q: %w", chartName, chartVersion.Version, err))
+				continue
+			}
+			if latestVersion == nil || version.GreaterThan(latestVersion) {
+				latestVersion = version
+			}
+		}
+		if latestVersion == nil {
+			continue
+		}
+		for _, chartVersion := range chartVersions {
+			value, ok := chartVersion.Annotations[annotationFeatured]
+			if !ok {
+				continue
+			}
+			version, err := semver.NewVersion(chartVersion.Version)
+			if err != nil || version.Equal(latestVersion) {
+				continue
+			}
+			errs = append(errs, fmt.Errorf("%s version %s has annotation %s=%q but is not the latest version %s", chartName, chartVersion.Version, annotationFeatured, value, latestVersion.Original()))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,61 @@
+package validate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+func TestCheckFeaturedAnnotations(t *testing.T) {
+	newChartVersion := func(version string, annotations map[string]string) *repo.ChartVersion {
+		return &repo.ChartVersion{
+			Metadata: &chart.Metadata{
+				Name:        "testchart",
+				Version:     version,
+				Annotations: annotations,
+			},
+		}
+	}
+
+	t.Run("should return no errors when only the latest version is featured", func(t *testing.T) {
+		indexYaml := &repo.IndexFile{
+			Entries: map[string]repo.ChartVersions{
+				"testchart": {
+					newChartVersion("1.9.0", nil),
+					newChartVersion("1.10.0", map[string]string{annotationFeatured: "1"}),
+				},
+			},
+		}
+		assert.Len(t, checkFeaturedAnnotations(indexYaml), 0)
+	})
+
+	t.Run("should return no errors when no version is featured", func(t *testing.T) {
+		indexYaml := &repo.IndexFile{
+			Entries: map[string]repo.ChartVersions{
+				"testchart": {
+					newChartVersion("1.0.0", nil),
+				},
+			},
+		}
+		assert.Len(t, checkFeaturedAnnotations(indexYaml), 0)
+	})
+
+	t.Run("should return errors for featured versions that are not the latest", func(t *testing.T) {
+		indexYaml := &repo.IndexFile{
+			Entries: map[string]repo.ChartVersions{
+				"testchart": {
+					newChartVersion("1.10.0", nil),
+					newChartVersion("1.9.0", map[string]string{annotationFeatured: "2"}),
+					newChartVersion("1.8.0", map[string]string{annotationFeatured: "2"}),
+				},
+			},
+		}
+		errors := checkFeaturedAnnotations(indexYaml)
+		assert.Len(t, errors, 2)
+		assert.ErrorContains(t, errors[0], `testchart version 1.9.0 has annotation catalog.cattle.io/featured="2" but is not the latest version 1.10.0`)
+		assert.ErrorContains(t, errors[1], "testchart version 1.8.0")
+	})
+}
@@ -0,0 +1,92 @@
+package validate
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	p "github.com/rancher/partner-charts-ci/pkg/paths"
+)
+
+// A Finding is a single problem found by a validation.
+type Finding struct {
+	// Check is the name of the validation that found the problem.
+	Check    string   `json:"check"`
+	Severity Severity `json:"severity"`
+	// Package is the <vendor>/<chart> name of the package the problem
+	// is in, if any.
+	Package string `json:"package,omitempty"`
+	// File is the path, relative to the root of the repository, of the
+	// file or directory the problem is in, if any.
+	File    string `json:"file,omitempty"`
+	Message string `json:"message"`
+}
+
+func (finding Finding) Error() string {
+	return finding.Check + ": " + finding.Message
+}
+
+// locatedError is an error that refers to a package or file. Validations
+// return it through withLocation so that Run can fill in the Package and
+// File fields of the corresponding Finding.
+type locatedError struct {
+	packageName string
+	file        string
+	err         error
+}
+
+func (e locatedError) Error() string {
+	return e.err.Error()
+}
+
+func (e locatedError) Unwrap() error {
+	return e.err
+}
+
+// withLocation attaches a package name and file path to err. Either may
+// be empty.
+func withLocation(err error, packageName, file string) error {
+	return locatedError{packageName: packageName, file: file, err: err}
+}
+
+// locateErrors attaches file to each of errs that does not already have
+// a location.
+func locateErrors(errs []error, file string) []error {
+	for index, err := range errs {
+		var located locatedError
+		if !errors.As(err, &located) {
+			errs[index] = withLocation(err, "", file)
+		}
+	}
+	return errs
+}
+
+// newFinding converts an error returned by a validation into a Finding.
+func newFinding(paths p.Paths, check string, severity Severity, err error) Finding {
+	finding := Finding{
+		Check:    check,
+		Severity: severity,
+		Message:  err.Error(),
+	}
+	var located locatedError
+	if errors.As(err, &located) {
+		finding.Package = located.packageName
+		finding.File = getRepoRelativePath(paths, located.file)
+	}
+	return finding
+}
+
+// getRepoRelativePath returns filePath relative to the root of the
+// repository, with forward slashes, so that it can be used in reports
+// that CI systems map to files in the repository.
+func getRepoRelativePath(paths p.Paths, filePath string) string {
+	if filePath == "" {
+		return ""
+	}
+	if filepath.IsAbs(filePath) && paths.RepoRoot != "" {
+		if relativePath, err := filepath.Rel(paths.RepoRoot, filePath); err == nil && !strings.HasPrefix(relativePath, "..") {
+			filePath = relativePath
+		}
+	}
+	return filepath.ToSlash(filePath)
+}
@@ -0,0 +1,49 @@
+package validate
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	p "github.com/rancher/partner-charts-ci/pkg/paths"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFinding(t *testing.T) {
+	repoRoot := t.TempDir()
+	paths := p.Paths{RepoRoot: repoRoot}
+
+	t.Run("should set package and file relative to repository root", func(t *testing.T) {
+		err := withLocation(errors.New("something is wrong"), "vendor/testchart", filepath.Join(repoRoot, "assets", "vendor", "testchart-1.0.0.tgz"))
+		finding := newFinding(paths, "icons", SeverityWarning, err)
+		assert.Equal(t, Finding{
+			Check:    "icons",
+			Severity: SeverityWarning,
+			Package:  "vendor/testchart",
+			File:     "assets/vendor/testchart-1.0.0.tgz",
+			Message:  "something is wrong",
+		}, finding)
+		assert.EqualError(t, finding, "icons: something is wrong")
+	})
+
+	t.Run("should keep relative file paths", func(t *testing.T) {
+		err := withLocation(errors.New("something is wrong"), "", "index.yaml")
+		finding := newFinding(paths, "icons", SeverityError, err)
+		assert.Equal(t, "index.yaml", finding.File)
+	})
+
+	t.Run("should leave package and file empty for errors without location", func(t *testing.T) {
+		finding := newFinding(paths, "icons", SeverityError, errors.New("something is wrong"))
+		assert.Empty(t, finding.Package)
+		assert.Empty(t, finding.File)
+	})
+
+	t.Run("locateErrors should not replace existing locations", func(t *testing.T) {
+		errs := locateErrors([]error{
+			withLocation(errors.New("first"), "", "assets/icons/testchart.png"),
+			errors.New("second"),
+		}, "index.yaml")
+		assert.Equal(t, "assets/icons/testchart.png", newFinding(paths, "icons", SeverityError, errs[0]).File)
+		assert.Equal(t, "index.yaml", newFinding(paths, "icons", SeverityError, errs[1]).File)
+	})
+}
@@ -14,8 +14,9 @@ import (
 // Checks two things related to icons: that the icon field of each chart version
 // in index.yaml refers to an icon that exists in the icons directory, and
 // that every icon in the icons directory is referred to by a chart version
-// in index.yaml.
-func validateIcons(paths p.Paths, _ ConfigurationYaml) []error {
+// in index.yaml. If configYaml.Package is set, only its chart and icon
+// are checked.
+func validateIcons(paths p.Paths, configYaml ConfigurationYaml) []error {
 
 	indexYaml, err := repo.LoadIndexFile(paths.IndexYaml)
 	if err != nil {
@@ -27,13 +28,22 @@ func validateIcons(paths p.Paths, _ ConfigurationYaml) []error {
 		return []error{fmt.Errorf("failed to read icons directory: %w", err)}
 	}
 
+	chartName := getChartName(configYaml.Package)
+	if chartName != "" {
+		indexYaml.Entries = map[string]repo.ChartVersions{chartName: indexYaml.Entries[chartName]}
+	}
+
 	iconFiles := make([]string, 0, len(dirEntries))
 	for _, dirEntry := range dirEntries {
+		iconName := strings.TrimSuffix(dirEntry.Name(), filepath.Ext(dirEntry.Name()))
+		if chartName != "" && iconName != chartName {
+			continue
+		}
 		iconPath := filepath.Join("assets", "icons", dirEntry.Name())
 		iconFiles = append(iconFiles, iconPath)
 	}
 
-	return validateLoadedIcons(indexYaml, iconFiles)
+	return locateErrors(validateLoadedIcons(indexYaml, iconFiles), paths.IndexYaml)
 }
 
 func validateLoadedIcons(indexYaml *repo.IndexFile, iconFiles []string) []error {
@@ -61,7 +71,7 @@ func validateLoadedIcons(indexYaml *repo.IndexFile, iconFiles []string) []error
 	for filePath, present := range iconMap {
 		if !present {
 			error := fmt.Errorf("icon file %s is not referenced in index.yaml", filePath)
-			errors = append(errors, error)
+			errors = append(errors, withLocation(error, "", filePath))
 		}
 	}
 
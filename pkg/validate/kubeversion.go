@@ -0,0 +1,76 @@
+package validate
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/rancher/partner-charts-ci/pkg/conform"
+	p "github.com/rancher/partner-charts-ci/pkg/paths"
+	"github.com/rancher/partner-charts-ci/pkg/pkg"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+const annotationKubeVersion = "catalog.cattle.io/kube-version"
+
+// validateKubeVersions checks that the kubeVersion constraints of chart
+// versions that have not yet been released have lower bounds with a "-0"
+// suffix, both in Chart.yaml and in the kube-version annotation. Without
+// this suffix charts are not shown on clusters whose Kubernetes versions
+// have a prerelease, such as GKE clusters. Packages that have
+// PreserveKubeVersion set in their upstream.yaml are not checked. If
+// configYaml.Package is set, only its chart versions are checked.
+func validateKubeVersions(paths p.Paths, configYaml ConfigurationYaml) []error {
+	newTgzFiles, err := listNewChartTgzFiles(paths, configYaml)
+	if err != nil {
+		return []error{fmt.Errorf("failed to list new chart versions: %w", err)}
+	}
+	packageWrappers, err := pkg.ListPackageWrappers(paths, configYaml.Package)
+	if err != nil {
+		return []error{fmt.Errorf("failed to list package wrappers: %w", err)}
+	}
+	preserved := map[string]bool{}
+	for _, packageWrapper := range packageWrappers {
+		preserved[packageWrapper.FullName()] = packageWrapper.UpstreamYaml.PreserveKubeVersion
+	}
+
+	chartName := getChartName(configYaml.Package)
+	errs := make([]error, 0)
+	for _, tgzFile := range newTgzFiles {
+		if chartName != "" && !strings.HasPrefix(filepath.Base(tgzFile), chartName+"-") {
+			continue
+		}
+		helmChart, err := loader.LoadFile(tgzFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load %s: %w", tgzFile, err))
+			continue
+		}
+		packageName := filepath.Base(filepath.Dir(tgzFile)) + "/" + helmChart.Name()
+		if configYaml.Package != "" && packageName != configYaml.Package {
+			continue
+		}
+		if preserved[packageName] {
+			continue
+		}
+		for _, err := range checkKubeVersionNormalized(tgzFile, helmChart) {
+			errs = append(errs, withLocation(err, packageName, tgzFile))
+		}
+	}
+
+	return errs
+}
+
+func checkKubeVersionNormalized(tgzFile string, helmChart *chart.Chart) []error {
+	errs := make([]error, 0, 2)
+	kubeVersion := helmChart.Metadata.KubeVersion
+	if normalized := conform.NormalizeKubeVersion(kubeVersion); normalized != kubeVersion {
+		errs = append(errs, fmt.Errorf("%s: kubeVersion %q should be %q", tgzFile, kubeVersion, normalized))
+	}
+	annotationValue := helmChart.Metadata.Annotations[annotationKubeVersion]
+	if normalized := conform.NormalizeKubeVersion(annotationValue); normalized != annotationValue {
+		errs = append(errs, fmt.Errorf("%s: annotation %s %q should be %q", tgzFile, annotationKubeVersion, annotationValue, normalized))
+	}
+	return errs
+}
@@ -0,0 +1,83 @@
+package validate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rancher/partner-charts-ci/pkg/conform"
+	"github.com/stretchr/testify/assert"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+func TestCheckKubeVersionNormalized(t *testing.T) {
+	t.Run("should return no errors when kubeVersion and annotation are normalized", func(t *testing.T) {
+		helmChart := &chart.Chart{
+			Metadata: &chart.Metadata{
+				KubeVersion: ">=1.21-0",
+				Annotations: map[string]string{
+					annotationKubeVersion: ">=1.21-0",
+				},
+			},
+		}
+		errors := checkKubeVersionNormalized("test.tgz", helmChart)
+		assert.Len(t, errors, 0)
+	})
+
+	t.Run("should return no errors when kubeVersion and annotation are not set", func(t *testing.T) {
+		helmChart := &chart.Chart{
+			Metadata: &chart.Metadata{},
+		}
+		errors := checkKubeVersionNormalized("test.tgz", helmChart)
+		assert.Len(t, errors, 0)
+	})
+
+	t.Run("should return errors when kubeVersion and annotation are not normalized", func(t *testing.T) {
+		helmChart := &chart.Chart{
+			Metadata: &chart.Metadata{
+				KubeVersion: ">=1.21",
+				Annotations: map[string]string{
+					annotationKubeVersion: ">=1.22 <1.30",
+				},
+			},
+		}
+		errors := checkKubeVersionNormalized("test.tgz", helmChart)
+		assert.Len(t, errors, 2)
+		assert.ErrorContains(t, errors[0], `kubeVersion ">=1.21" should be ">=1.21-0"`)
+		assert.ErrorContains(t, errors[1], `annotation catalog.cattle.io/kube-version ">=1.22 <1.30" should be ">=1.22-0 <1.30"`)
+	})
+}
+
+func TestValidateKubeVersions(t *testing.T) {
+	t.Run("should only check chart versions of Package when it is set", func(t *testing.T) {
+		paths := setUpGitTreeBaseRepo(t)
+		// ListPackageWrappers expects the packages directory to be relative
+		t.Chdir(paths.RepoRoot)
+		paths.Packages = "packages"
+		for _, chartName := range []string{"testchart", "otherchart"} {
+			packageDir := filepath.Join(paths.Packages, "vendor", chartName)
+			if err := os.MkdirAll(packageDir, 0o755); err != nil {
+				t.Fatalf("failed to create %s: %s", packageDir, err)
+			}
+			upstreamYaml := []byte("HelmRepo: https://charts.example.com\nHelmChart: " + chartName + "\n")
+			if err := os.WriteFile(filepath.Join(packageDir, "upstream.yaml"), upstreamYaml, 0o644); err != nil {
+				t.Fatalf("failed to write upstream.yaml: %s", err)
+			}
+			helmChart := &chart.Chart{
+				Metadata: &chart.Metadata{APIVersion: "v2", Name: chartName, Version: "2.0.0", KubeVersion: ">=1.21"},
+			}
+			if _, err := conform.SaveChart(helmChart, filepath.Join(paths.Assets, "vendor")); err != nil {
+				t.Fatalf("failed to save chart: %s", err)
+			}
+		}
+
+		configYaml := ConfigurationYaml{LocalBase: "HEAD"}
+		assert.Len(t, validateKubeVersions(paths, configYaml), 2)
+		configYaml.Package = "vendor/testchart"
+		errs := validateKubeVersions(paths, configYaml)
+		if assert.Len(t, errs, 1) {
+			assert.ErrorContains(t, errs[0], "testchart-2.0.0.tgz")
+		}
+	})
+}
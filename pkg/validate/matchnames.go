@@ -18,7 +18,7 @@ func validateIndexYamlAndPackagesDirNamesMatch(paths p.Paths, _ ConfigurationYam
 	if err != nil {
 		return []error{fmt.Errorf("failed to list package wrappers: %w", err)}
 	}
-	return matchPackageNames(indexYaml, packageWrappers)
+	return locateErrors(matchPackageNames(indexYaml, packageWrappers), paths.IndexYaml)
 }
 
 func matchPackageNames(indexYaml *repo.IndexFile, packageWrappers []pkg.PackageWrapper) []error {
@@ -32,7 +32,7 @@ func matchPackageNames(indexYaml *repo.IndexFile, packageWrappers []pkg.PackageW
 			indexYamlNames[packageWrapper.Name] = true
 		} else {
 			error := fmt.Errorf("chart name %q is present in packages/ but not in index.yaml", packageWrapper.Name)
-			errors = append(errors, error)
+			errors = append(errors, withLocation(error, packageWrapper.FullName(), packageWrapper.Path))
 		}
 	}
 	for packageName, present := range indexYamlNames {
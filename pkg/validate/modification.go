@@ -23,6 +23,18 @@ type DirectoryComparison struct {
 	Modified  []string
 	Added     []string
 	Removed   []string
+	// ChartDiffs describes how each modified chart archive differs,
+	// keyed by its path in Modified.
+	ChartDiffs map[string]ChartDiff
+}
+
+// addChartDiff records a modified chart archive.
+func (directoryComparison *DirectoryComparison) addChartDiff(updateFilePath string, chartDiff ChartDiff) {
+	directoryComparison.Modified = append(directoryComparison.Modified, updateFilePath)
+	if directoryComparison.ChartDiffs == nil {
+		directoryComparison.ChartDiffs = map[string]ChartDiff{}
+	}
+	directoryComparison.ChartDiffs[updateFilePath] = chartDiff
 }
 
 func (directoryComparison *DirectoryComparison) Match() bool {
@@ -34,42 +46,79 @@ func (directoryComparison *DirectoryComparison) Merge(newComparison DirectoryCom
 	directoryComparison.Modified = append(directoryComparison.Modified, newComparison.Modified...)
 	directoryComparison.Added = append(directoryComparison.Added, newComparison.Added...)
 	directoryComparison.Removed = append(directoryComparison.Removed, newComparison.Removed...)
+	for updateFilePath, chartDiff := range newComparison.ChartDiffs {
+		if directoryComparison.ChartDiffs == nil {
+			directoryComparison.ChartDiffs = map[string]ChartDiff{}
+		}
+		directoryComparison.ChartDiffs[updateFilePath] = chartDiff
+	}
 }
 
 // preventReleasedChartModifications validates that no released chart
 // versions have been modified outside of a few that must be allowed,
 // such as the deprecated field of Chart.yaml and Rancher-specific
-// annotations.
+// annotations. Released chart versions are those in any of the bases
+// returned by getReleasedBases.
 func preventReleasedChartModifications(paths p.Paths, configYaml ConfigurationYaml) (errs []error) {
-	cloneDir, err := os.MkdirTemp("", "gitRepo")
+	bases, err := getReleasedBases(paths, configYaml)
 	if err != nil {
-		logrus.Fatal(err)
+		return []error{err}
 	}
-	defer func() {
-		if closeErr := os.RemoveAll(cloneDir); closeErr != nil {
-			errs = append(errs, fmt.Errorf("failed to remove temporary clone directory: %w", closeErr))
-		}
-	}()
 
-	err = cloneRepo(configYaml.ValidateUpstreams[0].URL, configYaml.ValidateUpstreams[0].Branch, cloneDir)
-	if err != nil {
-		logrus.Fatal(err)
+	reported := map[string]bool{}
+	for _, base := range bases {
+		directoryComparison, err := base.compareAssets(paths.Assets)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to compare assets to %s: %w", base, err))
+			continue
+		}
+		for _, modifiedFile := range directoryComparison.Modified {
+			if reported[modifiedFile] {
+				continue
+			}
+			reported[modifiedFile] = true
+			err := fmt.Errorf("%s was modified", modifiedFile)
+			if chartDiff, ok := directoryComparison.ChartDiffs[modifiedFile]; ok {
+				err = fmt.Errorf("%s was modified compared to %s:\n%s", modifiedFile, base, chartDiff)
+			}
+			errs = append(errs, withLocation(err, "", modifiedFile))
+		}
 	}
+	return errs
+}
 
-	upstreamPath := filepath.Join(cloneDir, "assets")
-	updatePath, err := filepath.Abs(paths.Assets)
+// listNewChartTgzFiles returns the paths of the chart .tgz files in the
+// assets directory that have not been released, i.e. that are not present
+// in any of the bases returned by getReleasedBases.
+func listNewChartTgzFiles(paths p.Paths, configYaml ConfigurationYaml) ([]string, error) {
+	bases, err := getReleasedBases(paths, configYaml)
 	if err != nil {
-		return []error{fmt.Errorf("failed to get absolute path to assets dir: %w", err)}
+		return nil, err
 	}
-	directoryComparison, err := compareDirectories(upstreamPath, updatePath, []string{"icons"})
+
+	tgzFiles, err := filepath.Glob(filepath.Join(paths.Assets, "*", "*.tgz"))
 	if err != nil {
-		return []error{fmt.Errorf("failed to compare directories: %w", err)}
+		return nil, fmt.Errorf("failed to glob for chart tgz files: %w", err)
 	}
-
-	for _, modifiedFile := range directoryComparison.Modified {
-		errs = append(errs, fmt.Errorf("%s was modified", modifiedFile))
+	newTgzFiles := make([]string, 0, len(tgzFiles))
+	for _, tgzFile := range tgzFiles {
+		relativePath, err := filepath.Rel(paths.Assets, tgzFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get relative path of %s: %w", tgzFile, err)
+		}
+		released := false
+		for _, base := range bases {
+			if released, err = base.hasAsset(relativePath); err != nil {
+				return nil, err
+			} else if released {
+				break
+			}
+		}
+		if !released {
+			newTgzFiles = append(newTgzFiles, tgzFile)
+		}
 	}
-	return errs
+	return newTgzFiles, nil
 }
 
 func cloneRepo(url string, branch string, targetDir string) error {
@@ -148,14 +197,14 @@ func compareDirectories(upstreamPath, updatePath string, skipDirs []string) (Dir
 		}
 
 		if leftCheckSum != rightCheckSum && strings.HasSuffix(upstreamFilePath, ".tgz") {
-			chartMatch, err := matchHelmCharts(upstreamFilePath, updateFilePath)
-			if chartMatch {
-				directoryComparison.Unchanged = append(directoryComparison.Unchanged, updateFilePath)
-			} else {
-				directoryComparison.Modified = append(directoryComparison.Modified, updateFilePath)
-			}
+			chartDiff, err := DiffHelmCharts(upstreamFilePath, updateFilePath)
 			if err != nil {
 				logrus.Debug(err)
+				directoryComparison.Modified = append(directoryComparison.Modified, updateFilePath)
+			} else if chartDiff.Match() {
+				directoryComparison.Unchanged = append(directoryComparison.Unchanged, updateFilePath)
+			} else {
+				directoryComparison.addChartDiff(updateFilePath, chartDiff)
 			}
 		} else if leftCheckSum != rightCheckSum {
 			directoryComparison.Modified = append(directoryComparison.Modified, updateFilePath)
@@ -199,6 +248,13 @@ func compareDirectories(upstreamPath, updatePath string, skipDirs []string) (Dir
 	return directoryComparison, nil
 }
 
+// IsIgnoredAnnotation returns whether changes to annotation are allowed
+// in released chart versions. These are the partner-charts-specific
+// catalog.cattle.io annotations.
+func IsIgnoredAnnotation(annotation string) bool {
+	return strings.HasPrefix(annotation, "catalog.cattle.io")
+}
+
 // prepareTgzForComparison takes a path to a .tgz helm chart. It unpacks this
 // helm chart, applies modifications to it that cause the validation process
 // to ignore certain changes, and exports it to a temporary chart directory.
@@ -223,7 +279,7 @@ func prepareTgzForComparison(tgzPath string) (chartDirectory string, err error)
 
 	// Do not consider changes to partner-charts-specific chart annotations
 	for annotation := range upstreamChart.Metadata.Annotations {
-		if strings.HasPrefix(annotation, "catalog.cattle.io") {
+		if IsIgnoredAnnotation(annotation) {
 			delete(upstreamChart.Metadata.Annotations, annotation)
 		}
 	}
@@ -242,34 +298,3 @@ func prepareTgzForComparison(tgzPath string) (chartDirectory string, err error)
 
 	return chartDirectory, err
 }
-
-func matchHelmCharts(upstreamPath, updatePath string) (match bool, err error) {
-	upstreamChartDirectory, err := prepareTgzForComparison(upstreamPath)
-	if err != nil {
-		return false, fmt.Errorf("failed to prepare %s for comparison: %w", upstreamPath, err)
-	}
-	defer func() {
-		if closeErr := os.RemoveAll(upstreamChartDirectory); closeErr != nil {
-			err = errors.Join(err, closeErr)
-		}
-	}()
-
-	updateChartDirectory, err := prepareTgzForComparison(updatePath)
-	if err != nil {
-		return false, fmt.Errorf("failed to prepare %s for comparison: %w", updatePath, err)
-	}
-	defer func() {
-		if closeErr := os.RemoveAll(updateChartDirectory); closeErr != nil {
-			err = errors.Join(err, closeErr)
-		}
-	}()
-
-	directoryComparison, err := compareDirectories(upstreamChartDirectory, updateChartDirectory, []string{})
-	if err != nil {
-		return false, fmt.Errorf("failed to compare directories: %w", err)
-	}
-
-	match = directoryComparison.Match()
-
-	return match, err
-}
@@ -7,7 +7,7 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func TestMatchHelmCharts(t *testing.T) {
+func TestDiffHelmChartsMatch(t *testing.T) {
 	testCases := []struct {
 		Description   string
 		UpdateChart   string
@@ -44,11 +44,11 @@ func TestMatchHelmCharts(t *testing.T) {
 			if err != nil {
 				t.Fatalf("failed to get absolute path to update tgz: %s", err)
 			}
-			match, err := matchHelmCharts(upstreamPath, updatePath)
+			chartDiff, err := DiffHelmCharts(upstreamPath, updatePath)
 			if err != nil {
 				t.Fatalf("unexpected error: %s", err)
 			}
-			assert.Equal(t, testCase.ExpectedMatch, match)
+			assert.Equal(t, testCase.ExpectedMatch, chartDiff.Match())
 		})
 	}
 }
@@ -29,7 +29,7 @@ func validatePackagesDirectory(paths p.Paths, _ ConfigurationYaml) []error {
 			}
 			if !fileInfo.IsDir() {
 				error := fmt.Errorf("%s may contain only directories, but %s is not a directory", filepath.Dir(match), match)
-				errors = append(errors, error)
+				errors = append(errors, withLocation(error, "", match))
 			}
 		}
 	}
@@ -55,16 +55,16 @@ func validatePackagesDirectory(paths p.Paths, _ ConfigurationYaml) []error {
 		case "upstream.yaml":
 			if fileInfo.IsDir() {
 				error := fmt.Errorf("%s must be a file", match)
-				errors = append(errors, error)
+				errors = append(errors, withLocation(error, "", match))
 			}
 		case "overlay":
 			if !fileInfo.IsDir() {
 				error := fmt.Errorf("%s must be a directory", match)
-				errors = append(errors, error)
+				errors = append(errors, withLocation(error, "", match))
 			}
 		default:
 			error := fmt.Errorf("only upstream.yaml and overlay directory may exist in package directories but found %s", match)
-			errors = append(errors, error)
+			errors = append(errors, withLocation(error, "", match))
 		}
 	}
 
@@ -0,0 +1,63 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/rancher/partner-charts-ci/pkg/kubeapi"
+	p "github.com/rancher/partner-charts-ci/pkg/paths"
+	"github.com/rancher/partner-charts-ci/pkg/pkg"
+	"github.com/sirupsen/logrus"
+
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// validateRemovedAPIs checks that the latest chart version of each package
+// does not use any Kubernetes APIs that have been removed in a Kubernetes
+// version that the chart version's kubeVersion constraint allows. Only the
+// latest chart version is checked because released chart versions cannot
+// be changed; the problem can only be fixed by integrating a new one.
+func validateRemovedAPIs(paths p.Paths, configYaml ConfigurationYaml) []error {
+	indexYaml, err := repo.LoadIndexFile(paths.IndexYaml)
+	if err != nil {
+		return []error{fmt.Errorf("failed to load index.yaml: %w", err)}
+	}
+	packageWrappers, err := pkg.ListPackageWrappers(paths, configYaml.Package)
+	if err != nil {
+		return []error{fmt.Errorf("failed to list package wrappers: %w", err)}
+	}
+
+	errs := make([]error, 0)
+	for _, packageWrapper := range packageWrappers {
+		latestVersion, err := indexYaml.Get(packageWrapper.Name, "")
+		if errors.Is(err, repo.ErrNoChartName) || errors.Is(err, repo.ErrNoChartVersion) {
+			// validateIndexYamlAndPackagesDirNamesMatch reports this
+			continue
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("failed to get latest version of %s: %w", packageWrapper.FullName(), err))
+			continue
+		}
+
+		tgzFilename := fmt.Sprintf("%s-%s.tgz", packageWrapper.Name, latestVersion.Version)
+		tgzPath := filepath.Join(paths.Assets, packageWrapper.Vendor, tgzFilename)
+		helmChart, err := loader.LoadFile(tgzPath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load %s: %w", tgzPath, err))
+			continue
+		}
+
+		usages, err := kubeapi.FindRemovedAPIUsages(helmChart)
+		if err != nil {
+			logrus.Warnf("failed to check %s version %s for removed APIs: %s", packageWrapper.FullName(), latestVersion.Version, err)
+			continue
+		}
+		for _, usage := range usages {
+			err := fmt.Errorf("%s version %s: %s", packageWrapper.FullName(), latestVersion.Version, usage)
+			errs = append(errs, withLocation(err, packageWrapper.FullName(), tgzPath))
+		}
+	}
+
+	return errs
+}
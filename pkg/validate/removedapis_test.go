@@ -0,0 +1,60 @@
+package validate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rancher/partner-charts-ci/pkg/conform"
+	"github.com/stretchr/testify/assert"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+func TestValidateRemovedAPIs(t *testing.T) {
+	podSecurityPolicy := []byte("apiVersion: policy/v1beta1\nkind: PodSecurityPolicy\nmetadata:\n  name: testchart\n")
+
+	t.Run("should return finding for latest chart version that uses a removed API", func(t *testing.T) {
+		paths := getConsistencyTestPaths(t)
+		// ListPackageWrappers expects the packages directory to be relative
+		t.Chdir(paths.RepoRoot)
+		paths.Packages = "packages"
+		packageDir := filepath.Join(paths.Packages, "vendor", "testchart")
+		if err := os.MkdirAll(packageDir, 0o755); err != nil {
+			t.Fatalf("failed to create %s: %s", packageDir, err)
+		}
+		upstreamYaml := []byte("HelmRepo: https://charts.example.com\nHelmChart: testchart\n")
+		if err := os.WriteFile(filepath.Join(packageDir, "upstream.yaml"), upstreamYaml, 0o644); err != nil {
+			t.Fatalf("failed to write upstream.yaml: %s", err)
+		}
+
+		indexYaml := repo.NewIndexFile()
+		for _, version := range []string{"1.0.0", "2.0.0"} {
+			helmChart := &chart.Chart{
+				Metadata: &chart.Metadata{APIVersion: "v2", Name: "testchart", Version: version, KubeVersion: ">=1.21-0"},
+				Templates: []*chart.File{
+					{Name: "templates/psp.yaml", Data: podSecurityPolicy},
+				},
+			}
+			if _, err := conform.SaveChart(helmChart, filepath.Join(paths.Assets, "vendor")); err != nil {
+				t.Fatalf("failed to save chart: %s", err)
+			}
+			if err := indexYaml.MustAdd(helmChart.Metadata, "testchart-"+version+".tgz", "", "sha256:0"); err != nil {
+				t.Fatalf("failed to add chart version to index: %s", err)
+			}
+		}
+		if err := indexYaml.WriteFile(paths.IndexYaml, 0o644); err != nil {
+			t.Fatalf("failed to write index.yaml: %s", err)
+		}
+
+		errs := validateRemovedAPIs(paths, ConfigurationYaml{})
+		if !assert.Len(t, errs, 1) {
+			return
+		}
+		finding := newFinding(paths, "removed-apis", SeverityError, errs[0])
+		assert.Equal(t, "vendor/testchart", finding.Package)
+		assert.Equal(t, "assets/vendor/testchart-2.0.0.tgz", finding.File)
+		assert.Contains(t, finding.Message, "vendor/testchart version 2.0.0: testchart/templates/psp.yaml: policy/v1beta1 PodSecurityPolicy is removed in Kubernetes 1.25")
+	})
+}
@@ -0,0 +1,252 @@
+package validate
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format is an output format for the Result of Run.
+type Format string
+
+const (
+	FormatText  Format = "text"
+	FormatJSON  Format = "json"
+	FormatSARIF Format = "sarif"
+	FormatJUnit Format = "junit"
+)
+
+// Formats contains every supported Format.
+var Formats = []Format{FormatText, FormatJSON, FormatSARIF, FormatJUnit}
+
+// WriteReport writes result to writer in the passed format.
+func WriteReport(writer io.Writer, format Format, result Result) error {
+	switch format {
+	case FormatText:
+		return writeTextReport(writer, result)
+	case FormatJSON:
+		return writeJSONReport(writer, result)
+	case FormatSARIF:
+		return writeSARIFReport(writer, result)
+	case FormatJUnit:
+		return writeJUnitReport(writer, result)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+func writeTextReport(writer io.Writer, result Result) error {
+	for _, finding := range result.Findings {
+		prefix := ""
+		if finding.Severity == SeverityWarning {
+			prefix = "warning: "
+		}
+		if _, err := fmt.Fprintf(writer, "%s%s\n", prefix, finding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type jsonReport struct {
+	Checks   []string  `json:"checks"`
+	Findings []Finding `json:"findings"`
+}
+
+func writeJSONReport(writer io.Writer, result Result) error {
+	report := jsonReport{
+		Checks:   make([]string, 0, len(result.Validations)),
+		Findings: result.Findings,
+	}
+	for _, validation := range result.Validations {
+		report.Checks = append(report.Checks, validation.Name)
+	}
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// The sarif* types are the parts of the SARIF 2.1.0 format that
+// WriteReport uses.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string          `json:"ruleId"`
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations,omitempty"`
+	Properties map[string]any  `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+func writeSARIFReport(writer io.Writer, result Result) error {
+	run := sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           "partner-charts-ci",
+				InformationURI: "https://github.com/rancher/partner-charts-ci",
+				Rules:          make([]sarifRule, 0, len(result.Validations)),
+			},
+		},
+		Results: make([]sarifResult, 0, len(result.Findings)),
+	}
+	for _, validation := range result.Validations {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:               validation.Name,
+			ShortDescription: sarifMessage{Text: validation.Description},
+		})
+	}
+	for _, finding := range result.Findings {
+		sarifResult := sarifResult{
+			RuleID:  finding.Check,
+			Level:   string(finding.Severity),
+			Message: sarifMessage{Text: finding.Message},
+		}
+		if finding.File != "" {
+			sarifResult.Locations = []sarifLocation{
+				{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: finding.File}}},
+			}
+		}
+		if finding.Package != "" {
+			sarifResult.Properties = map[string]any{"package": finding.Package}
+		}
+		run.Results = append(run.Results, sarifResult)
+	}
+
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(log)
+}
+
+// The junit* types are the parts of the JUnit XML format that
+// WriteReport uses. Each validation is a test case, and each finding
+// with SeverityError is a failure of its test case. Findings with
+// SeverityWarning are written to the system-out of their test case.
+type junitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string         `xml:"name,attr"`
+	ClassName string         `xml:"classname,attr"`
+	Failures  []junitFailure `xml:"failure"`
+	SystemOut string         `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func writeJUnitReport(writer io.Writer, result Result) error {
+	testSuite := junitTestSuite{
+		Name:      "validate",
+		Tests:     len(result.Validations),
+		TestCases: make([]junitTestCase, 0, len(result.Validations)),
+	}
+	for _, validation := range result.Validations {
+		testCase := junitTestCase{
+			Name:      validation.Name,
+			ClassName: "validate",
+		}
+		warnings := make([]string, 0)
+		for _, finding := range result.Findings {
+			if finding.Check != validation.Name {
+				continue
+			}
+			text := finding.Message
+			if finding.File != "" {
+				text = finding.File + ": " + text
+			}
+			if finding.Severity == SeverityWarning {
+				warnings = append(warnings, "warning: "+text)
+				continue
+			}
+			testCase.Failures = append(testCase.Failures, junitFailure{
+				Message: finding.Message,
+				Type:    string(finding.Severity),
+				Text:    text,
+			})
+		}
+		testCase.SystemOut = strings.Join(warnings, "\n")
+		if len(testCase.Failures) > 0 {
+			testSuite.Failures++
+		}
+		testSuite.TestCases = append(testSuite.TestCases, testCase)
+	}
+
+	testSuites := junitTestSuites{
+		Name:       "partner-charts-ci",
+		Tests:      testSuite.Tests,
+		Failures:   testSuite.Failures,
+		TestSuites: []junitTestSuite{testSuite},
+	}
+	if _, err := io.WriteString(writer, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(writer)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(testSuites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(writer, "\n")
+	return err
+}
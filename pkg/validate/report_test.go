@@ -0,0 +1,96 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteReport(t *testing.T) {
+	result := Result{
+		Validations: []Validation{
+			{Name: "icons", Description: "Icons must be valid"},
+			{Name: "removed-apis", Description: "APIs must not be removed"},
+		},
+		Findings: []Finding{
+			{Check: "icons", Severity: SeverityError, Package: "vendor/testchart", File: "index.yaml", Message: "icon is missing"},
+			{Check: "icons", Severity: SeverityWarning, Message: "icon is large"},
+		},
+	}
+
+	t.Run("text", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		if !assert.Nil(t, WriteReport(buffer, FormatText, result)) {
+			return
+		}
+		assert.Equal(t, "icons: icon is missing\nwarning: icons: icon is large\n", buffer.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		if !assert.Nil(t, WriteReport(buffer, FormatJSON, result)) {
+			return
+		}
+		report := jsonReport{}
+		if !assert.Nil(t, json.Unmarshal(buffer.Bytes(), &report)) {
+			return
+		}
+		assert.Equal(t, []string{"icons", "removed-apis"}, report.Checks)
+		assert.Equal(t, result.Findings, report.Findings)
+	})
+
+	t.Run("sarif", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		if !assert.Nil(t, WriteReport(buffer, FormatSARIF, result)) {
+			return
+		}
+		log := sarifLog{}
+		if !assert.Nil(t, json.Unmarshal(buffer.Bytes(), &log)) {
+			return
+		}
+		assert.Equal(t, "2.1.0", log.Version)
+		if !assert.Len(t, log.Runs, 1) {
+			return
+		}
+		run := log.Runs[0]
+		assert.Len(t, run.Tool.Driver.Rules, 2)
+		if assert.Len(t, run.Results, 2) {
+			assert.Equal(t, "icons", run.Results[0].RuleID)
+			assert.Equal(t, "error", run.Results[0].Level)
+			if assert.Len(t, run.Results[0].Locations, 1) {
+				assert.Equal(t, "index.yaml", run.Results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+			}
+			assert.Equal(t, "warning", run.Results[1].Level)
+			assert.Len(t, run.Results[1].Locations, 0)
+		}
+	})
+
+	t.Run("junit", func(t *testing.T) {
+		buffer := &bytes.Buffer{}
+		if !assert.Nil(t, WriteReport(buffer, FormatJUnit, result)) {
+			return
+		}
+		testSuites := junitTestSuites{}
+		if !assert.Nil(t, xml.Unmarshal(buffer.Bytes(), &testSuites)) {
+			return
+		}
+		assert.Equal(t, 2, testSuites.Tests)
+		assert.Equal(t, 1, testSuites.Failures)
+		if !assert.Len(t, testSuites.TestSuites, 1) || !assert.Len(t, testSuites.TestSuites[0].TestCases, 2) {
+			return
+		}
+		iconsTestCase := testSuites.TestSuites[0].TestCases[0]
+		if assert.Len(t, iconsTestCase.Failures, 1) {
+			assert.Equal(t, "index.yaml: icon is missing", iconsTestCase.Failures[0].Text)
+		}
+		assert.Equal(t, "warning: icon is large", iconsTestCase.SystemOut)
+		assert.Len(t, testSuites.TestSuites[0].TestCases[1].Failures, 0)
+	})
+
+	t.Run("should return error for unknown format", func(t *testing.T) {
+		assert.ErrorContains(t, WriteReport(&bytes.Buffer{}, "xml", result), `unknown format "xml"`)
+	})
+}
@@ -1,7 +1,12 @@
 package validate
 
 import (
+	"fmt"
+	"slices"
+	"strings"
+
 	p "github.com/rancher/partner-charts-ci/pkg/paths"
+	"github.com/sirupsen/logrus"
 )
 
 // A ValidationFunc is a function that checks one specific thing about the
@@ -9,18 +14,220 @@ import (
 // more errors explaining what is wrong.
 type ValidationFunc func(paths p.Paths, configYaml ConfigurationYaml) []error
 
-func Run(paths p.Paths, configYaml ConfigurationYaml) []error {
-	validationErrors := []error{}
-	validationFuncs := []ValidationFunc{
-		preventReleasedChartModifications,
-		preventDuplicatePackageNames,
-		validatePackagesDirectory,
-		validateIndexYamlAndPackagesDirNamesMatch,
-		validateIcons,
+// Severity determines whether the errors from a validation cause
+// validation to fail.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// A Validation is a named ValidationFunc.
+type Validation struct {
+	Name        string
+	Description string
+	Func        ValidationFunc
+	// RequiresUpstream is true if the validation compares the repository
+	// to the validation upstreams in configuration.yaml or Options.Base.
+	RequiresUpstream bool
+	// PerPackage is true if the validation checks each package on its
+	// own, so that it can be limited to Options.Package.
+	PerPackage bool
+}
+
+// validations contains every validation, in the order they are run.
+var validations = []Validation{
+	{
+		Name:             "released-chart-modifications",
+		Description:      "Released chart versions must not be modified",
+		Func:             preventReleasedChartModifications,
+		RequiresUpstream: true,
+	},
+	{
+		Name:        "duplicate-package-names",
+		Description: "Packages of different vendors must not have the same name",
+		Func:        preventDuplicatePackageNames,
+	},
+	{
+		Name:        "packages-directory",
+		Description: "packages/ must only contain package directories",
+		Func:        validatePackagesDirectory,
+	},
+	{
+		Name:        "package-names",
+		Description: "Chart names in index.yaml must match the packages in packages/",
+		Func:        validateIndexYamlAndPackagesDirNamesMatch,
+	},
+	{
+		Name:        "icons",
+		Description: "Icons in index.yaml must match the icons in assets/icons/",
+		Func:        validateIcons,
+		PerPackage:  true,
+	},
+	{
+		Name:        "removed-apis",
+		Description: "The latest chart version of each package must not use removed Kubernetes APIs",
+		Func:        validateRemovedAPIs,
+		PerPackage:  true,
+	},
+	{
+		Name:             "kube-versions",
+		Description:      "The kubeVersion constraints of new chart versions must allow prerelease versions",
+		Func:             validateKubeVersions,
+		RequiresUpstream: true,
+		PerPackage:       true,
+	},
+	{
+		Name:        "featured-annotations",
+		Description: "Only the latest chart version of each package may be featured",
+		Func:        validateFeaturedAnnotations,
+	},
+	{
+		Name:        "charts-match-assets",
+		Description: "charts/ must contain exactly the unpacked chart archives in assets/",
+		Func:        validateChartsMatchAssets,
+		PerPackage:  true,
+	},
+	{
+		Name:        "index-matches-assets",
+		Description: "index.yaml must contain exactly the chart archives in assets/, with correct digests",
+		Func:        validateIndexYamlMatchesAssets,
+	},
+	{
+		Name:        "asset-vendors",
+		Description: "Chart archives must be in the assets/ directory of their package's vendor",
+		Func:        validateAssetVendors,
+		PerPackage:  true,
+	},
+	{
+		Name:        "annotation-state",
+		Description: "Released chart versions must reflect the upstream.yaml of their package",
+		Func:        validateAnnotationState,
+		PerPackage:  true,
+	},
+}
+
+// List returns every validation, in the order they are run.
+func List() []Validation {
+	return slices.Clone(validations)
+}
+
+// getChartName returns the chart name of packageName, which is in
+// <vendor>/<chart> format, or an empty string if packageName is empty.
+func getChartName(packageName string) string {
+	_, chartName, _ := strings.Cut(packageName, "/")
+	return chartName
+}
+
+func getValidation(name string) (Validation, bool) {
+	index := slices.IndexFunc(validations, func(validation Validation) bool {
+		return validation.Name == name
+	})
+	if index == -1 {
+		return Validation{}, false
+	}
+	return validations[index], true
+}
+
+// Options selects the validations that Run runs. If Only is not empty,
+// only the validations named in it are run, even if they are disabled
+// in configuration.yaml. Validations named in Skip are never run.
+type Options struct {
+	Only []string
+	Skip []string
+	// Package is a package in <vendor>/<chart> format. If it is set,
+	// only the validations that are PerPackage are run, and they only
+	// check that package.
+	Package string
+	// Base is a revision in the local git repository, such as
+	// origin/main, that contains the released chart versions. If it is
+	// empty or cannot be read, the validation upstreams in
+	// configuration.yaml are cloned instead.
+	Base string
+}
+
+func (options Options) validate() error {
+	for _, name := range append(slices.Clone(options.Only), options.Skip...) {
+		if _, ok := getValidation(name); !ok {
+			return fmt.Errorf("unknown validation %q", name)
+		}
+	}
+	return nil
+}
+
+// Result contains the validations that Run ran and what they found.
+type Result struct {
+	Validations []Validation
+	Findings    []Finding
+}
+
+// Errors returns the findings with SeverityError. Only these should
+// cause validation to fail.
+func (result Result) Errors() []Finding {
+	return result.filterFindings(SeverityError)
+}
+
+// Warnings returns the findings with SeverityWarning.
+func (result Result) Warnings() []Finding {
+	return result.filterFindings(SeverityWarning)
+}
+
+func (result Result) filterFindings(severity Severity) []Finding {
+	findings := make([]Finding, 0, len(result.Findings))
+	for _, finding := range result.Findings {
+		if finding.Severity == severity {
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+// Run runs the validations selected by options and configYaml. It
+// returns an error if the validations cannot be run at all.
+func Run(paths p.Paths, configYaml ConfigurationYaml, options Options) (Result, error) {
+	if err := options.validate(); err != nil {
+		return Result{}, err
+	}
+
+	selectedValidations := make([]Validation, 0, len(validations))
+	for _, validation := range validations {
+		if slices.Contains(options.Skip, validation.Name) {
+			continue
+		}
+		if options.Package != "" && !validation.PerPackage {
+			continue
+		}
+		if len(options.Only) > 0 {
+			if !slices.Contains(options.Only, validation.Name) {
+				continue
+			}
+		} else if !configYaml.IsEnabled(validation.Name) {
+			continue
+		}
+		if validation.RequiresUpstream && len(configYaml.ValidateUpstreams) == 0 && options.Base == "" {
+			return Result{}, fmt.Errorf("validation %s requires validation upstream in configuration.yaml or a base revision", validation.Name)
+		}
+		selectedValidations = append(selectedValidations, validation)
+	}
+
+	configYaml.LocalBase = options.Base
+	configYaml.Package = options.Package
+	defer func() {
+		if err := removeReleasedBases(); err != nil {
+			logrus.Error(err)
+		}
+	}()
+	result := Result{
+		Validations: selectedValidations,
+		Findings:    []Finding{},
 	}
-	for _, validationFunc := range validationFuncs {
-		errors := validationFunc(paths, configYaml)
-		validationErrors = append(validationErrors, errors...)
+	for _, validation := range selectedValidations {
+		logrus.Debugf("Running validation %s", validation.Name)
+		severity := configYaml.GetSeverity(validation.Name)
+		for _, err := range validation.Func(paths, configYaml) {
+			result.Findings = append(result.Findings, newFinding(paths, validation.Name, severity, err))
+		}
 	}
-	return validationErrors
+	return result, nil
 }
@@ -0,0 +1,136 @@
+package validate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	p "github.com/rancher/partner-charts-ci/pkg/paths"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigurationYaml(t *testing.T) {
+	disabled := false
+
+	t.Run("Validate", func(t *testing.T) {
+		t.Run("should accept configuration without validation upstream", func(t *testing.T) {
+			assert.Nil(t, ConfigurationYaml{}.Validate())
+		})
+
+		t.Run("should reject validation upstream without branch", func(t *testing.T) {
+			configYaml := ConfigurationYaml{
+				ValidateUpstreams: []validateUpstream{{URL: "https://github.com/rancher/partner-charts"}},
+			}
+			assert.ErrorContains(t, configYaml.Validate(), "must provide branch")
+		})
+
+		t.Run("should reject unknown validation", func(t *testing.T) {
+			configYaml := ConfigurationYaml{
+				Validations: map[string]ValidationConfig{"bogus": {}},
+			}
+			assert.ErrorContains(t, configYaml.Validate(), `unknown validation "bogus"`)
+		})
+
+		t.Run("should reject invalid severity", func(t *testing.T) {
+			configYaml := ConfigurationYaml{
+				Validations: map[string]ValidationConfig{"icons": {Severity: "fatal"}},
+			}
+			assert.ErrorContains(t, configYaml.Validate(), `invalid severity "fatal" for validation icons`)
+		})
+	})
+
+	t.Run("IsEnabled and GetSeverity should default to enabled errors", func(t *testing.T) {
+		configYaml := ConfigurationYaml{
+			Validations: map[string]ValidationConfig{
+				"icons": {Enabled: &disabled, Severity: SeverityWarning},
+			},
+		}
+		assert.False(t, configYaml.IsEnabled("icons"))
+		assert.Equal(t, SeverityWarning, configYaml.GetSeverity("icons"))
+		assert.True(t, configYaml.IsEnabled("removed-apis"))
+		assert.Equal(t, SeverityError, configYaml.GetSeverity("removed-apis"))
+	})
+
+	t.Run("ReadConfig should parse validations", func(t *testing.T) {
+		configYamlPath := filepath.Join(t.TempDir(), "configuration.yaml")
+		contents := "validations:\n  icons:\n    enabled: false\n    severity: warning\n"
+		if err := os.WriteFile(configYamlPath, []byte(contents), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %s", configYamlPath, err)
+		}
+		configYaml, err := ReadConfig(configYamlPath)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.False(t, configYaml.IsEnabled("icons"))
+		assert.Equal(t, SeverityWarning, configYaml.GetSeverity("icons"))
+	})
+}
+
+func TestRun(t *testing.T) {
+	// The icons and featured-annotations validations each return one
+	// error when index.yaml does not exist, so the validations that ran
+	// can be told apart by the names in the returned errors.
+	paths := p.Paths{
+		Assets:    filepath.Join(t.TempDir(), "assets"),
+		IndexYaml: filepath.Join(t.TempDir(), "index.yaml"),
+		Icons:     filepath.Join(t.TempDir(), "icons"),
+	}
+
+	t.Run("should only run validations passed in Only", func(t *testing.T) {
+		result, err := Run(paths, ConfigurationYaml{}, Options{Only: []string{"icons"}})
+		if !assert.Nil(t, err) {
+			return
+		}
+		if assert.Len(t, result.Errors(), 1) {
+			assert.ErrorContains(t, result.Errors()[0], "icons: ")
+		}
+	})
+
+	t.Run("should not run validations passed in Skip", func(t *testing.T) {
+		result, err := Run(paths, ConfigurationYaml{}, Options{
+			Only: []string{"icons", "featured-annotations"},
+			Skip: []string{"icons"},
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+		if assert.Len(t, result.Errors(), 1) {
+			assert.ErrorContains(t, result.Errors()[0], "featured-annotations: ")
+		}
+	})
+
+	t.Run("should only run validations that are PerPackage when Package is set", func(t *testing.T) {
+		result, err := Run(paths, ConfigurationYaml{}, Options{
+			Only:    []string{"icons", "featured-annotations"},
+			Package: "vendor/testchart",
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+		if assert.Len(t, result.Errors(), 1) {
+			assert.ErrorContains(t, result.Errors()[0], "icons: ")
+		}
+	})
+
+	t.Run("should return errors of warning validations as warnings", func(t *testing.T) {
+		configYaml := ConfigurationYaml{
+			Validations: map[string]ValidationConfig{"icons": {Severity: SeverityWarning}},
+		}
+		result, err := Run(paths, configYaml, Options{Only: []string{"icons"}})
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Len(t, result.Errors(), 0)
+		assert.Len(t, result.Warnings(), 1)
+	})
+
+	t.Run("should return error for unknown validation", func(t *testing.T) {
+		_, err := Run(paths, ConfigurationYaml{}, Options{Skip: []string{"bogus"}})
+		assert.ErrorContains(t, err, `unknown validation "bogus"`)
+	})
+
+	t.Run("should return error when validation requires missing upstream", func(t *testing.T) {
+		_, err := Run(paths, ConfigurationYaml{}, Options{Only: []string{"released-chart-modifications"}})
+		assert.ErrorContains(t, err, "validation released-chart-modifications requires validation upstream")
+	})
+}